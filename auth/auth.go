@@ -8,8 +8,10 @@ import (
 	"log"
 	"log/slog"
 	"net/http"
+	"slices"
 
 	"github.com/abibby/salusa/clog"
+	"github.com/abibby/salusa/database/cryptcolumn"
 	"github.com/abibby/salusa/openapidoc"
 	"github.com/abibby/salusa/request"
 	"github.com/abibby/salusa/router"
@@ -28,8 +30,23 @@ var (
 
 var appKey []byte
 
+// AppKeyConfiger is implemented by configs that provide the app key. Previous
+// keys are used to decrypt values encrypted before the key was rotated.
+type AppKeyConfiger interface {
+	AppKey() []byte
+	PreviousAppKeys() [][]byte
+}
+
+// SetAppKey sets the key used to sign tokens and encrypt cryptcolumn values.
 func SetAppKey(key []byte) {
 	appKey = key
+	cryptcolumn.SetKey(key)
+}
+
+// SetPreviousAppKeys sets old app keys that can still decrypt cryptcolumn
+// values.
+func SetPreviousAppKeys(keys ...[]byte) {
+	cryptcolumn.SetPreviousKeys(keys...)
 }
 func getAppKey() []byte {
 	if appKey == nil {
@@ -60,10 +77,21 @@ func getClaimsCtx(ctx context.Context) (claims *Claims, inContext bool) {
 }
 
 func setClaims(r *http.Request, claims *Claims) *http.Request {
-	return r.WithContext(context.WithValue(r.Context(), claimKey, claims))
+	return r.WithContext(WithClaims(r.Context(), claims))
+}
+
+// WithClaims returns a copy of ctx with claims attached.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimKey, claims)
 }
 
-func AttachUser() router.MiddlewareFunc {
+// AttachUser adds the claims of the first guard that authenticates the
+// request to its context. With no guards the request is authenticated with a
+// BearerGuard.
+func AttachUser(guards ...Guard) router.MiddlewareFunc {
+	if len(guards) == 0 {
+		guards = []Guard{NewBearerGuard()}
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Context().Value(claimKey) != nil {
@@ -71,8 +99,8 @@ func AttachUser() router.MiddlewareFunc {
 				return
 			}
 
-			claims, err := authenticate(r)
-			if errors.Is(err, ErrMissingAuthorizationHeader) {
+			claims, err := authenticateGuards(r, guards)
+			if errors.Is(err, ErrNoCredentials) {
 				// noop
 			} else if err != nil {
 				clog.Use(r.Context()).Warn("authentication failed", "err", err)
@@ -124,10 +152,13 @@ func (m *LoggedInMiddleware) OperationMiddleware(s *spec.Operation) *spec.Operat
 }
 
 type HasClaimMiddleware struct {
-	validate func(c *Claims) bool
+	validate               func(c *Claims) bool
+	requirements           []string
+	securityDefinitionName string
 }
 
 var _ router.Middleware = (*HasClaimMiddleware)(nil)
+var _ openapidoc.OperationMiddleware = (*HasClaimMiddleware)(nil)
 
 func (m *HasClaimMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -145,10 +176,51 @@ func (m *HasClaimMiddleware) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+// Describe lists the claims checked by the middleware in the api docs.
+func (m *HasClaimMiddleware) Describe(requirements ...string) *HasClaimMiddleware {
+	m.requirements = requirements
+	return m
+}
+func (m *HasClaimMiddleware) SecurityDefinition(name string) *HasClaimMiddleware {
+	m.securityDefinitionName = name
+	return m
+}
+func (m *HasClaimMiddleware) OperationMiddleware(s *spec.Operation) *spec.Operation {
+	return documentRequirement(s, m.securityDefinitionName, m.requirements...)
+}
+
 func HasClaim(validate func(c *Claims) bool) *HasClaimMiddleware {
 	return &HasClaimMiddleware{
-		validate: validate,
+		validate:               validate,
+		securityDefinitionName: openapidoc.DefaultSecurityDefinitionName,
+	}
+}
+
+// HasRole rejects requests from users without one of the roles.
+func HasRole(roles ...string) *HasClaimMiddleware {
+	requirements := make([]string, len(roles))
+	for i, role := range roles {
+		requirements[i] = "role:" + role
+	}
+	return HasClaim(func(c *Claims) bool {
+		return slices.ContainsFunc(roles, c.HasRole)
+	}).Describe(requirements...)
+}
+
+// HasPermission rejects requests from users without all of the permissions.
+func HasPermission(permissions ...string) *HasClaimMiddleware {
+	requirements := make([]string, len(permissions))
+	for i, permission := range permissions {
+		requirements[i] = "permission:" + permission
 	}
+	return HasClaim(func(c *Claims) bool {
+		for _, p := range permissions {
+			if !c.HasPermission(p) {
+				return false
+			}
+		}
+		return true
+	}).Describe(requirements...)
 }
 
 func respond(resp request.Responder, w http.ResponseWriter, r *http.Request) {
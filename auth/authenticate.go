@@ -8,7 +8,7 @@ import (
 )
 
 var (
-	ErrMissingAuthorizationHeader = fmt.Errorf("missing Authorization header")
+	ErrMissingAuthorizationHeader = fmt.Errorf("missing Authorization header: %w", ErrNoCredentials)
 	ErrInvalidAuthorizationHeader = fmt.Errorf("invalid Authorization header")
 	ErrUnexpectedAlgorithm        = fmt.Errorf("unexpected algorithm")
 	ErrNoAccessScope              = fmt.Errorf("no access scope")
@@ -25,6 +25,9 @@ func authenticate(r *http.Request) (*Claims, error) {
 		return nil, ErrInvalidAuthorizationHeader
 	}
 	tokenStr := authHeader[len(prefix):]
+	if strings.HasPrefix(tokenStr, PersonalAccessTokenPrefix) {
+		return nil, fmt.Errorf("personal access token: %w", ErrNoCredentials)
+	}
 	claims, err := Parse(tokenStr)
 	if err != nil {
 		return nil, err
@@ -32,5 +35,14 @@ func authenticate(r *http.Request) (*Claims, error) {
 	if !slices.Contains(claims.Scope, ScopeAccess) {
 		return nil, ErrNoAccessScope
 	}
+	if d := getDenylist(); d != nil && claims.ID != "" {
+		denied, err := d.Denied(r.Context(), claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if denied {
+			return nil, ErrTokenRevoked
+		}
+	}
 	return claims, nil
 }
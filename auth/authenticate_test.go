@@ -5,6 +5,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/abibby/salusa/database/cache"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
@@ -28,3 +31,28 @@ func TestAuthenticate(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "test", c.Subject)
 }
+
+func TestAuthenticateDenylist(t *testing.T) {
+	SetDenylist(NewCacheDenylist(cache.NewMemoryStore()))
+	defer SetDenylist(nil)
+
+	claims := NewClaims().
+		WithSubject("test").
+		WithJWTID("token-id").
+		WithLifetime(time.Hour).
+		WithScopes(ScopeAccess)
+	token, err := GenerateToken(claims)
+	assert.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "https://example.com", http.NoBody)
+	r.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	_, err = authenticate(r)
+	assert.NoError(t, err)
+
+	err = denyAccessToken(r.Context(), claims)
+	assert.NoError(t, err)
+
+	_, err = authenticate(r)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+}
@@ -1,10 +1,14 @@
 package auth
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/abibby/salusa/database/jsoncolumn"
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -13,6 +17,13 @@ import (
 const (
 	ScopeRefresh = "refresh"
 	ScopeAccess  = "access"
+	// ScopeMFAPending is the scope of tokens issued after a password is
+	// checked for a user with two factor authentication. They can only be
+	// exchanged for access and refresh tokens with a TOTP or recovery code.
+	ScopeMFAPending = "mfa-pending"
+	// ScopePersonalAccessToken is added to the claims of requests
+	// authenticated with a personal access token.
+	ScopePersonalAccessToken = "personal-access-token"
 )
 
 type ScopeStrings []string
@@ -42,6 +53,15 @@ type Claims struct {
 
 	// The `scope` (Scope) claim. See https://www.rfc-editor.org/rfc/rfc8693.html#name-scope-scopes-claim
 	Scope ScopeStrings `json:"scope,omitempty"`
+
+	// The `tenant` (Tenant) claim is the tenant the subject belongs to.
+	Tenant string `json:"tenant,omitempty"`
+
+	// The `roles` (Roles) claim is the names of the subjects roles.
+	Roles []string `json:"roles,omitempty"`
+	// The `permissions` (Permissions) claim is the permissions granted by the
+	// subjects roles.
+	Permissions []string `json:"permissions,omitempty"`
 }
 
 func NewClaims() *Claims {
@@ -152,3 +172,46 @@ func (c *Claims) WithScopes(scope ...string) *Claims {
 	c.Scope = scope
 	return c
 }
+
+// The tenant claim identifies the tenant the subject belongs to.
+func (c *Claims) WithTenant(tenant string) *Claims {
+	c.Tenant = tenant
+	return c
+}
+
+// The roles claim lists the roles of the subject.
+func (c *Claims) WithRoles(roles ...string) *Claims {
+	c.Roles = roles
+	return c
+}
+
+// The permissions claim lists the permissions granted to the subject.
+func (c *Claims) WithPermissions(permissions ...string) *Claims {
+	c.Permissions = permissions
+	return c
+}
+
+// HasRole reports if the claims include the role. It is safe to call on nil
+// claims.
+func (c *Claims) HasRole(role string) bool {
+	return c != nil && slices.Contains(c.Roles, role)
+}
+
+// HasPermission reports if the claims include the permission. It is safe to
+// call on nil claims.
+func (c *Claims) HasPermission(permission string) bool {
+	return c != nil && slices.Contains(c.Permissions, permission)
+}
+
+var _ sql.Scanner = (*Claims)(nil)
+var _ driver.Valuer = (*Claims)(nil)
+
+// Scan implements sql.Scanner so claims can be stored in a json column.
+func (c *Claims) Scan(src any) error {
+	return jsoncolumn.Scan(c, src)
+}
+
+// Value implements driver.Valuer so claims can be stored in a json column.
+func (c *Claims) Value() (driver.Value, error) {
+	return jsoncolumn.Value(c)
+}
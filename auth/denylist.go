@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/abibby/salusa/database/cache"
+)
+
+var ErrTokenRevoked = errors.New("token revoked")
+
+// Denylist stores the ids of access tokens that were revoked before they
+// expired.
+type Denylist interface {
+	Deny(ctx context.Context, jti string, expires time.Time) error
+	Denied(ctx context.Context, jti string) (bool, error)
+}
+
+var (
+	denylistMtx sync.RWMutex
+	denylist    Denylist
+)
+
+// SetDenylist sets the denylist checked when authenticating access tokens.
+// Without a denylist access tokens stay valid until they expire, even after
+// logging out.
+func SetDenylist(d Denylist) {
+	denylistMtx.Lock()
+	defer denylistMtx.Unlock()
+	denylist = d
+}
+
+func getDenylist() Denylist {
+	denylistMtx.RLock()
+	defer denylistMtx.RUnlock()
+	return denylist
+}
+
+// CacheDenylist is a Denylist that keeps revoked token ids in a cache store
+// until the tokens expire.
+type CacheDenylist struct {
+	store cache.Store
+}
+
+var _ Denylist = (*CacheDenylist)(nil)
+
+func NewCacheDenylist(store cache.Store) *CacheDenylist {
+	return &CacheDenylist{store: store}
+}
+
+func (d *CacheDenylist) Deny(ctx context.Context, jti string, expires time.Time) error {
+	ttl := time.Until(expires)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.store.Set(ctx, "auth:denied:"+jti, []byte{1}, ttl, nil)
+}
+
+func (d *CacheDenylist) Denied(ctx context.Context, jti string) (bool, error) {
+	_, ok, err := d.store.Get(ctx, "auth:denied:"+jti)
+	return ok, err
+}
+
+func denyAccessToken(ctx context.Context, claims *Claims) error {
+	d := getDenylist()
+	if d == nil || claims == nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	return d.Deny(ctx, claims.ID, claims.ExpiresAt.Time)
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/abibby/salusa/openapidoc"
+	"github.com/abibby/salusa/request"
+	"github.com/abibby/salusa/router"
+	"github.com/go-openapi/spec"
+	"github.com/stoewer/go-strcase"
+)
+
+var (
+	ErrForbidden        = errors.New("forbidden")
+	ErrNoPolicy         = errors.New("no policy registered")
+	ErrUndefinedAbility = errors.New("undefined ability")
+)
+
+// Policy authorizes abilities on models of type T. Authorize calls the
+// method matching the ability, so "update" calls Update. Policies can add
+// more abilities with methods that have the same signature.
+type Policy[T any] interface {
+	View(ctx context.Context, claims *Claims, model T) bool
+	Update(ctx context.Context, claims *Claims, model T) bool
+	Delete(ctx context.Context, claims *Claims, model T) bool
+}
+
+type gate struct {
+	mtx       sync.RWMutex
+	policies  map[reflect.Type]reflect.Value
+	abilities map[string]func(ctx context.Context, claims *Claims) bool
+}
+
+var defaultGate = &gate{
+	policies:  map[reflect.Type]reflect.Value{},
+	abilities: map[string]func(ctx context.Context, claims *Claims) bool{},
+}
+
+// RegisterPolicy sets the policy used to authorize abilities on models of
+// type T.
+func RegisterPolicy[T any](policy Policy[T]) {
+	defaultGate.mtx.Lock()
+	defer defaultGate.mtx.Unlock()
+	defaultGate.policies[reflect.TypeFor[T]()] = reflect.ValueOf(policy)
+}
+
+// Define adds an ability that is not tied to a model. Check it by passing a
+// nil model to Authorize.
+func Define(ability string, cb func(ctx context.Context, claims *Claims) bool) {
+	defaultGate.mtx.Lock()
+	defer defaultGate.mtx.Unlock()
+	defaultGate.abilities[ability] = cb
+}
+
+// Allows reports if the user in ctx has the ability on model. Pass a nil
+// model for abilities added with Define.
+func Allows(ctx context.Context, ability string, model any) (bool, error) {
+	claims, _ := GetClaimsCtx(ctx)
+
+	if model == nil {
+		defaultGate.mtx.RLock()
+		cb, ok := defaultGate.abilities[ability]
+		defaultGate.mtx.RUnlock()
+		if !ok {
+			return false, fmt.Errorf("%w %q", ErrUndefinedAbility, ability)
+		}
+		return cb(ctx, claims), nil
+	}
+
+	t := reflect.TypeOf(model)
+	defaultGate.mtx.RLock()
+	policy, ok := defaultGate.policies[t]
+	defaultGate.mtx.RUnlock()
+	if !ok {
+		return false, fmt.Errorf("%w for %s", ErrNoPolicy, t)
+	}
+	method := policy.MethodByName(strcase.UpperCamelCase(ability))
+	if !method.IsValid() || !isAbilityMethod(method.Type(), t) {
+		return false, fmt.Errorf("%w %q on %s", ErrUndefinedAbility, ability, policy.Type())
+	}
+
+	out := method.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(claims), reflect.ValueOf(model)})
+	return out[0].Bool(), nil
+}
+
+func isAbilityMethod(m reflect.Type, model reflect.Type) bool {
+	return m.NumIn() == 3 &&
+		m.In(0) == reflect.TypeFor[context.Context]() &&
+		m.In(1) == reflect.TypeFor[*Claims]() &&
+		m.In(2) == model &&
+		m.NumOut() == 1 &&
+		m.Out(0).Kind() == reflect.Bool
+}
+
+// Authorize returns a 403 error wrapping ErrForbidden if the user in ctx does
+// not have the ability on model.
+func Authorize(ctx context.Context, ability string, model any) error {
+	ok, err := Allows(ctx, ability, model)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return request.NewHTTPError(fmt.Errorf("%w: %s", ErrForbidden, ability), http.StatusForbidden)
+	}
+	return nil
+}
+
+type CanMiddleware struct {
+	ability                string
+	model                  func(r *http.Request) (any, error)
+	securityDefinitionName string
+}
+
+var _ router.Middleware = (*CanMiddleware)(nil)
+var _ openapidoc.OperationMiddleware = (*CanMiddleware)(nil)
+
+// Can rejects requests from users without the ability.
+func Can(ability string) *CanMiddleware {
+	return &CanMiddleware{
+		ability:                ability,
+		securityDefinitionName: openapidoc.DefaultSecurityDefinitionName,
+	}
+}
+
+// Model sets the function that loads the model the ability is checked
+// against, e.g. from a path parameter. Without it the ability must be added
+// with Define.
+func (m *CanMiddleware) Model(model func(r *http.Request) (any, error)) *CanMiddleware {
+	m.model = model
+	return m
+}
+func (m *CanMiddleware) SecurityDefinition(name string) *CanMiddleware {
+	m.securityDefinitionName = name
+	return m
+}
+func (m *CanMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var model any
+		if m.model != nil {
+			var err error
+			model, err = m.model(r)
+			if err != nil {
+				request.RespondError(w, r, err)
+				return
+			}
+		}
+
+		err := Authorize(r.Context(), m.ability, model)
+		if err != nil {
+			request.RespondError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+func (m *CanMiddleware) OperationMiddleware(s *spec.Operation) *spec.Operation {
+	return documentRequirement(s, m.securityDefinitionName, "ability:"+m.ability)
+}
+
+// documentRequirement adds a security requirement listing the claims needed
+// to call the operation.
+func documentRequirement(s *spec.Operation, securityDefinitionName string, requirements ...string) *spec.Operation {
+	if securityDefinitionName == "" {
+		return s
+	}
+	if s.Security == nil {
+		s.Security = []map[string][]string{}
+	}
+	if requirements == nil {
+		requirements = []string{}
+	}
+	s.Security = append(s.Security, map[string][]string{
+		securityDefinitionName: requirements,
+	})
+	if s.Responses == nil {
+		s.Responses = &spec.Responses{}
+	}
+	if s.Responses.StatusCodeResponses == nil {
+		s.Responses.StatusCodeResponses = map[int]spec.Response{}
+	}
+	if len(requirements) > 0 {
+		s.Responses.StatusCodeResponses[http.StatusForbidden] = *spec.NewResponse().WithDescription("Forbidden")
+	}
+	return s
+}
@@ -0,0 +1,166 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/router"
+	"github.com/go-openapi/spec"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Post struct {
+	AuthorID string
+}
+
+type PostPolicy struct{}
+
+var _ auth.Policy[*Post] = PostPolicy{}
+
+func (PostPolicy) View(ctx context.Context, claims *auth.Claims, p *Post) bool {
+	return true
+}
+func (PostPolicy) Update(ctx context.Context, claims *auth.Claims, p *Post) bool {
+	return claims != nil && claims.Subject == p.AuthorID
+}
+func (PostPolicy) Delete(ctx context.Context, claims *auth.Claims, p *Post) bool {
+	return claims.HasRole("admin")
+}
+func (PostPolicy) ForcePublish(ctx context.Context, claims *auth.Claims, p *Post) bool {
+	return claims.HasPermission("posts.publish")
+}
+
+func init() {
+	auth.RegisterPolicy[*Post](PostPolicy{})
+	auth.Define("view-dashboard", func(ctx context.Context, claims *auth.Claims) bool {
+		return claims.HasRole("admin")
+	})
+}
+
+func TestAuthorize(t *testing.T) {
+	post := &Post{AuthorID: "author"}
+	guest := context.Background()
+	author := auth.WithClaims(context.Background(), auth.NewClaims().WithSubject("author"))
+	admin := auth.WithClaims(context.Background(), auth.NewClaims().
+		WithSubject("admin").
+		WithRoles("admin").
+		WithPermissions("posts.publish"))
+
+	assert.NoError(t, auth.Authorize(guest, "view", post))
+	assert.ErrorIs(t, auth.Authorize(guest, "update", post), auth.ErrForbidden)
+	assert.NoError(t, auth.Authorize(author, "update", post))
+	assert.ErrorIs(t, auth.Authorize(admin, "update", post), auth.ErrForbidden)
+
+	assert.ErrorIs(t, auth.Authorize(author, "delete", post), auth.ErrForbidden)
+	assert.NoError(t, auth.Authorize(admin, "delete", post))
+
+	assert.ErrorIs(t, auth.Authorize(author, "force-publish", post), auth.ErrForbidden)
+	assert.NoError(t, auth.Authorize(admin, "force-publish", post))
+
+	assert.ErrorIs(t, auth.Authorize(author, "view-dashboard", nil), auth.ErrForbidden)
+	assert.NoError(t, auth.Authorize(admin, "view-dashboard", nil))
+
+	assert.ErrorIs(t, auth.Authorize(admin, "archive", post), auth.ErrUndefinedAbility)
+	assert.ErrorIs(t, auth.Authorize(admin, "archive", nil), auth.ErrUndefinedAbility)
+	assert.ErrorIs(t, auth.Authorize(admin, "view", &AutoIncrementUser{}), auth.ErrNoPolicy)
+}
+
+func TestCanMiddleware(t *testing.T) {
+	post := &Post{AuthorID: "author"}
+	r := router.New()
+	r.Use(auth.AttachUser())
+	r.Group("", func(r *router.Router) {
+		r.Use(auth.Can("update").Model(func(r *http.Request) (any, error) {
+			return post, nil
+		}))
+		r.Get("/post", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	})
+
+	request := func(subject string) int {
+		req := httptest.NewRequest(http.MethodGet, "/post", http.NoBody)
+		token, err := auth.GenerateToken(auth.NewClaims().WithSubject(subject).WithScopes(auth.ScopeAccess))
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("author"))
+	assert.Equal(t, http.StatusForbidden, request("other"))
+}
+
+func TestHasRole(t *testing.T) {
+	h := auth.HasRole("admin", "editor").Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(claims *auth.Claims) int {
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req = req.WithContext(auth.WithClaims(req.Context(), claims))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request(auth.NewClaims().WithRoles("editor")))
+	assert.Equal(t, http.StatusUnauthorized, request(auth.NewClaims().WithRoles("viewer")))
+	assert.Equal(t, http.StatusUnauthorized, request(nil))
+
+	op := auth.HasPermission("posts.publish").OperationMiddleware(&spec.Operation{})
+	assert.Equal(t, []map[string][]string{{"Bearer": {"permission:posts.publish"}}}, op.Security)
+}
+
+func TestRoleClaims(t *testing.T) {
+	routes := auth.NewBasicAuthController[*auth.UsernameUser](auth.RoleClaims())
+
+	Run(t, "", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		u := &auth.UsernameUser{
+			ID:       uuid.New(),
+			Username: "user",
+		}
+		hash, err := bcrypt.GenerateFromPassword(u.SaltedPassword("pass"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		u.PasswordHash = hash
+		assert.NoError(t, model.Save(tx, u))
+
+		_, err = auth.CreateRole(ctx, tx, "editor", "posts.update", "posts.publish")
+		assert.NoError(t, err)
+		_, err = auth.CreateRole(ctx, tx, "author", "posts.update")
+		assert.NoError(t, err)
+		_, err = auth.CreateRole(ctx, tx, "admin", "users.delete")
+		assert.NoError(t, err)
+
+		assert.NoError(t, auth.AssignRole(ctx, tx, u.GetID(), "editor"))
+		assert.NoError(t, auth.AssignRole(ctx, tx, u.GetID(), "author"))
+		assert.ErrorIs(t, auth.AssignRole(ctx, tx, u.GetID(), "missing"), auth.ErrUnknownRole)
+
+		resp, err := routes.RunLogin(&auth.LoginRequest{
+			Username: "user",
+			Password: "pass",
+			Read:     dbtest.Read(tx),
+			Update:   dbtest.Update(tx),
+			Ctx:      ctx,
+			Log:      nullLogger,
+		})
+		assert.NoError(t, err)
+
+		claims, err := auth.Parse(resp.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"author", "editor"}, claims.Roles)
+		assert.Equal(t, []string{"posts.publish", "posts.update"}, claims.Permissions)
+
+		assert.NoError(t, auth.RemoveRole(ctx, tx, u.GetID(), "editor"))
+		roles, permissions, err := auth.UserRoles(ctx, tx, u.GetID())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"author"}, roles)
+		assert.Equal(t, []string{"posts.update"}, permissions)
+	})
+}
@@ -4,6 +4,14 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// GenerateToken signs claims with the key from SetSigningKey, or the app key
+// if no signing key is set.
 func GenerateToken(claims jwt.Claims) (string, error) {
-	return jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString(getAppKey())
+	key := getSigningKey()
+	if key == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString(getAppKey())
+	}
+	t := jwt.NewWithClaims(key.Method, claims)
+	t.Header["kid"] = key.ID
+	return t.SignedString(key.key)
 }
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrNoCredentials is returned by guards when the request has no credentials
+// for them to check.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Guard authenticates requests. AttachUser tries each of its guards in turn.
+type Guard interface {
+	// Authenticate returns the claims of the request. It returns an error
+	// wrapping ErrNoCredentials if the request has no credentials for the
+	// guard so the next guard can be tried.
+	Authenticate(r *http.Request) (*Claims, error)
+}
+
+// BearerGuard authenticates requests with an access token in the
+// Authorization header.
+type BearerGuard struct{}
+
+var _ Guard = (*BearerGuard)(nil)
+
+func NewBearerGuard() *BearerGuard {
+	return &BearerGuard{}
+}
+
+func (g *BearerGuard) Authenticate(r *http.Request) (*Claims, error) {
+	return authenticate(r)
+}
+
+func authenticateGuards(r *http.Request, guards []Guard) (*Claims, error) {
+	errs := []error{}
+	for _, g := range guards {
+		claims, err := g.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return claims, nil
+	}
+	if len(errs) == 0 {
+		return nil, ErrNoCredentials
+	}
+	return nil, errors.Join(errs...)
+}
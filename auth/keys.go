@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/abibby/salusa/request"
+	"github.com/go-openapi/spec"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrInvalidPEM = errors.New("no PEM block found")
+	ErrUnknownKID = errors.New("unknown key id")
+)
+
+// SigningKey is a private key used to sign tokens. Tokens are signed with
+// RS256, ES256, ES384, ES512 or EdDSA depending on the key type and carry the
+// key id in the kid header.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+// VerificationKey is a public key accepted when parsing tokens.
+type VerificationKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	Key    crypto.PublicKey
+}
+
+// KeyFile is the path of a PEM encoded key in the filesystem and its key id.
+type KeyFile struct {
+	ID   string
+	Path string
+}
+
+// SigningKeyConfiger is implemented by configs that load token keys from the
+// filesystem config. SigningKeyFile is the private key new tokens are signed
+// with and VerificationKeyFiles are public or private keys that are still
+// accepted, e.g. the previous signing key during a rotation.
+type SigningKeyConfiger interface {
+	SigningKeyFile() KeyFile
+	VerificationKeyFiles() []KeyFile
+}
+
+type keyRing struct {
+	mtx          sync.RWMutex
+	signing      *SigningKey
+	keys         map[string]*VerificationKey
+	acceptAppKey bool
+}
+
+var keys = &keyRing{
+	keys: map[string]*VerificationKey{},
+}
+
+// NewSigningKey creates a signing key from an RSA, ECDSA or Ed25519 private
+// key.
+func NewSigningKey(id string, key crypto.Signer) (*SigningKey, error) {
+	method, err := signingMethod(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		ID:     id,
+		Method: method,
+		key:    key,
+	}, nil
+}
+
+// NewVerificationKey creates a verification key from an RSA, ECDSA or Ed25519
+// public key.
+func NewVerificationKey(id string, key crypto.PublicKey) (*VerificationKey, error) {
+	method, err := signingMethod(key)
+	if err != nil {
+		return nil, err
+	}
+	return &VerificationKey{
+		ID:     id,
+		Method: method,
+		Key:    key,
+	}, nil
+}
+
+// VerificationKey returns the public half of the signing key.
+func (k *SigningKey) VerificationKey() *VerificationKey {
+	return &VerificationKey{
+		ID:     k.ID,
+		Method: k.Method,
+		Key:    k.key.Public(),
+	}
+}
+
+func signingMethod(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return jwt.SigningMethodES256, nil
+		case 384:
+			return jwt.SigningMethodES384, nil
+		case 521:
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("%w %T", ErrUnsupportedKey, key)
+	}
+}
+
+// ParseSigningKey parses a PEM encoded PKCS #8, PKCS #1 or SEC 1 private key.
+func ParseSigningKey(id string, b []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w %T", ErrUnsupportedKey, key)
+	}
+	return NewSigningKey(id, signer)
+}
+
+// ParseVerificationKey parses a PEM encoded public key. Private keys are also
+// accepted so an old signing key can be kept for verification.
+func ParseVerificationKey(id string, b []byte) (*VerificationKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		signing, err := ParseSigningKey(id, b)
+		if err != nil {
+			return nil, err
+		}
+		return signing.VerificationKey(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return NewVerificationKey(id, key)
+}
+
+// LoadSigningKey reads a signing key from a PEM file in fsys.
+func LoadSigningKey(fsys fs.FS, file KeyFile) (*SigningKey, error) {
+	b, err := fs.ReadFile(fsys, file.Path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSigningKey(file.ID, b)
+}
+
+// LoadVerificationKey reads a verification key from a PEM file in fsys.
+func LoadVerificationKey(fsys fs.FS, file KeyFile) (*VerificationKey, error) {
+	b, err := fs.ReadFile(fsys, file.Path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVerificationKey(file.ID, b)
+}
+
+// SetSigningKey sets the key new tokens are signed with. Passing nil goes back
+// to signing with the HMAC app key. Once a signing key is set tokens signed
+// with the app key are rejected unless SetAcceptAppKey is used.
+//
+// The previous signing key is kept as a verification key so tokens signed
+// with it stay valid and it stays in the JWKS. Remove it with
+// SetVerificationKeys once those tokens have expired.
+func SetSigningKey(key *SigningKey) {
+	keys.mtx.Lock()
+	defer keys.mtx.Unlock()
+	keys.signing = key
+	if key != nil {
+		keys.keys[key.ID] = key.VerificationKey()
+	}
+}
+
+// SetAcceptAppKey keeps accepting tokens signed with the HMAC app key after a
+// signing key is set, e.g. until the tokens issued before the switch have
+// expired. Anyone with the app key can sign these tokens, so turn it off once
+// they are no longer needed.
+func SetAcceptAppKey(accept bool) {
+	keys.mtx.Lock()
+	defer keys.mtx.Unlock()
+	keys.acceptAppKey = accept
+}
+
+// SetVerificationKeys sets the keys, other than the signing key, that are
+// accepted when parsing tokens.
+func SetVerificationKeys(verificationKeys ...*VerificationKey) {
+	keys.mtx.Lock()
+	defer keys.mtx.Unlock()
+	keys.keys = make(map[string]*VerificationKey, len(verificationKeys)+1)
+	for _, k := range verificationKeys {
+		keys.keys[k.ID] = k
+	}
+	if keys.signing != nil {
+		keys.keys[keys.signing.ID] = keys.signing.VerificationKey()
+	}
+}
+
+func getSigningKey() *SigningKey {
+	keys.mtx.RLock()
+	defer keys.mtx.RUnlock()
+	return keys.signing
+}
+
+func getVerificationKey(kid string) (*VerificationKey, bool) {
+	keys.mtx.RLock()
+	defer keys.mtx.RUnlock()
+	k, ok := keys.keys[kid]
+	return k, ok
+}
+
+// acceptsAppKey reports if tokens signed with the HMAC app key are accepted.
+func acceptsAppKey() bool {
+	keys.mtx.RLock()
+	defer keys.mtx.RUnlock()
+	return keys.signing == nil || keys.acceptAppKey
+}
+
+// keyFunc returns the key to verify t with. HMAC tokens use the app key, if
+// they are accepted, and other tokens use the verification key with the
+// matching kid and algorithm.
+func keyFunc(t *jwt.Token) (any, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		if !acceptsAppKey() {
+			return nil, fmt.Errorf("tokens signed with the app key are not accepted: %w", ErrUnexpectedAlgorithm)
+		}
+		return getAppKey(), nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	k, ok := getVerificationKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKID, kid)
+	}
+	if k.Method.Alg() != t.Method.Alg() {
+		return nil, fmt.Errorf("expected %s received %v: %w", k.Method.Alg(), t.Header["alg"], ErrUnexpectedAlgorithm)
+	}
+	return k.Key, nil
+}
+
+// GetJWKS returns the public keys tokens can be verified with.
+func GetJWKS() (*JSONWebKeySet, error) {
+	keys.mtx.RLock()
+	defer keys.mtx.RUnlock()
+	set := &JSONWebKeySet{
+		Keys: make([]*JSONWebKey, 0, len(keys.keys)),
+	}
+	for _, k := range keys.keys {
+		jwk, err := NewJSONWebKey(k.ID, k.Method.Alg(), k.Key)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	slices.SortFunc(set.Keys, func(a, b *JSONWebKey) int {
+		return strings.Compare(a.Kid, b.Kid)
+	})
+	return set, nil
+}
+
+type JWKSRequest struct{}
+
+// JWKS serves the public keys so other services can verify tokens. Register
+// it at /.well-known/jwks.json.
+func JWKS() http.Handler {
+	return request.Handler(func(r *JWKSRequest) (*JSONWebKeySet, error) {
+		return GetJWKS()
+	}).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
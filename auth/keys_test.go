@@ -0,0 +1,162 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustSigningKey(t *testing.T, id string, key crypto.Signer) *auth.SigningKey {
+	k, err := auth.NewSigningKey(id, key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return k
+}
+
+func TestSigningKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		Name string
+		Key  crypto.Signer
+		Alg  string
+	}{
+		{"rsa", rsaKey, "RS256"},
+		{"ecdsa", ecKey, "ES256"},
+		{"ed25519", edKey, "EdDSA"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			defer auth.SetVerificationKeys()
+			defer auth.SetSigningKey(nil)
+			auth.SetSigningKey(mustSigningKey(t, tc.Name, tc.Key))
+
+			token, err := auth.GenerateToken(auth.NewClaims().WithSubject("user"))
+			assert.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Alg, parsed.Method.Alg())
+			assert.Equal(t, tc.Name, parsed.Header["kid"])
+
+			claims, err := auth.Parse(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "user", claims.Subject)
+
+			set, err := auth.GetJWKS()
+			assert.NoError(t, err)
+			if assert.Len(t, set.Keys, 1) {
+				assert.Equal(t, tc.Name, set.Keys[0].Kid)
+				assert.Equal(t, tc.Alg, set.Keys[0].Alg)
+				pub, err := set.Keys[0].PublicKey()
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Key.Public(), pub)
+			}
+		})
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	defer auth.SetVerificationKeys()
+	defer auth.SetSigningKey(nil)
+
+	hmacToken, err := auth.GenerateToken(auth.NewClaims().WithSubject("hmac"))
+	assert.NoError(t, err)
+
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	old := mustSigningKey(t, "old", oldKey)
+	auth.SetSigningKey(old)
+	oldToken, err := auth.GenerateToken(auth.NewClaims().WithSubject("old"))
+	assert.NoError(t, err)
+
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	auth.SetSigningKey(mustSigningKey(t, "new", newKey))
+
+	claims, err := auth.Parse(oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", claims.Subject)
+
+	auth.SetVerificationKeys()
+	_, err = auth.Parse(oldToken)
+	assert.ErrorIs(t, err, auth.ErrUnknownKID)
+
+	auth.SetVerificationKeys(old.VerificationKey())
+	claims, err = auth.Parse(oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", claims.Subject)
+
+	_, err = auth.Parse(hmacToken)
+	assert.ErrorIs(t, err, auth.ErrUnexpectedAlgorithm)
+
+	auth.SetAcceptAppKey(true)
+	claims, err = auth.Parse(hmacToken)
+	auth.SetAcceptAppKey(false)
+	assert.NoError(t, err)
+	assert.Equal(t, "hmac", claims.Subject)
+
+	w := httptest.NewRecorder()
+	auth.JWKS().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", http.NoBody))
+	set := &auth.JSONWebKeySet{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), set))
+	kids := []string{}
+	for _, k := range set.Keys {
+		kids = append(kids, k.Kid)
+	}
+	assert.Equal(t, []string{"new", "old"}, kids)
+}
+
+func TestLoadKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	assert.NoError(t, err)
+	pkix, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	assert.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"keys/rsa.pem":     {Data: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})},
+		"keys/rsa.pub.pem": {Data: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})},
+		"keys/ec.pem":      {Data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})},
+		"keys/invalid.pem": {Data: []byte("not a key")},
+	}
+
+	signing, err := auth.LoadSigningKey(fsys, auth.KeyFile{ID: "rsa", Path: "keys/rsa.pem"})
+	assert.NoError(t, err)
+	assert.Equal(t, "RS256", signing.Method.Alg())
+
+	verification, err := auth.LoadVerificationKey(fsys, auth.KeyFile{ID: "rsa", Path: "keys/rsa.pub.pem"})
+	assert.NoError(t, err)
+	assert.Equal(t, signing.VerificationKey(), verification)
+
+	signing, err = auth.LoadSigningKey(fsys, auth.KeyFile{ID: "ec", Path: "keys/ec.pem"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ES384", signing.Method.Alg())
+
+	_, err = auth.LoadSigningKey(fsys, auth.KeyFile{ID: "invalid", Path: "keys/invalid.pem"})
+	assert.ErrorIs(t, err, auth.ErrInvalidPEM)
+}
@@ -0,0 +1,221 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid id token")
+	ErrUnknownKey     = errors.New("unknown signing key")
+)
+
+// discoveryDocument is the subset of the OpenID Connect discovery document
+// used to configure a provider.
+type discoveryDocument struct {
+	Issuer      string `json:"issuer"`
+	AuthURL     string `json:"authorization_endpoint"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"`
+	JWKSURL     string `json:"jwks_uri"`
+}
+
+// Discover creates an OpenID Connect provider from the discovery document
+// at issuer/.well-known/openid-configuration.
+func Discover(ctx context.Context, name, issuer string, config *oauth2.Config) (*Provider, error) {
+	return DiscoverWithClient(ctx, http.DefaultClient, name, issuer, config)
+}
+
+// DiscoverWithClient is Discover using client for requests to the provider.
+func DiscoverWithClient(ctx context.Context, client *http.Client, name, issuer string, config *oauth2.Config) (*Provider, error) {
+	u := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	doc := &discoveryDocument{}
+	err := getJSON(ctx, client, u, doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover %s: %w", issuer, err)
+	}
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", doc.Issuer, issuer)
+	}
+
+	config.Endpoint = oauth2.Endpoint{
+		AuthURL:  doc.AuthURL,
+		TokenURL: doc.TokenURL,
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		Name:        name,
+		Config:      config,
+		Verifier:    NewIDTokenVerifier(client, doc.Issuer, config.ClientID, doc.JWKSURL),
+		UserInfoURL: doc.UserInfoURL,
+		HTTPClient:  client,
+	}, nil
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token.
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+func (c *IDTokenClaims) identity() *Identity {
+	return &Identity{
+		Subject:       c.Subject,
+		Email:         c.Email,
+		EmailVerified: c.EmailVerified,
+		Name:          c.Name,
+	}
+}
+
+// DefaultJWKSRefreshInterval is the minimum time between key set fetches
+// triggered by unknown key ids.
+const DefaultJWKSRefreshInterval = time.Minute
+
+// IDTokenVerifier verifies ID tokens signed with the keys published by an
+// OpenID Connect provider.
+type IDTokenVerifier struct {
+	client          *http.Client
+	issuer          string
+	clientID        string
+	jwksURL         string
+	refreshInterval time.Duration
+
+	mtx     sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+func NewIDTokenVerifier(client *http.Client, issuer, clientID, jwksURL string) *IDTokenVerifier {
+	return &IDTokenVerifier{
+		client:          client,
+		issuer:          issuer,
+		clientID:        clientID,
+		jwksURL:         jwksURL,
+		refreshInterval: DefaultJWKSRefreshInterval,
+		keys:            map[string]crypto.PublicKey{},
+	}
+}
+
+// WithRefreshInterval sets the minimum time between key set fetches. Tokens
+// with an unknown key id are rejected without a fetch until it has passed.
+func (v *IDTokenVerifier) WithRefreshInterval(d time.Duration) *IDTokenVerifier {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.refreshInterval = d
+	return v
+}
+
+// Verify checks the signature, issuer, audience, expiry and nonce of an ID
+// token.
+func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}))
+	_, err := parser.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	if claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+	if !claims.VerifyAudience(v.clientID, true) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	}
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: missing expiry", ErrInvalidIDToken)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// key returns the public key with the kid, refreshing the key set if the key
+// is unknown to pick up rotated keys. The key set is fetched at most once per
+// refresh interval so tokens with made up key ids can't flood the provider.
+func (v *IDTokenVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+
+	now := time.Now()
+	if !v.fetched.IsZero() && now.Sub(v.fetched) < v.refreshInterval {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	v.fetched = now
+
+	keys, err := fetchKeySet(ctx, v.client, v.jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+func fetchKeySet(ctx context.Context, client *http.Client, jwksURL string) (map[string]crypto.PublicKey, error) {
+	set := &auth.JSONWebKeySet{}
+	err := getJSON(ctx, client, jwksURL, set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key set: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.PublicKey()
+		if errors.Is(err, auth.ErrUnsupportedKey) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,155 @@
+// Package oauth implements social login with OAuth2 and OpenID Connect
+// providers using the authorization code flow with PKCE.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrNoSubject       = errors.New("provider did not return a subject")
+	ErrUnknownProvider = errors.New("unknown oauth provider")
+)
+
+// Identity is a user as described by a provider.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is an OAuth2 or OpenID Connect identity provider.
+type Provider struct {
+	Name   string
+	Config *oauth2.Config
+
+	// Verifier verifies ID tokens from OpenID Connect providers. When it is
+	// set the identity is read from the ID token instead of UserInfoURL.
+	Verifier *IDTokenVerifier
+	// UserInfoURL is fetched with the access token to find the identity of
+	// plain OAuth2 providers.
+	UserInfoURL string
+	// HTTPClient is used for requests to the provider. It defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewProvider creates an OAuth2 provider that reads the identity from a user
+// info endpoint.
+func NewProvider(name string, config *oauth2.Config, userInfoURL string) *Provider {
+	return &Provider{
+		Name:        name,
+		Config:      config,
+		UserInfoURL: userInfoURL,
+	}
+}
+
+// GitHub creates a provider for GitHub OAuth apps.
+func GitHub(clientID, clientSecret, redirectURL string) *Provider {
+	return NewProvider("github", &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"read:user", "user:email"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://github.com/login/oauth/authorize",
+			TokenURL: "https://github.com/login/oauth/access_token",
+		},
+	}, "https://api.github.com/user")
+}
+
+func (p *Provider) client() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// Exchange trades an authorization code for the identity of the user.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client())
+	token, err := p.Config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	var identity *Identity
+	if p.Verifier != nil {
+		rawIDToken, ok := token.Extra("id_token").(string)
+		if !ok {
+			return nil, fmt.Errorf("token response has no id_token: %w", ErrInvalidIDToken)
+		}
+		claims, err := p.Verifier.Verify(ctx, rawIDToken, nonce)
+		if err != nil {
+			return nil, err
+		}
+		identity = claims.identity()
+	} else {
+		identity, err = p.userInfo(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if identity.Subject == "" {
+		return nil, ErrNoSubject
+	}
+	identity.Provider = p.Name
+	return identity, nil
+}
+
+func (p *Provider) userInfo(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.UserInfoURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	token.SetAuthHeader(req)
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user info: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch user info: %s", resp.Status)
+	}
+
+	info := &userInfo{}
+	err = json.NewDecoder(resp.Body).Decode(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode user info: %w", err)
+	}
+	return info.identity(), nil
+}
+
+// userInfo holds the fields shared by OpenID Connect user info responses and
+// common OAuth2 providers. GitHub uses a numeric id in place of sub.
+type userInfo struct {
+	Subject       string          `json:"sub"`
+	ID            json.RawMessage `json:"id"`
+	Email         string          `json:"email"`
+	EmailVerified bool            `json:"email_verified"`
+	Name          string          `json:"name"`
+}
+
+func (i *userInfo) identity() *Identity {
+	subject := i.Subject
+	if subject == "" && len(i.ID) > 0 && json.Unmarshal(i.ID, &subject) != nil {
+		subject = string(i.ID)
+	}
+	return &Identity{
+		Subject:       subject,
+		Email:         i.Email,
+		EmailVerified: i.EmailVerified,
+		Name:          i.Name,
+	}
+}
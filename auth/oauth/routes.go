@@ -0,0 +1,294 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/request"
+	"github.com/abibby/salusa/router"
+	"github.com/go-openapi/spec"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrStateMismatch       = errors.New("oauth state mismatch")
+	ErrAuthorizationDenied = errors.New("authorization denied")
+	ErrAccountNotLinked    = errors.New("no account is linked to this identity")
+)
+
+const (
+	stateCookieName = "oauth_state"
+	stateLifetime   = 10 * time.Minute
+)
+
+// ResolveUser returns the user to link an identity to the first time it is
+// used to log in. It can find an existing user or create a new one. Returning
+// a nil user rejects the login with ErrAccountNotLinked.
+//
+// Only trust identity.Email to match existing users when
+// identity.EmailVerified is true.
+type ResolveUser[T auth.User] func(ctx context.Context, tx database.DB, identity *Identity) (T, error)
+
+type Controller interface {
+	Redirect() http.Handler
+	Callback() http.Handler
+}
+
+// BasicController logs users in with OAuth2 providers and issues tokens with
+// a BasicAuthController.
+type BasicController[T auth.User] struct {
+	auth        *auth.BasicAuthController[T]
+	resolveUser ResolveUser[T]
+	providers   map[string]*Provider
+	secure      bool
+}
+
+var _ Controller = (*BasicController[auth.User])(nil)
+
+func NewBasicController[T auth.User](authController *auth.BasicAuthController[T], resolveUser ResolveUser[T], providers ...*Provider) *BasicController[T] {
+	c := &BasicController[T]{
+		auth:        authController,
+		resolveUser: resolveUser,
+		providers:   make(map[string]*Provider, len(providers)),
+		secure:      true,
+	}
+	for _, p := range providers {
+		c.providers[p.Name] = p
+	}
+	return c
+}
+
+// Secure sets the Secure flag of the state cookie. It should only be disabled
+// for local development over http.
+func (c *BasicController[T]) Secure(secure bool) *BasicController[T] {
+	c.secure = secure
+	return c
+}
+
+func RegisterRoutes(r *router.Router, controller Controller) {
+	r.Get("/oauth/{provider}/redirect", controller.Redirect()).Name("auth.oauth.redirect")
+	r.Get("/oauth/{provider}/callback", controller.Callback()).Name("auth.oauth.callback")
+}
+
+// stateClaims are stored in a cookie between the redirect to the provider
+// and the callback.
+type stateClaims struct {
+	jwt.RegisteredClaims
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce,omitempty"`
+}
+
+func (c *BasicController[T]) provider(name string) (*Provider, error) {
+	p, ok := c.providers[name]
+	if !ok {
+		return nil, request.NewHTTPError(fmt.Errorf("%w %q", ErrUnknownProvider, name), http.StatusNotFound)
+	}
+	return p, nil
+}
+
+type RedirectRequest struct {
+	Provider string              `path:"provider"`
+	Response http.ResponseWriter `inject:""`
+}
+
+func (c *BasicController[T]) Redirect() http.Handler {
+	return request.Handler(c.RunRedirect).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunRedirect sends the user to the providers consent page.
+func (c *BasicController[T]) RunRedirect(r *RedirectRequest) (http.Handler, error) {
+	p, err := c.provider(r.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	claims := &stateClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        state,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(stateLifetime)),
+		},
+		Provider: p.Name,
+		Verifier: oauth2.GenerateVerifier(),
+	}
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(claims.Verifier)}
+	if p.Verifier != nil {
+		claims.Nonce, err = randomString()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", claims.Nonce))
+	}
+
+	cookie, err := auth.GenerateToken(claims)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate state: %w", err)
+	}
+	http.SetCookie(r.Response, &http.Cookie{
+		Name:     stateCookieName,
+		Value:    cookie,
+		Path:     "/",
+		MaxAge:   int(stateLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   c.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return http.RedirectHandler(p.Config.AuthCodeURL(state, opts...), http.StatusFound), nil
+}
+
+type CallbackRequest struct {
+	Provider         string `path:"provider"`
+	Code             string `query:"code"`
+	State            string `query:"state"`
+	Error            string `query:"error"`
+	ErrorDescription string `query:"error_description"`
+
+	Request  *http.Request       `inject:""`
+	Response http.ResponseWriter `inject:""`
+	Update   database.Update     `inject:""`
+	Ctx      context.Context     `inject:""`
+	Log      *slog.Logger        `inject:""`
+}
+
+func (c *BasicController[T]) Callback() http.Handler {
+	return request.Handler(c.RunCallback).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunCallback exchanges the authorization code from the provider for access
+// and refresh tokens. The first login with an identity links it to the user
+// from ResolveUser.
+func (c *BasicController[T]) RunCallback(r *CallbackRequest) (*auth.LoginResponse, error) {
+	p, err := c.provider(r.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(r.Response, &http.Cookie{
+		Name:     stateCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if r.Error != "" {
+		return nil, request.NewHTTPError(fmt.Errorf("%w: %s %s", ErrAuthorizationDenied, r.Error, r.ErrorDescription), http.StatusUnauthorized)
+	}
+
+	state, err := c.checkState(r.Request, p, r.State)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := p.Exchange(r.Ctx, r.Code, state.Verifier, state.Nonce)
+	if err != nil {
+		r.Log.Warn("oauth exchange failed", "provider", p.Name, "error", err)
+		return nil, request.NewHTTPError(err, http.StatusUnauthorized)
+	}
+
+	var resp *auth.LoginResponse
+	err = r.Update(func(tx *sqlx.Tx) error {
+		u, err := c.findUser(r.Ctx, tx, identity)
+		if err != nil {
+			return err
+		}
+		resp, err = c.auth.CompleteLogin(r.Ctx, tx, u)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *BasicController[T]) checkState(req *http.Request, p *Provider, state string) (*stateClaims, error) {
+	cookie, err := req.Cookie(stateCookieName)
+	if err != nil {
+		return nil, request.NewHTTPError(ErrStateMismatch, http.StatusBadRequest)
+	}
+	claims, err := auth.ParseOf[*stateClaims](cookie.Value)
+	if err != nil {
+		return nil, request.NewHTTPError(fmt.Errorf("%w: %w", ErrStateMismatch, err), http.StatusBadRequest)
+	}
+	if state == "" || claims.ID != state || claims.Provider != p.Name {
+		return nil, request.NewHTTPError(ErrStateMismatch, http.StatusBadRequest)
+	}
+	return claims, nil
+}
+
+// findUser returns the user linked to the identity, linking it to the user
+// from resolveUser if it is new.
+func (c *BasicController[T]) findUser(ctx context.Context, tx database.DB, identity *Identity) (T, error) {
+	var zero T
+	account, err := FindSocialAccount(ctx, tx, identity)
+	if err != nil {
+		return zero, err
+	}
+	if account != nil {
+		u, err := builder.From[T]().
+			WithContext(ctx).
+			Find(tx, account.UserID)
+		if err != nil {
+			return zero, err
+		}
+		if isNil(u) {
+			return zero, request.NewHTTPError(ErrAccountNotLinked, http.StatusForbidden)
+		}
+		return u, nil
+	}
+
+	if c.resolveUser == nil {
+		return zero, request.NewHTTPError(ErrAccountNotLinked, http.StatusForbidden)
+	}
+	u, err := c.resolveUser(ctx, tx, identity)
+	if err != nil {
+		return zero, err
+	}
+	if isNil(u) {
+		return zero, request.NewHTTPError(ErrAccountNotLinked, http.StatusForbidden)
+	}
+	_, err = Link(ctx, tx, u.GetID(), identity)
+	if err != nil {
+		return zero, err
+	}
+	return u, nil
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,224 @@
+package oauth_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/auth/oauth"
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/database/model"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+var nullLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+var authRoutes = auth.NewBasicAuthController[*auth.UsernameUser]()
+
+func createUser(ctx context.Context, tx database.DB, identity *oauth.Identity) (*auth.UsernameUser, error) {
+	u := &auth.UsernameUser{
+		ID:           uuid.New(),
+		Username:     identity.Email,
+		PasswordHash: []byte{},
+	}
+	return u, model.SaveContext(ctx, tx, u)
+}
+
+// login runs the redirect and callback routes with a code issued by p.
+func login(t *testing.T, tx *sqlx.Tx, c *oauth.BasicController[*auth.UsernameUser], p *testProvider, provider string) (*auth.LoginResponse, error) {
+	w := httptest.NewRecorder()
+	h, err := c.RunRedirect(&oauth.RedirectRequest{
+		Provider: provider,
+		Response: w,
+	})
+	if !assert.NoError(t, err) {
+		return nil, err
+	}
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	location := w.Header().Get("Location")
+	authURL, err := url.Parse(location)
+	assert.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return c.RunCallback(&oauth.CallbackRequest{
+		Provider: provider,
+		Code:     p.authorize(location),
+		State:    authURL.Query().Get("state"),
+		Request:  r,
+		Response: httptest.NewRecorder(),
+		Update:   dbtest.Update(tx),
+		Ctx:      context.Background(),
+		Log:      nullLogger,
+	})
+}
+
+func discover(t *testing.T, p *testProvider) *oauth.Provider {
+	provider, err := oauth.Discover(context.Background(), "test", p.URL, &oauth2.Config{
+		ClientID:    p.clientID,
+		RedirectURL: "http://app.test/oauth/test/callback",
+	})
+	assert.NoError(t, err)
+	return provider
+}
+
+func TestOIDCLogin(t *testing.T) {
+	p := newTestProvider("subject")
+	defer p.Close()
+
+	Run(t, "links new identity", func(t *testing.T, tx *sqlx.Tx) {
+		c := oauth.NewBasicController(authRoutes, createUser, discover(t, p))
+
+		resp, err := login(t, tx, c, p, "test")
+		assert.NoError(t, err)
+		assert.NotZero(t, resp.AccessToken)
+		assert.NotZero(t, resp.RefreshToken)
+
+		claims, err := auth.Parse(resp.AccessToken)
+		assert.NoError(t, err)
+
+		account, err := oauth.FindSocialAccount(context.Background(), tx, &oauth.Identity{Provider: "test", Subject: "subject"})
+		assert.NoError(t, err)
+		if assert.NotNil(t, account) {
+			assert.Equal(t, claims.Subject, account.UserID)
+			assert.Equal(t, "user@example.com", account.Email)
+		}
+
+		resp, err = login(t, tx, c, p, "test")
+		assert.NoError(t, err)
+		claims2, err := auth.Parse(resp.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, claims.Subject, claims2.Subject)
+
+		count, err := builder.From[*oauth.SocialAccount]().Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	Run(t, "unlinked identity", func(t *testing.T, tx *sqlx.Tx) {
+		c := oauth.NewBasicController[*auth.UsernameUser](authRoutes, nil, discover(t, p))
+
+		_, err := login(t, tx, c, p, "test")
+		assert.ErrorIs(t, err, oauth.ErrAccountNotLinked)
+	})
+
+	Run(t, "state mismatch", func(t *testing.T, tx *sqlx.Tx) {
+		c := oauth.NewBasicController(authRoutes, createUser, discover(t, p))
+
+		w := httptest.NewRecorder()
+		_, err := c.RunRedirect(&oauth.RedirectRequest{Provider: "test", Response: w})
+		assert.NoError(t, err)
+
+		r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		for _, cookie := range w.Result().Cookies() {
+			r.AddCookie(cookie)
+		}
+		_, err = c.RunCallback(&oauth.CallbackRequest{
+			Provider: "test",
+			Code:     "code",
+			State:    "not the state",
+			Request:  r,
+			Response: httptest.NewRecorder(),
+			Update:   dbtest.Update(tx),
+			Ctx:      context.Background(),
+			Log:      nullLogger,
+		})
+		assert.ErrorIs(t, err, oauth.ErrStateMismatch)
+	})
+
+	Run(t, "unknown provider", func(t *testing.T, tx *sqlx.Tx) {
+		c := oauth.NewBasicController(authRoutes, createUser, discover(t, p))
+
+		_, err := c.RunRedirect(&oauth.RedirectRequest{Provider: "other", Response: httptest.NewRecorder()})
+		assert.ErrorIs(t, err, oauth.ErrUnknownProvider)
+	})
+}
+
+func TestOAuth2Login(t *testing.T) {
+	p := newTestProvider("subject")
+	defer p.Close()
+
+	Run(t, "user info", func(t *testing.T, tx *sqlx.Tx) {
+		c := oauth.NewBasicController(authRoutes, createUser, oauth.NewProvider("plain", &oauth2.Config{
+			ClientID: p.clientID,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  p.URL + "/authorize",
+				TokenURL: p.URL + "/token",
+			},
+		}, p.URL+"/userinfo"))
+
+		_, err := login(t, tx, c, p, "plain")
+		assert.NoError(t, err)
+
+		account, err := oauth.FindSocialAccount(context.Background(), tx, &oauth.Identity{Provider: "plain", Subject: "42"})
+		assert.NoError(t, err)
+		assert.NotNil(t, account)
+	})
+}
+
+func TestIDTokenVerifier(t *testing.T) {
+	p := newTestProvider("subject")
+	defer p.Close()
+	provider := discover(t, p)
+
+	code := p.authorize(provider.Config.AuthCodeURL("state",
+		oauth2.S256ChallengeOption("verifier"),
+		oauth2.SetAuthURLParam("nonce", "nonce"),
+	))
+
+	_, err := provider.Exchange(context.Background(), code, "verifier", "other nonce")
+	assert.ErrorIs(t, err, oauth.ErrInvalidIDToken)
+
+	code = p.authorize(provider.Config.AuthCodeURL("state",
+		oauth2.S256ChallengeOption("verifier"),
+		oauth2.SetAuthURLParam("nonce", "nonce"),
+	))
+	identity, err := provider.Exchange(context.Background(), code, "verifier", "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, &oauth.Identity{
+		Provider:      "test",
+		Subject:       "subject",
+		Email:         "user@example.com",
+		EmailVerified: true,
+	}, identity)
+
+	verifier := oauth.NewIDTokenVerifier(http.DefaultClient, p.URL, "other client", p.URL+"/jwks")
+	_, err = verifier.Verify(context.Background(), "not a token", "")
+	assert.ErrorIs(t, err, oauth.ErrInvalidIDToken)
+}
+
+func TestIDTokenVerifierUnknownKey(t *testing.T) {
+	p := newTestProvider("subject")
+	defer p.Close()
+
+	verifier := oauth.NewIDTokenVerifier(http.DefaultClient, p.URL, p.clientID, p.URL+"/jwks")
+
+	claims, err := verifier.Verify(context.Background(), p.idToken("test", "nonce"), "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, "subject", claims.Subject)
+	assert.Equal(t, int32(1), p.jwksFetches.Load())
+
+	for i := 0; i < 3; i++ {
+		_, err = verifier.Verify(context.Background(), p.idToken("unknown", "nonce"), "nonce")
+		assert.ErrorIs(t, err, oauth.ErrUnknownKey)
+	}
+	assert.Equal(t, int32(1), p.jwksFetches.Load())
+
+	verifier.WithRefreshInterval(0)
+	_, err = verifier.Verify(context.Background(), p.idToken("unknown", "nonce"), "nonce")
+	assert.ErrorIs(t, err, oauth.ErrUnknownKey)
+	assert.Equal(t, int32(2), p.jwksFetches.Load())
+}
@@ -0,0 +1,180 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/auth/oauth"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/database/dialects/sqlite"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jmoiron/sqlx"
+)
+
+var runner = dbtest.NewRunner(func() (*sqlx.DB, error) {
+	sqlite.UseSQLite()
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	err = migrate.RunModelCreate(ctx, db, &auth.UsernameUser{}, &auth.RefreshToken{}, &oauth.SocialAccount{})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+})
+
+var Run = runner.Run
+
+type authorization struct {
+	challenge string
+	nonce     string
+}
+
+// testProvider is a minimal OpenID Connect provider. Codes are issued with
+// authorize instead of a consent page.
+type testProvider struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	subject  string
+
+	mtx   sync.Mutex
+	codes map[string]authorization
+
+	jwksFetches atomic.Int32
+}
+
+func newTestProvider(subject string) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	p := &testProvider{
+		key:      key,
+		clientID: "client",
+		subject:  subject,
+		codes:    map[string]authorization{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"userinfo_endpoint":      p.URL + "/userinfo",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.jwksFetches.Add(1)
+		writeJSON(w, map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-"+p.subject {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"id":    42,
+			"email": "user@example.com",
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// authorize issues a code as if the user accepted the consent page at
+// authURL.
+func (p *testProvider) authorize(authURL string) string {
+	r := httptest.NewRequest(http.MethodGet, authURL, http.NoBody)
+	q := r.URL.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		panic("missing pkce challenge")
+	}
+	code := "code-" + q.Get("state")
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.codes[code] = authorization{
+		challenge: q.Get("code_challenge"),
+		nonce:     q.Get("nonce"),
+	}
+	return code
+}
+
+func (p *testProvider) token(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	p.mtx.Lock()
+	a, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mtx.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != a.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	signed := p.idToken("test", a.nonce)
+
+	writeJSON(w, map[string]any{
+		"access_token": "access-" + p.subject,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// idToken signs an ID token for the provider's subject with the key id kid.
+func (p *testProvider) idToken(kid, nonce string) string {
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, &oauth.IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.URL,
+			Subject:   p.subject,
+			Audience:  jwt.ClaimStrings{p.clientID},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		Nonce:         nonce,
+		Email:         "user@example.com",
+		EmailVerified: true,
+	})
+	idToken.Header["kid"] = kid
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,82 @@
+package oauth
+
+import (
+	"context"
+	"time"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/google/uuid"
+)
+
+// SocialAccount links a provider identity to a user.
+type SocialAccount struct {
+	model.BaseModel
+	ID        string    `json:"id"         db:"id,primary"`
+	Provider  string    `json:"provider"   db:"provider"`
+	Subject   string    `json:"subject"    db:"subject"`
+	UserID    string    `json:"user_id"    db:"user_id"`
+	Email     string    `json:"email"      db:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (*SocialAccount) Table() string {
+	return "social_accounts"
+}
+
+// SocialAccountMigration creates the social_accounts table.
+func SocialAccountMigration(name string) *migrate.Migration {
+	return &migrate.Migration{
+		Name: name,
+		Up: schema.Create("social_accounts", func(table *schema.Blueprint) {
+			table.String("id").Primary()
+			table.String("provider")
+			table.String("subject")
+			table.String("user_id")
+			table.String("email")
+			table.DateTime("created_at")
+			table.Index("social_accounts-provider-subject").AddColumn("provider").AddColumn("subject").Unique()
+			table.Index("social_accounts-user_id").AddColumn("user_id")
+		}),
+		Down: schema.DropIfExists("social_accounts"),
+	}
+}
+
+// FindSocialAccount returns the account linked to the identity or nil if the
+// identity has not been linked.
+func FindSocialAccount(ctx context.Context, tx database.DB, identity *Identity) (*SocialAccount, error) {
+	return builder.From[*SocialAccount]().
+		WithContext(ctx).
+		Where("provider", "=", identity.Provider).
+		Where("subject", "=", identity.Subject).
+		First(tx)
+}
+
+// Link links the identity to a user.
+func Link(ctx context.Context, tx database.DB, userID string, identity *Identity) (*SocialAccount, error) {
+	a := &SocialAccount{
+		ID:        uuid.NewString(),
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		UserID:    userID,
+		Email:     identity.Email,
+		CreatedAt: time.Now(),
+	}
+	err := model.SaveContext(ctx, tx, a)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Unlink removes the link between a user and a provider.
+func Unlink(ctx context.Context, tx database.DB, userID, provider string) error {
+	return builder.From[*SocialAccount]().
+		WithContext(ctx).
+		Where("user_id", "=", userID).
+		Where("provider", "=", provider).
+		Delete(tx)
+}
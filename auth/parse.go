@@ -15,14 +15,7 @@ func ParseOf[T jwt.Claims](token string) (T, error) {
 		var zero T
 		return zero, err
 	}
-	t, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-		// Don't forget to validate the alg is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("expected HMAC received %v: %w", token.Header["alg"], ErrUnexpectedAlgorithm)
-		}
-
-		return getAppKey(), nil
-	})
+	t, err := jwt.ParseWithClaims(token, claims, keyFunc)
 	if err != nil {
 		var zero T
 		return zero, fmt.Errorf("failed to parse JWT: %w", err)
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/google/uuid"
+)
+
+const (
+	LoginMethodLink = "link"
+	LoginMethodCode = "code"
+)
+
+const (
+	loginCodeDigits      = 6
+	maxLoginCodeAttempts = 5
+)
+
+var (
+	ErrInvalidLoginMethod = errors.New("invalid login method")
+	ErrInvalidLoginToken  = errors.New("invalid or expired login token")
+)
+
+// LoginToken is a single use magic link token or email code. Only a hash of
+// the token is stored.
+type LoginToken struct {
+	model.BaseModel
+	ID        string    `json:"id"         db:"id,primary"`
+	UserID    string    `json:"user_id"    db:"user_id"`
+	Method    string    `json:"method"     db:"method"`
+	TokenHash string    `json:"-"          db:"token_hash"`
+	Attempts  int       `json:"-"          db:"attempts"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (*LoginToken) Table() string {
+	return "login_tokens"
+}
+
+// LoginTokenMigration creates the login_tokens table used by passwordless
+// login.
+func LoginTokenMigration(name string) *migrate.Migration {
+	return &migrate.Migration{
+		Name: name,
+		Up: schema.Create("login_tokens", func(table *schema.Blueprint) {
+			table.String("id").Primary()
+			table.String("user_id")
+			table.String("method")
+			table.String("token_hash")
+			table.Int("attempts")
+			table.DateTime("expires_at")
+			table.DateTime("created_at")
+			table.Index("login_tokens-token_hash").AddColumn("token_hash")
+			table.Index("login_tokens-user_id").AddColumn("user_id")
+		}),
+		Down: schema.DropIfExists("login_tokens"),
+	}
+}
+
+// createLoginToken replaces the users outstanding tokens of the method with a
+// new one and returns the plain text token.
+func createLoginToken(ctx context.Context, tx database.DB, userID, method string, ttl time.Duration) (string, error) {
+	var token string
+	switch method {
+	case LoginMethodLink:
+		b := make([]byte, 32)
+		_, err := rand.Read(b)
+		if err != nil {
+			return "", err
+		}
+		token = base64.RawURLEncoding.EncodeToString(b)
+	case LoginMethodCode:
+		n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+		if err != nil {
+			return "", err
+		}
+		token = fmt.Sprintf("%0*d", loginCodeDigits, n.Int64())
+	default:
+		return "", ErrInvalidLoginMethod
+	}
+
+	err := builder.From[*LoginToken]().
+		WithContext(ctx).
+		Where("user_id", "=", userID).
+		Where("method", "=", method).
+		Delete(tx)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = model.SaveContext(ctx, tx, &LoginToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Method:    method,
+		TokenHash: hashLoginToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// redeemLoginLink deletes the link token and returns the id of its user.
+func redeemLoginLink(ctx context.Context, tx database.DB, token string) (string, error) {
+	t, err := builder.From[*LoginToken]().
+		WithContext(ctx).
+		Where("method", "=", LoginMethodLink).
+		Where("token_hash", "=", hashLoginToken(token)).
+		First(tx)
+	if err != nil {
+		return "", err
+	}
+	if t == nil {
+		return "", ErrInvalidLoginToken
+	}
+	err = deleteLoginToken(ctx, tx, t)
+	if err != nil {
+		return "", err
+	}
+	if time.Now().After(t.ExpiresAt) {
+		return "", ErrInvalidLoginToken
+	}
+	return t.UserID, nil
+}
+
+// redeemLoginCode deletes the users code if it matches. Codes are deleted
+// after too many wrong guesses.
+func redeemLoginCode(ctx context.Context, tx database.DB, userID, code string) error {
+	t, err := builder.From[*LoginToken]().
+		WithContext(ctx).
+		Where("method", "=", LoginMethodCode).
+		Where("user_id", "=", userID).
+		First(tx)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return ErrInvalidLoginToken
+	}
+	if time.Now().After(t.ExpiresAt) {
+		err = deleteLoginToken(ctx, tx, t)
+		if err != nil {
+			return err
+		}
+		return ErrInvalidLoginToken
+	}
+
+	if subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(hashLoginToken(code))) != 1 {
+		t.Attempts++
+		if t.Attempts >= maxLoginCodeAttempts {
+			err = deleteLoginToken(ctx, tx, t)
+		} else {
+			err = model.SaveContext(ctx, tx, t)
+		}
+		if err != nil {
+			return err
+		}
+		return ErrInvalidLoginToken
+	}
+	return deleteLoginToken(ctx, tx, t)
+}
+
+func deleteLoginToken(ctx context.Context, tx database.DB, t *LoginToken) error {
+	return builder.From[*LoginToken]().
+		WithContext(ctx).
+		Where("id", "=", t.ID).
+		Delete(tx)
+}
+
+func hashLoginToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,193 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/email/emailtest"
+	"github.com/abibby/salusa/router/routertest"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+var loginLinkRegexp = regexp.MustCompile(`href="([^"]+)"`)
+var loginCodeRegexp = regexp.MustCompile(`<strong>(\d+)</strong>`)
+
+func TestAuthRoutesPasswordless(t *testing.T) {
+	createUser := func(t *testing.T, tx *sqlx.Tx, verified bool) *auth.EmailVerifiedUser {
+		u := &auth.EmailVerifiedUser{
+			ID:           uuid.New(),
+			Email:        "user@example.com",
+			PasswordHash: []byte{},
+			Verified:     verified,
+		}
+		assert.NoError(t, model.Save(tx, u))
+		return u
+	}
+	send := func(t *testing.T, routes *auth.BasicAuthController[*auth.EmailVerifiedUser], tx *sqlx.Tx, method string) *emailtest.TestMailer {
+		m := emailtest.NewTestMailer()
+		_, err := routes.RunPasswordlessSend(&auth.PasswordlessSendRequest{
+			Email:    "user@example.com",
+			Method:   method,
+			Update:   dbtest.Update(tx),
+			Ctx:      context.Background(),
+			Mailer:   m,
+			Logger:   nullLogger,
+			URL:      routertest.NewTestResolver(),
+			Template: emailTemplates,
+		})
+		assert.NoError(t, err)
+		time.Sleep(time.Millisecond * 20)
+		return m
+	}
+	login := func(routes *auth.BasicAuthController[*auth.EmailVerifiedUser], tx *sqlx.Tx, r *auth.PasswordlessLoginRequest) (*auth.LoginResponse, error) {
+		r.Update = dbtest.Update(tx)
+		r.Ctx = context.Background()
+		r.Log = nullLogger
+		r.Request = httptest.NewRequest(http.MethodPost, "/login/passwordless/redeem", http.NoBody)
+		return routes.RunPasswordlessLogin(r)
+	}
+	linkToken := func(t *testing.T, m *emailtest.TestMailer) string {
+		sent := m.EmailsSent()
+		if !assert.Len(t, sent, 1) {
+			return ""
+		}
+		match := loginLinkRegexp.FindStringSubmatch(sent[0].HTMLBody)
+		if !assert.Len(t, match, 2) {
+			return ""
+		}
+		u, err := url.Parse(match[1])
+		assert.NoError(t, err)
+		assert.Equal(t, "/login-link", u.Path)
+		return u.Query().Get("token")
+	}
+
+	Run(t, "link", func(t *testing.T, tx *sqlx.Tx) {
+		u := createUser(t, tx, true)
+		m := send(t, emailRoutes, tx, auth.LoginMethodLink)
+		token := linkToken(t, m)
+		assert.NotZero(t, token)
+		assert.Equal(t, "Log in", m.EmailsSent()[0].Subject)
+
+		resp, err := login(emailRoutes, tx, &auth.PasswordlessLoginRequest{Token: token})
+		assert.NoError(t, err)
+		assert.NotZero(t, resp.AccessToken)
+
+		claims, err := auth.Parse(resp.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, u.ID.String(), claims.Subject)
+
+		_, err = login(emailRoutes, tx, &auth.PasswordlessLoginRequest{Token: token})
+		assert.ErrorIs(t, err, auth.ErrInvalidLoginToken)
+	})
+
+	Run(t, "code", func(t *testing.T, tx *sqlx.Tx) {
+		createUser(t, tx, true)
+		m := send(t, emailRoutes, tx, auth.LoginMethodCode)
+		sent := m.EmailsSent()
+		assert.Len(t, sent, 1)
+		match := loginCodeRegexp.FindStringSubmatch(sent[0].HTMLBody)
+		assert.Len(t, match, 2)
+		code := match[1]
+		assert.Len(t, code, 6)
+
+		resp, err := login(emailRoutes, tx, &auth.PasswordlessLoginRequest{Email: "user@example.com", Code: code})
+		assert.NoError(t, err)
+		assert.NotZero(t, resp.AccessToken)
+
+		_, err = login(emailRoutes, tx, &auth.PasswordlessLoginRequest{Email: "user@example.com", Code: code})
+		assert.ErrorIs(t, err, auth.ErrInvalidLoginToken)
+	})
+
+	Run(t, "code is removed after too many guesses", func(t *testing.T, tx *sqlx.Tx) {
+		createUser(t, tx, true)
+		m := send(t, emailRoutes, tx, auth.LoginMethodCode)
+		code := loginCodeRegexp.FindStringSubmatch(m.EmailsSent()[0].HTMLBody)[1]
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+
+		for i := 0; i < 5; i++ {
+			_, err := login(emailRoutes, tx, &auth.PasswordlessLoginRequest{Email: "user@example.com", Code: wrong})
+			assert.ErrorIs(t, err, auth.ErrInvalidLoginToken)
+		}
+		_, err := login(emailRoutes, tx, &auth.PasswordlessLoginRequest{Email: "user@example.com", Code: code})
+		assert.ErrorIs(t, err, auth.ErrInvalidLoginToken)
+	})
+
+	Run(t, "expired", func(t *testing.T, tx *sqlx.Tx) {
+		routes := auth.NewBasicAuthController[*auth.EmailVerifiedUser](auth.LoginTokenTTL(-time.Minute))
+		createUser(t, tx, true)
+		token := linkToken(t, send(t, routes, tx, auth.LoginMethodLink))
+
+		_, err := login(routes, tx, &auth.PasswordlessLoginRequest{Token: token})
+		assert.ErrorIs(t, err, auth.ErrInvalidLoginToken)
+	})
+
+	Run(t, "unverified and unknown emails are not sent", func(t *testing.T, tx *sqlx.Tx) {
+		m := send(t, emailRoutes, tx, auth.LoginMethodLink)
+		assert.Len(t, m.EmailsSent(), 0)
+
+		createUser(t, tx, false)
+		m = send(t, emailRoutes, tx, auth.LoginMethodLink)
+		assert.Len(t, m.EmailsSent(), 0)
+	})
+
+	Run(t, "throttled", func(t *testing.T, tx *sqlx.Tx) {
+		routes := auth.NewBasicAuthController[*auth.EmailVerifiedUser](auth.Throttle(
+			auth.NewLoginThrottle(auth.NewMemoryThrottleStore()).
+				MaxAttempts(2).
+				Delay(0),
+		))
+		createUser(t, tx, true)
+		m := send(t, routes, tx, auth.LoginMethodCode)
+		code := loginCodeRegexp.FindStringSubmatch(m.EmailsSent()[0].HTMLBody)[1]
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+
+		for i := 0; i < 2; i++ {
+			_, err := login(routes, tx, &auth.PasswordlessLoginRequest{Email: "user@example.com", Code: wrong})
+			assert.ErrorIs(t, err, auth.ErrInvalidLoginToken)
+		}
+		_, err := login(routes, tx, &auth.PasswordlessLoginRequest{Email: "user@example.com", Code: code})
+		assert.ErrorIs(t, err, auth.ErrTooManyAttempts)
+	})
+
+	Run(t, "links are throttled by token", func(t *testing.T, tx *sqlx.Tx) {
+		routes := auth.NewBasicAuthController[*auth.EmailVerifiedUser](auth.Throttle(
+			auth.NewLoginThrottle(auth.NewMemoryThrottleStore()).
+				MaxAttempts(2).
+				Delay(0),
+		))
+		createUser(t, tx, true)
+		token := linkToken(t, send(t, routes, tx, auth.LoginMethodLink))
+
+		for i := 0; i < 3; i++ {
+			_, err := login(routes, tx, &auth.PasswordlessLoginRequest{Token: uuid.NewString()})
+			assert.ErrorIs(t, err, auth.ErrInvalidLoginToken)
+		}
+
+		resp, err := login(routes, tx, &auth.PasswordlessLoginRequest{Token: token})
+		assert.NoError(t, err)
+		assert.NotZero(t, resp.AccessToken)
+
+		_, err = login(routes, tx, &auth.PasswordlessLoginRequest{Token: token})
+		assert.ErrorIs(t, err, auth.ErrInvalidLoginToken)
+		_, err = login(routes, tx, &auth.PasswordlessLoginRequest{Token: token})
+		assert.ErrorIs(t, err, auth.ErrInvalidLoginToken)
+		_, err = login(routes, tx, &auth.PasswordlessLoginRequest{Token: token})
+		assert.ErrorIs(t, err, auth.ErrTooManyAttempts)
+	})
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/jsoncolumn"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/abibby/salusa/di"
+	"github.com/abibby/salusa/request"
+	"github.com/abibby/salusa/router"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token so they can be
+// told apart from JWTs and found by secret scanners.
+const PersonalAccessTokenPrefix = "sat_"
+
+var (
+	ErrInvalidPersonalAccessToken = errors.New("invalid personal access token")
+	ErrPersonalAccessTokenExpired = errors.New("personal access token expired")
+)
+
+// PersonalAccessToken is a long lived, revocable credential for scripts and
+// integrations. Only a hash of the token is stored.
+type PersonalAccessToken struct {
+	model.BaseModel
+	ID         string                   `json:"id"           db:"id,primary"`
+	UserID     string                   `json:"-"            db:"user_id"`
+	Name       string                   `json:"name"         db:"name"`
+	TokenHash  string                   `json:"-"            db:"token_hash,unique"`
+	Scopes     jsoncolumn.Slice[string] `json:"scopes"       db:"scopes"`
+	LastUsedAt *time.Time               `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  *time.Time               `json:"expires_at"   db:"expires_at"`
+	CreatedAt  time.Time                `json:"created_at"   db:"created_at"`
+}
+
+func (*PersonalAccessToken) Table() string {
+	return "personal_access_tokens"
+}
+
+// PersonalAccessTokenMigration creates the personal_access_tokens table.
+func PersonalAccessTokenMigration(name string) *migrate.Migration {
+	return &migrate.Migration{
+		Name: name,
+		Up: schema.Create("personal_access_tokens", func(table *schema.Blueprint) {
+			table.String("id").Primary()
+			table.String("user_id")
+			table.String("name")
+			table.String("token_hash").Unique()
+			table.Text("scopes")
+			table.DateTime("last_used_at").Nullable()
+			table.DateTime("expires_at").Nullable()
+			table.DateTime("created_at")
+			table.Index("personal_access_tokens-user_id").AddColumn("user_id")
+		}),
+		Down: schema.DropIfExists("personal_access_tokens"),
+	}
+}
+
+// CreatePersonalAccessToken creates a token for the user and returns it with
+// the plain text token. The plain text token can't be recovered later. A nil
+// expiresAt creates a token that doesn't expire.
+func CreatePersonalAccessToken(ctx context.Context, tx database.DB, userID, name string, scopes []string, expiresAt *time.Time) (*PersonalAccessToken, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, "", err
+	}
+	token := PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+	t := &PersonalAccessToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashPersonalAccessToken(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	err = model.SaveContext(ctx, tx, t)
+	if err != nil {
+		return nil, "", err
+	}
+	return t, token, nil
+}
+
+// RevokePersonalAccessToken deletes one of the users tokens.
+func RevokePersonalAccessToken(ctx context.Context, tx database.DB, userID, id string) error {
+	return builder.From[*PersonalAccessToken]().
+		WithContext(ctx).
+		Where("user_id", "=", userID).
+		Where("id", "=", id).
+		Delete(tx)
+}
+
+func hashPersonalAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PersonalAccessTokenGuard authenticates requests with a personal access
+// token in the Authorization header. The claims have the access and
+// personal-access-token scopes and the scopes of the token. The users roles
+// and permissions are only added with RoleClaims, without it HasRole and
+// HasPermission checks fail for personal access tokens.
+type PersonalAccessTokenGuard struct {
+	db         database.DB
+	roleClaims bool
+}
+
+var _ Guard = (*PersonalAccessTokenGuard)(nil)
+
+// NewPersonalAccessTokenGuard creates a guard that looks tokens up in the
+// *sqlx.DB from the request context. Use DB to set a database directly.
+func NewPersonalAccessTokenGuard() *PersonalAccessTokenGuard {
+	return &PersonalAccessTokenGuard{}
+}
+
+func (g *PersonalAccessTokenGuard) DB(db database.DB) *PersonalAccessTokenGuard {
+	g.db = db
+	return g
+}
+
+// RoleClaims adds the users roles and permissions to the claims. It requires
+// the tables from RolesMigration.
+func (g *PersonalAccessTokenGuard) RoleClaims() *PersonalAccessTokenGuard {
+	g.roleClaims = true
+	return g
+}
+
+func (g *PersonalAccessTokenGuard) Authenticate(r *http.Request) (*Claims, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !strings.HasPrefix(token, PersonalAccessTokenPrefix) {
+		return nil, fmt.Errorf("no personal access token: %w", ErrNoCredentials)
+	}
+
+	ctx := r.Context()
+	db := g.db
+	if db == nil {
+		sqlxDB, err := di.Resolve[*sqlx.DB](ctx)
+		if err != nil {
+			return nil, err
+		}
+		db = sqlxDB
+	}
+
+	t, err := builder.From[*PersonalAccessToken]().
+		WithContext(ctx).
+		Where("token_hash", "=", hashPersonalAccessToken(token)).
+		First(db)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+	now := time.Now()
+	if t.ExpiresAt != nil && now.After(*t.ExpiresAt) {
+		return nil, ErrPersonalAccessTokenExpired
+	}
+
+	err = builder.From[*PersonalAccessToken]().
+		WithContext(ctx).
+		Where("id", "=", t.ID).
+		Update(db, builder.Updates{"last_used_at": now})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := NewClaims().
+		WithSubject(t.UserID).
+		WithScopes(append([]string{ScopeAccess, ScopePersonalAccessToken}, t.Scopes...)...)
+	if t.ExpiresAt != nil {
+		claims = claims.WithExpirationTime(*t.ExpiresAt)
+	}
+	if g.roleClaims {
+		roles, permissions, err := UserRoles(ctx, db, t.UserID)
+		if err != nil {
+			return nil, err
+		}
+		claims = claims.WithRoles(roles...).WithPermissions(permissions...)
+	}
+	return claims, nil
+}
+
+// RejectPersonalAccessTokens responds with 403 Forbidden to requests
+// authenticated with a personal access token. It protects routes that manage
+// the account, so a leaked token can't be used to take it over.
+func RejectPersonalAccessTokens() router.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetClaims(r)
+			if ok && slices.Contains(claims.Scope, ScopePersonalAccessToken) {
+				respond(request.NewHTTPError(fmt.Errorf("%w: personal access tokens can't manage the account", ErrForbidden), http.StatusForbidden), w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
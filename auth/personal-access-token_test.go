@@ -0,0 +1,198 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/router"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	userClaims := auth.NewClaims().WithSubject("user").WithScopes(auth.ScopeAccess, "deploy", "read")
+
+	create := func(t *testing.T, tx *sqlx.Tx, scopes []string, expiresAt *time.Time) *auth.PersonalAccessTokenCreateResponse {
+		resp, err := usernameRoutes.RunPersonalAccessTokenCreate(&auth.PersonalAccessTokenCreateRequest{
+			Name:      "ci",
+			Scopes:    scopes,
+			ExpiresAt: expiresAt,
+			Claims:    userClaims,
+			Update:    dbtest.Update(tx),
+			Ctx:       context.Background(),
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+	request := func(tx *sqlx.Tx, token string) (int, *auth.Claims) {
+		var claims *auth.Claims
+		h := auth.AttachUser(auth.NewBearerGuard(), auth.NewPersonalAccessTokenGuard().DB(tx).RoleClaims())(
+			auth.HasClaim(func(c *auth.Claims) bool {
+				return slices.Contains(c.Scope, "deploy")
+			}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claims, _ = auth.GetClaims(r)
+			})),
+		)
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code, claims
+	}
+
+	Run(t, "create stores a hash", func(t *testing.T, tx *sqlx.Tx) {
+		resp := create(t, tx, []string{"deploy"}, nil)
+		assert.Contains(t, resp.Token, auth.PersonalAccessTokenPrefix)
+		assert.NotEqual(t, resp.Token, resp.PersonalAccessToken.TokenHash)
+
+		stored, err := builder.From[*auth.PersonalAccessToken]().Find(tx, resp.PersonalAccessToken.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "user", stored.UserID)
+		assert.Equal(t, "ci", stored.Name)
+		assert.NotContains(t, stored.TokenHash, resp.Token)
+	})
+
+	Run(t, "authenticates with scopes", func(t *testing.T, tx *sqlx.Tx) {
+		resp := create(t, tx, []string{"deploy"}, nil)
+
+		code, claims := request(tx, resp.Token)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "user", claims.Subject)
+		assert.Contains(t, claims.Scope, auth.ScopePersonalAccessToken)
+
+		stored, err := builder.From[*auth.PersonalAccessToken]().Find(tx, resp.PersonalAccessToken.ID)
+		assert.NoError(t, err)
+		assert.NotNil(t, stored.LastUsedAt)
+	})
+
+	Run(t, "role claims", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		_, err := auth.CreateRole(ctx, tx, "editor", "posts.edit")
+		assert.NoError(t, err)
+		assert.NoError(t, auth.AssignRole(ctx, tx, "user", "editor"))
+		resp := create(t, tx, []string{"deploy"}, nil)
+
+		code, claims := request(tx, resp.Token)
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, claims.HasRole("editor"))
+		assert.True(t, claims.HasPermission("posts.edit"))
+	})
+
+	Run(t, "scopes the user doesn't have", func(t *testing.T, tx *sqlx.Tx) {
+		_, err := usernameRoutes.RunPersonalAccessTokenCreate(&auth.PersonalAccessTokenCreateRequest{
+			Name:   "ci",
+			Scopes: []string{"deploy"},
+			Claims: auth.NewClaims().WithSubject("user").WithScopes(auth.ScopeAccess),
+			Update: dbtest.Update(tx),
+			Ctx:    context.Background(),
+		})
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+		if httpErr, ok := err.(interface{ Status() int }); assert.True(t, ok) {
+			assert.Equal(t, http.StatusForbidden, httpErr.Status())
+		}
+
+		list, err := usernameRoutes.RunPersonalAccessTokenList(&auth.PersonalAccessTokenListRequest{
+			Claims: userClaims,
+			Read:   dbtest.Read(tx),
+			Ctx:    context.Background(),
+		})
+		assert.NoError(t, err)
+		assert.Len(t, list.PersonalAccessTokens, 0)
+	})
+
+	Run(t, "missing scope", func(t *testing.T, tx *sqlx.Tx) {
+		resp := create(t, tx, []string{"read"}, nil)
+
+		code, _ := request(tx, resp.Token)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	Run(t, "rejects invalid and expired tokens", func(t *testing.T, tx *sqlx.Tx) {
+		code, _ := request(tx, auth.PersonalAccessTokenPrefix+"invalid")
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		resp := create(t, tx, []string{"deploy"}, nil)
+		past := time.Now().Add(-time.Hour)
+		err := builder.From[*auth.PersonalAccessToken]().
+			Where("id", "=", resp.PersonalAccessToken.ID).
+			Update(tx, builder.Updates{"expires_at": past})
+		assert.NoError(t, err)
+
+		code, _ = request(tx, resp.Token)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	Run(t, "list and revoke", func(t *testing.T, tx *sqlx.Tx) {
+		resp := create(t, tx, []string{"deploy"}, nil)
+
+		list, err := usernameRoutes.RunPersonalAccessTokenList(&auth.PersonalAccessTokenListRequest{
+			Claims: userClaims,
+			Read:   dbtest.Read(tx),
+			Ctx:    context.Background(),
+		})
+		assert.NoError(t, err)
+		assert.Len(t, list.PersonalAccessTokens, 1)
+
+		_, err = usernameRoutes.RunPersonalAccessTokenRevoke(&auth.PersonalAccessTokenRevokeRequest{
+			ID:     resp.PersonalAccessToken.ID,
+			Claims: auth.NewClaims().WithSubject("other"),
+			Update: dbtest.Update(tx),
+			Ctx:    context.Background(),
+		})
+		assert.NoError(t, err)
+		code, _ := request(tx, resp.Token)
+		assert.Equal(t, http.StatusOK, code)
+
+		_, err = usernameRoutes.RunPersonalAccessTokenRevoke(&auth.PersonalAccessTokenRevokeRequest{
+			ID:     resp.PersonalAccessToken.ID,
+			Claims: userClaims,
+			Update: dbtest.Update(tx),
+			Ctx:    context.Background(),
+		})
+		assert.NoError(t, err)
+		code, _ = request(tx, resp.Token)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	Run(t, "tokens can't manage the account", func(t *testing.T, tx *sqlx.Tx) {
+		resp := create(t, tx, []string{"read"}, nil)
+
+		r := router.New()
+		r.Use(auth.AttachUser(auth.NewBearerGuard(), auth.NewPersonalAccessTokenGuard().DB(tx)))
+		auth.RegisterRoutes(r, usernameRoutes)
+
+		routes := []struct{ method, path string }{
+			{http.MethodPost, "/user/2fa/enable"},
+			{http.MethodPost, "/user/2fa/confirm"},
+			{http.MethodPost, "/user/2fa/disable"},
+			{http.MethodPost, "/user/password/change"},
+			{http.MethodPost, "/logout/all"},
+			{http.MethodGet, "/user/tokens"},
+			{http.MethodDelete, "/user/tokens/" + resp.PersonalAccessToken.ID},
+		}
+		for _, route := range routes {
+			req := httptest.NewRequest(route.method, route.path, http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+resp.Token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusForbidden, w.Code, route.path)
+		}
+	})
+
+	Run(t, "tokens can't create tokens", func(t *testing.T, tx *sqlx.Tx) {
+		_, err := usernameRoutes.RunPersonalAccessTokenCreate(&auth.PersonalAccessTokenCreateRequest{
+			Name:   "ci",
+			Claims: auth.NewClaims().WithSubject("user").WithScopes(auth.ScopeAccess, auth.ScopePersonalAccessToken),
+			Update: dbtest.Update(tx),
+			Ctx:    context.Background(),
+		})
+		assert.ErrorIs(t, err, auth.ErrForbidden)
+	})
+}
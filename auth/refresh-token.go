@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/dialects"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRefreshTokenRevoked = errors.New("refresh token revoked")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// RefreshToken is an issued refresh token. Each token can be used once, using
+// it issues a new token in the same family. Using a token a second time
+// revokes every token in its family.
+type RefreshToken struct {
+	model.BaseModel
+	ID        string     `json:"id"         db:"id,primary"`
+	FamilyID  string     `json:"family_id"  db:"family_id"`
+	UserID    string     `json:"user_id"    db:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"    db:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (*RefreshToken) Table() string {
+	return "refresh_tokens"
+}
+
+// RefreshTokenMigration creates the refresh_tokens table.
+func RefreshTokenMigration(name string) *migrate.Migration {
+	return &migrate.Migration{
+		Name: name,
+		Up: schema.Create("refresh_tokens", func(table *schema.Blueprint) {
+			table.String("id").Primary()
+			table.String("family_id")
+			table.String("user_id")
+			table.DateTime("expires_at")
+			table.DateTime("used_at").Nullable()
+			table.DateTime("revoked_at").Nullable()
+			table.DateTime("created_at")
+			table.Index("refresh_tokens-family_id").AddColumn("family_id")
+			table.Index("refresh_tokens-user_id").AddColumn("user_id")
+		}),
+		Down: schema.DropIfExists("refresh_tokens"),
+	}
+}
+
+// RevokeRefreshTokens revokes every refresh token issued to the user.
+func RevokeRefreshTokens(ctx context.Context, tx database.DB, userID string) error {
+	return builder.From[*RefreshToken]().
+		WithContext(ctx).
+		Where("user_id", "=", userID).
+		Where("revoked_at", "=", nil).
+		Update(tx, builder.Updates{"revoked_at": time.Now()})
+}
+
+func revokeRefreshTokenFamily(ctx context.Context, tx database.DB, familyID string) error {
+	return builder.From[*RefreshToken]().
+		WithContext(ctx).
+		Where("family_id", "=", familyID).
+		Where("revoked_at", "=", nil).
+		Update(tx, builder.Updates{"revoked_at": time.Now()})
+}
+
+func createRefreshToken(ctx context.Context, tx database.DB, userID, familyID string, lifetime time.Duration) (*RefreshToken, error) {
+	now := time.Now()
+	t := &RefreshToken{
+		ID:        uuid.NewString(),
+		FamilyID:  familyID,
+		UserID:    userID,
+		ExpiresAt: now.Add(lifetime),
+		CreatedAt: now,
+	}
+	if t.FamilyID == "" {
+		t.FamilyID = t.ID
+	}
+	err := model.SaveContext(ctx, tx, t)
+	if err != nil {
+		return nil, fmt.Errorf("could not save refresh token: %w", err)
+	}
+	return t, nil
+}
+
+// useRefreshToken marks the token with the id jti as used. If the token has
+// already been used its family is revoked and ErrRefreshTokenReused is
+// returned, the revocation is saved so the caller must still commit tx.
+func useRefreshToken(ctx context.Context, tx database.DB, jti string) (*RefreshToken, error) {
+	t, err := builder.From[*RefreshToken]().WithContext(ctx).Find(tx, jti)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.RevokedAt != nil || time.Now().After(t.ExpiresAt) {
+		return nil, ErrRefreshTokenRevoked
+	}
+
+	// only one request can mark the token as used, any others are treated as
+	// reuse
+	q, bindings, err := builder.From[*RefreshToken]().
+		Where("id", "=", t.ID).
+		Where("used_at", "=", nil).
+		Updater(builder.Updates{"used_at": time.Now()}).
+		SQLString(dialects.New())
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, q, bindings...)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated == 0 {
+		err = revokeRefreshTokenFamily(ctx, tx, t.FamilyID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	return t, nil
+}
@@ -8,7 +8,9 @@ import (
 
 	"github.com/abibby/salusa/database/builder"
 	"github.com/abibby/salusa/di"
+	"github.com/abibby/salusa/filesystem"
 	"github.com/abibby/salusa/internal/helpers"
+	"github.com/abibby/salusa/salusaconfig"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -18,6 +20,18 @@ type userRegisterDeps struct {
 }
 
 func Register[T User](ctx context.Context) error {
+	cfg, err := di.Resolve[salusaconfig.Config](ctx)
+	if err == nil {
+		if cfger, ok := cfg.(AppKeyConfiger); ok && len(cfger.AppKey()) > 0 {
+			SetAppKey(cfger.AppKey())
+			SetPreviousAppKeys(cfger.PreviousAppKeys()...)
+		}
+		err = loadKeys(cfg)
+		if err != nil {
+			return err
+		}
+	}
+
 	di.Register(ctx, func(ctx context.Context, tag string) (*Claims, error) {
 		c, _ := GetClaimsCtx(ctx)
 		return c, nil
@@ -64,3 +78,37 @@ func Register[T User](ctx context.Context) error {
 	})
 	return nil
 }
+
+// loadKeys sets the signing and verification keys from the files in the
+// filesystem config.
+func loadKeys(cfg salusaconfig.Config) error {
+	var cfgAny any = cfg
+	keyCfger, ok := cfgAny.(SigningKeyConfiger)
+	if !ok {
+		return nil
+	}
+	fsCfger, ok := cfgAny.(filesystem.FSConfiger)
+	if !ok {
+		return fmt.Errorf("config must implement filesystem.FSConfiger to load signing keys")
+	}
+	fsys := fsCfger.FSConfig().FS()
+
+	verificationKeys := []*VerificationKey{}
+	for _, file := range keyCfger.VerificationKeyFiles() {
+		k, err := LoadVerificationKey(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to load verification key %s: %w", file.Path, err)
+		}
+		verificationKeys = append(verificationKeys, k)
+	}
+	SetVerificationKeys(verificationKeys...)
+
+	if file := keyCfger.SigningKeyFile(); file.Path != "" {
+		k, err := LoadSigningKey(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to load signing key %s: %w", file.Path, err)
+		}
+		SetSigningKey(k)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/google/uuid"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+// Role is a named group of permissions that can be assigned to users.
+type Role struct {
+	model.BaseModel
+	ID   string `json:"id"   db:"id,primary"`
+	Name string `json:"name" db:"name,unique"`
+}
+
+func (*Role) Table() string {
+	return "roles"
+}
+
+type Permission struct {
+	model.BaseModel
+	ID   string `json:"id"   db:"id,primary"`
+	Name string `json:"name" db:"name,unique"`
+}
+
+func (*Permission) Table() string {
+	return "permissions"
+}
+
+type RolePermission struct {
+	model.BaseModel
+	ID           string `json:"id"            db:"id,primary"`
+	RoleID       string `json:"role_id"       db:"role_id"`
+	PermissionID string `json:"permission_id" db:"permission_id"`
+}
+
+func (*RolePermission) Table() string {
+	return "role_permissions"
+}
+
+type UserRole struct {
+	model.BaseModel
+	ID     string `json:"id"      db:"id,primary"`
+	UserID string `json:"user_id" db:"user_id"`
+	RoleID string `json:"role_id" db:"role_id"`
+}
+
+func (*UserRole) Table() string {
+	return "user_roles"
+}
+
+// RolesMigration creates the roles, permissions, role_permissions and
+// user_roles tables.
+func RolesMigration(name string) *migrate.Migration {
+	tables := []*schema.CreateTableBuilder{
+		schema.Create("roles", func(table *schema.Blueprint) {
+			table.String("id").Primary()
+			table.String("name").Unique()
+		}),
+		schema.Create("permissions", func(table *schema.Blueprint) {
+			table.String("id").Primary()
+			table.String("name").Unique()
+		}),
+		schema.Create("role_permissions", func(table *schema.Blueprint) {
+			table.String("id").Primary()
+			table.String("role_id")
+			table.String("permission_id")
+			table.Index("role_permissions-role_id-permission_id").AddColumn("role_id").AddColumn("permission_id").Unique()
+		}),
+		schema.Create("user_roles", func(table *schema.Blueprint) {
+			table.String("id").Primary()
+			table.String("user_id")
+			table.String("role_id")
+			table.Index("user_roles-user_id-role_id").AddColumn("user_id").AddColumn("role_id").Unique()
+		}),
+	}
+	return &migrate.Migration{
+		Name: name,
+		Up: schema.Run(func(ctx context.Context, tx database.DB) error {
+			for _, t := range tables {
+				err := t.Run(ctx, tx)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+		Down: schema.Run(func(ctx context.Context, tx database.DB) error {
+			for _, t := range []string{"user_roles", "role_permissions", "permissions", "roles"} {
+				err := schema.DropIfExists(t).Run(ctx, tx)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	}
+}
+
+// CreateRole creates a role with the permissions, creating any permissions
+// that do not exist yet.
+func CreateRole(ctx context.Context, tx database.DB, name string, permissions ...string) (*Role, error) {
+	r := &Role{
+		ID:   uuid.NewString(),
+		Name: name,
+	}
+	err := model.SaveContext(ctx, tx, r)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range permissions {
+		p, err := builder.From[*Permission]().
+			WithContext(ctx).
+			Where("name", "=", name).
+			First(tx)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			p = &Permission{
+				ID:   uuid.NewString(),
+				Name: name,
+			}
+			err = model.SaveContext(ctx, tx, p)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = model.SaveContext(ctx, tx, &RolePermission{
+			ID:           uuid.NewString(),
+			RoleID:       r.ID,
+			PermissionID: p.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// AssignRole gives the user the role.
+func AssignRole(ctx context.Context, tx database.DB, userID, role string) error {
+	r, err := findRole(ctx, tx, role)
+	if err != nil {
+		return err
+	}
+	return model.SaveContext(ctx, tx, &UserRole{
+		ID:     uuid.NewString(),
+		UserID: userID,
+		RoleID: r.ID,
+	})
+}
+
+// RemoveRole takes the role away from the user.
+func RemoveRole(ctx context.Context, tx database.DB, userID, role string) error {
+	r, err := findRole(ctx, tx, role)
+	if err != nil {
+		return err
+	}
+	return builder.From[*UserRole]().
+		WithContext(ctx).
+		Where("user_id", "=", userID).
+		Where("role_id", "=", r.ID).
+		Delete(tx)
+}
+
+// UserRoles returns the names of the users roles and the permissions granted
+// by them.
+func UserRoles(ctx context.Context, tx database.DB, userID string) (roles []string, permissions []string, err error) {
+	rs, err := builder.From[*Role]().
+		WithContext(ctx).
+		Join("user_roles", "user_roles.role_id", "=", "roles.id").
+		Where("user_roles.user_id", "=", userID).
+		OrderBy("roles.name").
+		Get(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ps, err := builder.From[*Permission]().
+		WithContext(ctx).
+		Join("role_permissions", "role_permissions.permission_id", "=", "permissions.id").
+		Join("user_roles", "user_roles.role_id", "=", "role_permissions.role_id").
+		Where("user_roles.user_id", "=", userID).
+		Distinct().
+		OrderBy("permissions.name").
+		Get(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roles = make([]string, len(rs))
+	for i, r := range rs {
+		roles[i] = r.Name
+	}
+	permissions = make([]string, len(ps))
+	for i, p := range ps {
+		permissions[i] = p.Name
+	}
+	return roles, permissions, nil
+}
+
+func findRole(ctx context.Context, tx database.DB, name string) (*Role, error) {
+	r, err := builder.From[*Role]().
+		WithContext(ctx).
+		Where("name", "=", name).
+		First(tx)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownRole, name)
+	}
+	return r, nil
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"crypto/subtle"
 	"embed"
 	"errors"
 	"fmt"
@@ -18,6 +19,7 @@ import (
 	"github.com/abibby/salusa/email"
 	"github.com/abibby/salusa/internal/helpers"
 	"github.com/abibby/salusa/request"
+	"github.com/abibby/salusa/request/rules"
 	"github.com/abibby/salusa/router"
 	"github.com/abibby/salusa/view"
 	"github.com/go-openapi/spec"
@@ -41,6 +43,24 @@ type AuthController interface {
 	ChangePassword() http.Handler
 	Refresh() http.Handler
 	ForgotPassword() http.Handler
+	Logout() http.Handler
+	LogoutAll() http.Handler
+	// SessionLogin and SessionLogout return nil if the controller has no
+	// session guard.
+	SessionLogin() http.Handler
+	SessionLogout() http.Handler
+	TwoFactorEnable() http.Handler
+	TwoFactorConfirm() http.Handler
+	TwoFactorDisable() http.Handler
+	TwoFactorLogin() http.Handler
+	// SessionTwoFactorLogin returns nil if the controller has no session
+	// guard.
+	SessionTwoFactorLogin() http.Handler
+	PersonalAccessTokenCreate() http.Handler
+	PersonalAccessTokenList() http.Handler
+	PersonalAccessTokenRevoke() http.Handler
+	PasswordlessSend() http.Handler
+	PasswordlessLogin() http.Handler
 }
 
 type BasicAuthController[T User] struct {
@@ -50,8 +70,15 @@ type BasicAuthController[T User] struct {
 type basicAuthController struct {
 	createUserHandler   func(controller any) http.Handler
 	resetPasswordName   string
+	loginLinkName       string
+	loginTokenTTL       time.Duration
 	accessTokenOptions  func(u any, claims *Claims) jwt.Claims
 	refreshTokenOptions func(u any, claims *Claims) jwt.Claims
+	sessions            *SessionGuard
+	twoFactorIssuer     string
+	roleClaims          bool
+	throttle            *LoginThrottle
+	passwordPolicy      *rules.PasswordPolicy
 }
 
 type AuthOption func(a *basicAuthController) *basicAuthController
@@ -87,6 +114,52 @@ func RefreshTokenOptions[T User](cb func(u T, claims *Claims) jwt.Claims) AuthOp
 		return a
 	}
 }
+
+// Sessions adds session login and logout routes that use the guard.
+func Sessions(guard *SessionGuard) AuthOption {
+	return func(a *basicAuthController) *basicAuthController {
+		a.sessions = guard
+		return a
+	}
+}
+
+// TwoFactorIssuer sets the issuer shown in authenticator apps, the default is
+// Salusa.
+func TwoFactorIssuer(issuer string) AuthOption {
+	return func(a *basicAuthController) *basicAuthController {
+		a.twoFactorIssuer = issuer
+		return a
+	}
+}
+
+// RoleClaims adds the users roles and permissions to the claims of issued
+// access tokens and sessions. It requires the tables from RolesMigration.
+func RoleClaims() AuthOption {
+	return func(a *basicAuthController) *basicAuthController {
+		a.roleClaims = true
+		return a
+	}
+}
+
+// Throttle limits failed logins and password reset requests per username
+// and ip.
+func Throttle(throttle *LoginThrottle) AuthOption {
+	return func(a *basicAuthController) *basicAuthController {
+		a.throttle = throttle
+		return a
+	}
+}
+
+// PasswordPolicy checks new passwords when users are created, change their
+// password or reset it. Without a policy passwords are only checked against
+// the bcrypt length limit.
+func PasswordPolicy(policy *rules.PasswordPolicy) AuthOption {
+	return func(a *basicAuthController) *basicAuthController {
+		a.passwordPolicy = policy
+		return a
+	}
+}
+
 func ResetPasswordName(name string) AuthOption {
 	return func(a *basicAuthController) *basicAuthController {
 		a.resetPasswordName = name
@@ -94,6 +167,24 @@ func ResetPasswordName(name string) AuthOption {
 	}
 }
 
+// LoginLinkName sets the route used for magic links in passwordless login
+// emails, the default is login-link. The route gets the token as a parameter.
+func LoginLinkName(name string) AuthOption {
+	return func(a *basicAuthController) *basicAuthController {
+		a.loginLinkName = name
+		return a
+	}
+}
+
+// LoginTokenTTL sets how long passwordless login links and codes are valid,
+// the default is 15 minutes.
+func LoginTokenTTL(ttl time.Duration) AuthOption {
+	return func(a *basicAuthController) *basicAuthController {
+		a.loginTokenTTL = ttl
+		return a
+	}
+}
+
 //go:embed emails/*
 var emails embed.FS
 var defaultViewTemplate = view.NewViewTemplate(emails, "**/*.html")
@@ -104,6 +195,10 @@ func NewBasicAuthController[T User](options ...AuthOption) *BasicAuthController[
 			return nil
 		},
 		resetPasswordName: "reset-password",
+		passwordPolicy:    rules.NewPasswordPolicy().Min(0),
+		loginLinkName:     "login-link",
+		loginTokenTTL:     15 * time.Minute,
+		twoFactorIssuer:   "Salusa",
 		accessTokenOptions: func(u any, claims *Claims) jwt.Claims {
 			return claims
 		},
@@ -127,12 +222,33 @@ func RegisterRoutes(r *router.Router, controller AuthController) {
 	r.Post("/user", controller.UserCreate()).Name("auth.user.create")
 	r.Get("/user/verify", controller.VerifyEmail()).Name("auth.email.verify")
 	r.Post("/login/refresh", controller.Refresh()).Name("auth.refresh")
+	r.Post("/login/2fa", controller.TwoFactorLogin()).Name("auth.2fa.login")
+	r.Post("/login/passwordless", controller.PasswordlessSend()).Name("auth.passwordless.send")
+	r.Post("/login/passwordless/redeem", controller.PasswordlessLogin()).Name("auth.passwordless.login")
+	if h := controller.SessionLogin(); h != nil {
+		r.Post("/session/login", h).Name("auth.session.login")
+	}
+	if h := controller.SessionTwoFactorLogin(); h != nil {
+		r.Post("/session/login/2fa", h).Name("auth.session.2fa.login")
+	}
+	if h := controller.SessionLogout(); h != nil {
+		r.Post("/session/logout", h).Name("auth.session.logout")
+	}
 
 	r.Group("", func(r *router.Router) {
 		r.Use(AttachUser())
 		r.Use(LoggedIn())
+		r.Use(RejectPersonalAccessTokens())
 
 		r.Post("/user/password/change", controller.ChangePassword()).Name("auth.password.change")
+		r.Post("/logout", controller.Logout()).Name("auth.logout")
+		r.Post("/logout/all", controller.LogoutAll()).Name("auth.logout.all")
+		r.Post("/user/2fa/enable", controller.TwoFactorEnable()).Name("auth.2fa.enable")
+		r.Post("/user/2fa/confirm", controller.TwoFactorConfirm()).Name("auth.2fa.confirm")
+		r.Post("/user/2fa/disable", controller.TwoFactorDisable()).Name("auth.2fa.disable")
+		r.Post("/user/tokens", controller.PersonalAccessTokenCreate()).Name("auth.tokens.create")
+		r.Get("/user/tokens", controller.PersonalAccessTokenList()).Name("auth.tokens.list")
+		r.Delete("/user/tokens/{id}", controller.PersonalAccessTokenRevoke()).Name("auth.tokens.revoke")
 	})
 }
 
@@ -170,7 +286,12 @@ func (o *BasicAuthController[T]) RunUserCreate(u T, r *UserCreateRequest) (*User
 		}
 	}
 
-	err := r.Update(func(tx *sqlx.Tx) error {
+	err := o.checkPassword(r.Ctx, u, "password", r.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.Update(func(tx *sqlx.Tx) error {
 		err := model.SaveContext(r.Ctx, tx, u)
 		if err != nil {
 			return err
@@ -208,15 +329,21 @@ type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 
-	Ctx  context.Context `inject:""`
-	Read database.Read   `inject:""`
-	Log  *slog.Logger    `inject:""`
+	Ctx     context.Context `inject:""`
+	Read    database.Read   `inject:""`
+	Update  database.Update `inject:""`
+	Log     *slog.Logger    `inject:""`
+	Request *http.Request   `inject:""`
 }
 type LoginResponse struct {
 	AccessToken  string `json:"token"`
 	TokenType    string `json:"token_type"`
 	RefreshToken string `json:"refresh"`
 	ExpiresIn    int    `json:"expires_in"`
+	// MFAToken is set instead of the other fields when the user has two
+	// factor authentication enabled. Exchange it and a code for tokens with
+	// TwoFactorLogin.
+	MFAToken string `json:"mfa_token,omitempty"`
 }
 
 func (o *BasicAuthController[T]) Login() http.Handler {
@@ -225,61 +352,142 @@ func (o *BasicAuthController[T]) Login() http.Handler {
 	})
 }
 func (o *BasicAuthController[T]) RunLogin(r *LoginRequest) (*LoginResponse, error) {
-	u, err := helpers.NewOf[T]()
+	u, err := o.checkCredentials(r.Ctx, r.Read, r.Log, r.Request, r.Username, r.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *LoginResponse
+	err = r.Update(func(tx *sqlx.Tx) error {
+		resp, err = o.CompleteLogin(r.Ctx, tx, u)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return resp, nil
+}
+
+// CompleteLogin issues access and refresh tokens for a user that has already
+// been authenticated. If the user has two factor authentication enabled only
+// an mfa token is returned.
+func (o *BasicAuthController[T]) CompleteLogin(ctx context.Context, tx database.DB, u T) (*LoginResponse, error) {
+	if requiresTwoFactor(u) {
+		token, err := mfaPendingToken(ctx, tx, u)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResponse{MFAToken: token}, nil
+	}
+	return o.issueTokens(ctx, tx, u, "")
+}
+
+// checkCredentials returns the user with the username and password. Failures
+// are recorded with the throttle if one is set.
+func (o *BasicAuthController[T]) checkCredentials(ctx context.Context, read database.Read, log *slog.Logger, req *http.Request, username, password string) (T, error) {
+	if o.throttle == nil {
+		return o.findByCredentials(ctx, read, log, username, password)
+	}
+
+	var zero T
+	ip := clientIP(req)
+	err := o.throttle.Attempt(ctx, "login", username, ip)
+	if err != nil {
+		log.Info("throttled login attempt", "username", username, "ip", ip)
+		return zero, err
+	}
+	u, err := o.findByCredentials(ctx, read, log, username, password)
+	if err != nil {
+		return zero, o.attemptFailed(ctx, log, "login", username, ip, err)
+	}
+	err = o.throttle.Succeed(ctx, "login", username, ip)
+	if err != nil {
+		return zero, err
+	}
+	return u, nil
+}
+
+// attemptFailed confirms that an attempt recorded with the throttle failed
+// with err and returns err.
+func (o *BasicAuthController[T]) attemptFailed(ctx context.Context, log *slog.Logger, action, username, ip string, err error) error {
+	throttleErr := o.throttle.AttemptFailed(ctx, log, action, username, ip)
+	if throttleErr != nil {
+		return errors.Join(err, throttleErr)
+	}
+	return err
+}
+
+func (o *BasicAuthController[T]) findByCredentials(ctx context.Context, read database.Read, log *slog.Logger, username, password string) (T, error) {
+	var zero T
+	u, err := helpers.NewOf[T]()
+	if err != nil {
+		return zero, err
+	}
 	userColumns := u.UsernameColumns()
 	if len(userColumns) == 0 {
 		panic("need columns")
 	}
 
-	q := builder.From[T]().WithContext(r.Ctx)
+	q := builder.From[T]().WithContext(ctx)
 	for _, column := range userColumns {
-		q = q.OrWhere(column, "=", strings.ToLower(r.Username))
+		q = q.OrWhere(column, "=", strings.ToLower(username))
 	}
 
-	u, err = database.Value(r.Read, func(tx *sqlx.Tx) (T, error) {
+	u, err = database.Value(read, func(tx *sqlx.Tx) (T, error) {
 		return q.First(tx)
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to log in: %w", err)
+		return zero, fmt.Errorf("failed to log in: %w", err)
 	}
 	if reflect.ValueOf(u).IsNil() {
-		r.Log.Info("login attempt with unknown username", "username", r.Username)
-		return nil, request.NewHTTPError(ErrInvalidUserPass, http.StatusUnauthorized)
+		log.Info("login attempt with unknown username", "username", username)
+		return zero, request.NewHTTPError(ErrInvalidUserPass, http.StatusUnauthorized)
 	}
 
 	if v, ok := cast[EmailVerified](u); ok {
 		if !v.IsVerified() {
-			return nil, Err401Unauthorized
+			return zero, Err401Unauthorized
 		}
 	}
 
-	err = bcrypt.CompareHashAndPassword(u.GetPasswordHash(), u.SaltedPassword(r.Password))
+	err = bcrypt.CompareHashAndPassword(u.GetPasswordHash(), u.SaltedPassword(password))
 	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-		r.Log.Info("login attempt with incorrect password", "username", r.Username, "user_id", u.GetID())
-		return nil, request.NewHTTPError(ErrInvalidUserPass, http.StatusUnauthorized)
+		log.Info("login attempt with incorrect password", "username", username, "user_id", u.GetID())
+		return zero, request.NewHTTPError(ErrInvalidUserPass, http.StatusUnauthorized)
 	} else if err != nil {
-		return nil, fmt.Errorf("could not check password hash: %w", err)
+		return zero, fmt.Errorf("could not check password hash: %w", err)
 	}
+	return u, nil
+}
 
+// issueTokens creates an access token and a refresh token in the family
+// familyID, if familyID is empty a new family is started.
+func (o *BasicAuthController[T]) issueTokens(ctx context.Context, tx database.DB, u T, familyID string) (*LoginResponse, error) {
 	expires := time.Hour
+	refreshExpires := time.Hour * 24 * 30
 
-	access, err := GenerateToken(
-		o.accessTokenOptions(u, NewClaims().
-			WithSubject(u.GetID()).
-			WithLifetime(expires).
-			WithScopes(ScopeAccess),
-		),
-	)
+	claims, err := o.withRoleClaims(ctx, tx, u, NewClaims().
+		WithSubject(u.GetID()).
+		WithJWTID(uuid.NewString()).
+		WithLifetime(expires).
+		WithScopes(ScopeAccess))
+	if err != nil {
+		return nil, err
+	}
+	access, err := GenerateToken(o.accessTokenOptions(u, claims))
 	if err != nil {
 		return nil, fmt.Errorf("could not generate token: %w", err)
 	}
+
+	t, err := createRefreshToken(ctx, tx, u.GetID(), familyID, refreshExpires)
+	if err != nil {
+		return nil, err
+	}
 	refresh, err := GenerateToken(
 		o.refreshTokenOptions(u, NewClaims().
 			WithSubject(u.GetID()).
-			WithLifetime(time.Hour*24*30).
+			WithJWTID(t.ID).
+			WithLifetime(refreshExpires).
 			WithScopes(ScopeRefresh),
 		),
 	)
@@ -295,6 +503,123 @@ func (o *BasicAuthController[T]) RunLogin(r *LoginRequest) (*LoginResponse, erro
 	}, nil
 }
 
+type SessionLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	Ctx      context.Context     `inject:""`
+	Read     database.Read       `inject:""`
+	Update   database.Update     `inject:""`
+	Log      *slog.Logger        `inject:""`
+	Request  *http.Request       `inject:""`
+	Response http.ResponseWriter `inject:""`
+}
+type SessionLoginResponse struct {
+	CSRFToken string `json:"csrf_token,omitempty"`
+	// MFAToken is set instead of starting a session when the user has two
+	// factor authentication enabled. Exchange it and a code for a session
+	// with SessionTwoFactorLogin.
+	MFAToken string `json:"mfa_token,omitempty"`
+}
+
+func (o *BasicAuthController[T]) SessionLogin() http.Handler {
+	if o.sessions == nil {
+		return nil
+	}
+	return request.Handler(o.RunSessionLogin).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunSessionLogin checks the username and password and starts a session. The
+// response contains the csrf token needed for state changing requests.
+func (o *BasicAuthController[T]) RunSessionLogin(r *SessionLoginRequest) (*SessionLoginResponse, error) {
+	u, err := o.checkCredentials(r.Ctx, r.Read, r.Log, r.Request, r.Username, r.Password)
+	if err != nil {
+		return nil, err
+	}
+	if requiresTwoFactor(u) {
+		var token string
+		err = r.Update(func(tx *sqlx.Tx) error {
+			token, err = mfaPendingToken(r.Ctx, tx, u)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &SessionLoginResponse{MFAToken: token}, nil
+	}
+
+	var resp *SessionLoginResponse
+	err = r.Read(func(tx *sqlx.Tx) error {
+		resp, err = o.startSession(r.Ctx, tx, r.Response, u)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (o *BasicAuthController[T]) startSession(ctx context.Context, tx database.DB, w http.ResponseWriter, u T) (*SessionLoginResponse, error) {
+	claims, err := o.withRoleClaims(ctx, tx, u, NewClaims().
+		WithSubject(u.GetID()).
+		WithScopes(ScopeAccess))
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := o.accessTokenOptions(u, claims).(*Claims); ok {
+		claims = c
+	}
+
+	s, err := o.sessions.Login(ctx, w, claims)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionLoginResponse{
+		CSRFToken: s.CSRFToken,
+	}, nil
+}
+
+// logoutSessions ends every session of the user if the controller has a
+// session guard.
+func (o *BasicAuthController[T]) logoutSessions(ctx context.Context, userID string) error {
+	if o.sessions == nil {
+		return nil
+	}
+	return o.sessions.LogoutUser(ctx, userID)
+}
+
+type SessionLogoutRequest struct {
+	Request  *http.Request       `inject:""`
+	Response http.ResponseWriter `inject:""`
+}
+
+func (o *BasicAuthController[T]) SessionLogout() http.Handler {
+	if o.sessions == nil {
+		return nil
+	}
+	return request.Handler(o.RunSessionLogout).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunSessionLogout ends the current session and removes the session cookie.
+func (o *BasicAuthController[T]) RunSessionLogout(r *SessionLogoutRequest) (*LogoutResponse, error) {
+	_, err := o.sessions.Authenticate(r.Request)
+	if errors.Is(err, ErrCSRFMismatch) {
+		return nil, request.NewHTTPError(err, http.StatusForbidden)
+	} else if err != nil && !errors.Is(err, ErrNoCredentials) {
+		return nil, err
+	}
+
+	err = o.sessions.Logout(r.Response, r.Request)
+	if err != nil {
+		return nil, err
+	}
+	return &LogoutResponse{}, nil
+}
+
 type VerifyEmailRequest struct {
 	Token  string             `query:"token"`
 	Update database.Update    `inject:""`
@@ -362,6 +687,9 @@ func (o *BasicAuthController[T]) ResetPassword() http.Handler {
 		Tags: []string{"auth"},
 	})
 }
+
+// RunResetPassword sets the password of the user with the reset token and
+// ends all of their sessions.
 func (o *BasicAuthController[T]) RunResetPassword(r *ResetPasswordRequest) (*ResetPasswordResponse[T], error) {
 	u, err := helpers.NewOf[T]()
 	if err != nil {
@@ -388,6 +716,11 @@ func (o *BasicAuthController[T]) RunResetPassword(r *ResetPasswordRequest) (*Res
 			return Err401Unauthorized
 		}
 
+		err = o.checkPassword(r.Ctx, u, "password", r.Password)
+		if err != nil {
+			return err
+		}
+
 		v.SetLookupToken("")
 
 		err = updatePassword(u, r.Password)
@@ -401,6 +734,10 @@ func (o *BasicAuthController[T]) RunResetPassword(r *ResetPasswordRequest) (*Res
 	if err != nil {
 		return nil, err
 	}
+	err = o.logoutSessions(r.Ctx, u.GetID())
+	if err != nil {
+		return nil, err
+	}
 	return &ResetPasswordResponse[T]{
 		User: u,
 	}, nil
@@ -414,6 +751,7 @@ type ForgotPasswordRequest struct {
 	Logger   *slog.Logger       `inject:""`
 	URL      router.URLResolver `inject:""`
 	Template *view.ViewTemplate `inject:",optional"`
+	Request  *http.Request      `inject:""`
 }
 type ForgotPasswordResponse struct {
 }
@@ -436,6 +774,19 @@ func (o *BasicAuthController[T]) RunForgotPassword(r *ForgotPasswordRequest) (*F
 	if len(userColumns) == 0 {
 		panic("need columns")
 	}
+	if o.throttle != nil {
+		// Every request counts as a failure so reset emails can't be used to
+		// flood an inbox.
+		ip := clientIP(r.Request)
+		err = o.throttle.Attempt(r.Ctx, "forgot-password", r.Email, ip)
+		if err != nil {
+			return nil, err
+		}
+		err = o.throttle.AttemptFailed(r.Ctx, r.Logger, "forgot-password", r.Email, ip)
+		if err != nil {
+			return nil, err
+		}
+	}
 	err = r.Update(func(tx *sqlx.Tx) error {
 		q := builder.From[T]().WithContext(r.Ctx)
 		for _, column := range userColumns {
@@ -468,105 +819,886 @@ func (o *BasicAuthController[T]) RunForgotPassword(r *ForgotPasswordRequest) (*F
 	return &ForgotPasswordResponse{}, err
 }
 
-type ChangePasswordRequest[T User] struct {
-	OldPassword string          `json:"old_password"`
-	NewPassword string          `json:"new_password"`
-	User        T               `inject:""`
-	Update      database.Update `inject:""`
-	Ctx         context.Context `inject:""`
+type PasswordlessSendRequest struct {
+	Email    string             `json:"email" validate:"required|email"`
+	Method   string             `json:"method"`
+	Update   database.Update    `inject:""`
+	Ctx      context.Context    `inject:""`
+	Mailer   email.Mailer       `inject:""`
+	Logger   *slog.Logger       `inject:""`
+	URL      router.URLResolver `inject:""`
+	Template *view.ViewTemplate `inject:",optional"`
+	Request  *http.Request      `inject:""`
 }
-type ChangePasswordResponse[T User] struct {
-	User T `json:"user"`
+type PasswordlessSendResponse struct {
 }
 
-func (o *BasicAuthController[T]) ChangePassword() http.Handler {
-	return request.Handler(o.RunChangePassword).Docs(&spec.OperationProps{
+func (o *BasicAuthController[T]) PasswordlessSend() http.Handler {
+	return request.Handler(o.RunPasswordlessSend).Docs(&spec.OperationProps{
 		Tags: []string{"auth"},
 	})
 }
-func (o *BasicAuthController[T]) RunChangePassword(r *ChangePasswordRequest[T]) (*ChangePasswordResponse[T], error) {
-	err := bcrypt.CompareHashAndPassword(r.User.GetPasswordHash(), r.User.SaltedPassword(r.OldPassword))
-	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-		return nil, Err401Unauthorized
-	} else if err != nil {
-		return nil, fmt.Errorf("could not check password hash: %w", err)
-	}
 
-	err = updatePassword(r.User, r.NewPassword)
+// RunPasswordlessSend emails a login link, or a 6 digit code if the method is
+// code, to a verified user. The response is the same whether or not the email
+// belongs to a user.
+func (o *BasicAuthController[T]) RunPasswordlessSend(r *PasswordlessSendRequest) (*PasswordlessSendResponse, error) {
+	u, err := helpers.NewOf[T]()
 	if err != nil {
 		return nil, err
 	}
+	_, ok := cast[EmailVerified](u)
+	if !ok {
+		return nil, ErrNonEmailVerifiedUser
+	}
+	method := r.Method
+	if method == "" {
+		method = LoginMethodLink
+	}
+	if method != LoginMethodLink && method != LoginMethodCode {
+		return nil, request.NewHTTPError(ErrInvalidLoginMethod, http.StatusUnprocessableEntity)
+	}
+	if o.throttle != nil {
+		// Every request counts as a failure so login emails can't be used to
+		// flood an inbox.
+		ip := clientIP(r.Request)
+		err = o.throttle.Attempt(r.Ctx, "passwordless-send", r.Email, ip)
+		if err != nil {
+			return nil, err
+		}
+		err = o.throttle.AttemptFailed(r.Ctx, r.Logger, "passwordless-send", r.Email, ip)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	err = r.Update(func(tx *sqlx.Tx) error {
-		return model.SaveContext(r.Ctx, tx, r.User)
+		u, err := o.findByEmail(r.Ctx, tx, r.Email)
+		if err != nil {
+			return err
+		}
+		if reflect.ValueOf(u).IsNil() {
+			r.Logger.Info("passwordless login attempt for unused email", slog.String("email", r.Email))
+			return nil
+		}
+		v := mustCast[EmailVerified](u)
+		if !v.IsVerified() {
+			r.Logger.Info("passwordless login attempt for unverified email", slog.String("email", r.Email))
+			return nil
+		}
+
+		token, err := createLoginToken(r.Ctx, tx, u.GetID(), method, o.loginTokenTTL)
+		if err != nil {
+			return err
+		}
+
+		data := map[string]any{
+			"LoginLinkName": o.loginLinkName,
+		}
+		if method == LoginMethodCode {
+			data["Code"] = token
+		} else {
+			data["Token"] = token
+		}
+		o.mail(&sendEmailOptions{
+			URL:          r.URL,
+			ViewTemplate: r.Template,
+			User:         v,
+			Mailer:       r.Mailer,
+			Logger:       r.Logger,
+			TemplateName: "login_link.html",
+			Subject:      "Log in",
+		}, data)
+		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
-
-	return &ChangePasswordResponse[T]{
-		User: r.User,
-	}, nil
+	return &PasswordlessSendResponse{}, nil
 }
 
-type RefreshRequest[T User] struct {
-	RefreshToken string          `json:"refresh"`
-	Read         database.Read   `inject:""`
-	Ctx          context.Context `inject:""`
+type PasswordlessLoginRequest struct {
+	Email   string          `json:"email"`
+	Token   string          `json:"token"`
+	Code    string          `json:"code"`
+	Update  database.Update `inject:""`
+	Ctx     context.Context `inject:""`
+	Log     *slog.Logger    `inject:""`
+	Request *http.Request   `inject:""`
 }
 
-func (o *BasicAuthController[T]) Refresh() http.Handler {
-	return request.Handler(o.RunRefresh).Docs(&spec.OperationProps{
+func (o *BasicAuthController[T]) PasswordlessLogin() http.Handler {
+	return request.Handler(o.RunPasswordlessLogin).Docs(&spec.OperationProps{
 		Tags: []string{"auth"},
 	})
 }
-func (o *BasicAuthController[T]) RunRefresh(r *RefreshRequest[T]) (*LoginResponse, error) {
-	claims, err := Parse(r.RefreshToken)
-	if err != nil {
-		return nil, err
+
+// RunPasswordlessLogin redeems a login link token, or an email and code, and
+// logs the user in. Users with two factor authentication get an MFA token.
+func (o *BasicAuthController[T]) RunPasswordlessLogin(r *PasswordlessLoginRequest) (*LoginResponse, error) {
+	if r.Token == "" && (r.Email == "" || r.Code == "") {
+		return nil, request.NewHTTPError(ErrInvalidLoginToken, http.StatusUnprocessableEntity)
 	}
 
-	if !slices.Contains(claims.Scope, ScopeRefresh) {
-		return nil, request.NewHTTPError(fmt.Errorf("invalid token"), http.StatusUnauthorized)
+	ip := clientIP(r.Request)
+	key := r.Email
+	if r.Token != "" {
+		// links don't send an email, throttle attempts on the token instead
+		// of sharing one empty username between every link
+		key = hashLoginToken(r.Token)
+	}
+	if o.throttle != nil {
+		err := o.throttle.Attempt(r.Ctx, "passwordless-login", key, ip)
+		if err != nil {
+			r.Log.Info("throttled passwordless login attempt", "email", r.Email, "ip", ip)
+			return nil, err
+		}
 	}
 
-	var u T
-	err = r.Read(func(tx *sqlx.Tx) error {
-		u, err = builder.From[T]().
-			WithContext(r.Ctx).
-			Find(tx, claims.Subject)
+	var resp *LoginResponse
+	invalid := false
+	err := r.Update(func(tx *sqlx.Tx) error {
+		u, err := o.redeemLoginToken(r, tx)
+		if errors.Is(err, ErrInvalidLoginToken) {
+			// Commit so used, expired and guessed tokens are still removed.
+			invalid = true
+			return nil
+		} else if err != nil {
+			return err
+		}
+		resp, err = o.CompleteLogin(r.Ctx, tx, u)
 		return err
 	})
-	if err != nil {
-		return nil, request.NewHTTPError(fmt.Errorf("failed to verify: %w", err), http.StatusUnauthorized)
+	if err == nil && invalid {
+		err = request.NewHTTPError(ErrInvalidLoginToken, http.StatusUnauthorized)
 	}
-
-	if reflect.ValueOf(u).IsNil() {
-		return nil, request.NewHTTPError(fmt.Errorf("no user found"), http.StatusUnauthorized)
+	if err != nil {
+		if o.throttle != nil {
+			return nil, o.attemptFailed(r.Ctx, r.Log, "passwordless-login", key, ip, err)
+		}
+		return nil, err
 	}
+	if o.throttle != nil {
+		err = o.throttle.Succeed(r.Ctx, "passwordless-login", key, ip)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
 
-	expires := time.Hour
-
-	access, err := GenerateToken(
-		o.accessTokenOptions(u, NewClaims().
-			WithSubject(u.GetID()).
-			WithLifetime(expires).
-			WithIssuedAtTime(time.Now()).
-			WithScopes(ScopeAccess),
-		),
-	)
+func (o *BasicAuthController[T]) redeemLoginToken(r *PasswordlessLoginRequest, tx *sqlx.Tx) (T, error) {
+	var zero T
+	var u T
+	var err error
+	if r.Token != "" {
+		userID, err := redeemLoginLink(r.Ctx, tx, r.Token)
+		if err != nil {
+			return zero, err
+		}
+		u, err = builder.From[T]().WithContext(r.Ctx).Find(tx, userID)
+		if err != nil {
+			return zero, err
+		}
+	} else {
+		u, err = o.findByEmail(r.Ctx, tx, r.Email)
+		if err != nil {
+			return zero, err
+		}
+		if reflect.ValueOf(u).IsNil() {
+			r.Log.Info("passwordless login attempt with unknown email", "email", r.Email)
+			return zero, ErrInvalidLoginToken
+		}
+		err = redeemLoginCode(r.Ctx, tx, u.GetID(), r.Code)
+		if err != nil {
+			return zero, err
+		}
+	}
+	if reflect.ValueOf(u).IsNil() {
+		return zero, ErrInvalidLoginToken
+	}
+	if v, ok := cast[EmailVerified](u); ok && !v.IsVerified() {
+		return zero, ErrInvalidLoginToken
+	}
+	return u, nil
+}
+
+// findByEmail returns the user with the email in one of its username columns
+// or nil if there isn't one.
+func (o *BasicAuthController[T]) findByEmail(ctx context.Context, tx database.DB, email string) (T, error) {
+	var zero T
+	u, err := helpers.NewOf[T]()
 	if err != nil {
-		return nil, request.NewHTTPError(fmt.Errorf("could not generate token: %w", err), http.StatusUnauthorized)
+		return zero, err
+	}
+	userColumns := u.UsernameColumns()
+	if len(userColumns) == 0 {
+		panic("need columns")
+	}
+	q := builder.From[T]().WithContext(ctx)
+	for _, column := range userColumns {
+		q = q.OrWhere(column, "=", strings.ToLower(email))
 	}
+	return q.First(tx)
+}
 
-	return &LoginResponse{
-		AccessToken:  access,
-		TokenType:    "Bearer",
-		RefreshToken: r.RefreshToken,
-		ExpiresIn:    int(expires.Seconds()),
+type ChangePasswordRequest[T User] struct {
+	OldPassword string          `json:"old_password"`
+	NewPassword string          `json:"new_password"`
+	User        T               `inject:""`
+	Update      database.Update `inject:""`
+	Ctx         context.Context `inject:""`
+}
+type ChangePasswordResponse[T User] struct {
+	User T `json:"user"`
+}
+
+func (o *BasicAuthController[T]) ChangePassword() http.Handler {
+	return request.Handler(o.RunChangePassword).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunChangePassword changes the logged in user's password and ends all of
+// their sessions, including the current one.
+func (o *BasicAuthController[T]) RunChangePassword(r *ChangePasswordRequest[T]) (*ChangePasswordResponse[T], error) {
+	err := bcrypt.CompareHashAndPassword(r.User.GetPasswordHash(), r.User.SaltedPassword(r.OldPassword))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return nil, Err401Unauthorized
+	} else if err != nil {
+		return nil, fmt.Errorf("could not check password hash: %w", err)
+	}
+
+	err = o.checkPassword(r.Ctx, r.User, "new_password", r.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	err = updatePassword(r.User, r.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.Update(func(tx *sqlx.Tx) error {
+		return model.SaveContext(r.Ctx, tx, r.User)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.logoutSessions(r.Ctx, r.User.GetID())
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChangePasswordResponse[T]{
+		User: r.User,
 	}, nil
 }
 
+type RefreshRequest[T User] struct {
+	RefreshToken string          `json:"refresh"`
+	Update       database.Update `inject:""`
+	Ctx          context.Context `inject:""`
+	Log          *slog.Logger    `inject:""`
+}
+
+func (o *BasicAuthController[T]) Refresh() http.Handler {
+	return request.Handler(o.RunRefresh).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunRefresh exchanges a refresh token for a new access token and refresh
+// token. Refresh tokens can only be used once, reusing one revokes every
+// token issued from the same login.
+func (o *BasicAuthController[T]) RunRefresh(r *RefreshRequest[T]) (*LoginResponse, error) {
+	claims, err := Parse(r.RefreshToken)
+	if err != nil {
+		return nil, request.NewHTTPError(err, http.StatusUnauthorized)
+	}
+
+	if !slices.Contains(claims.Scope, ScopeRefresh) || claims.ID == "" {
+		return nil, request.NewHTTPError(ErrInvalidToken, http.StatusUnauthorized)
+	}
+
+	var resp *LoginResponse
+	var useErr error
+	err = r.Update(func(tx *sqlx.Tx) error {
+		t, err := useRefreshToken(r.Ctx, tx, claims.ID)
+		if errors.Is(err, ErrRefreshTokenReused) || errors.Is(err, ErrRefreshTokenRevoked) {
+			// returning nil commits the revocation of a reused token's family
+			useErr = err
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		u, err := builder.From[T]().
+			WithContext(r.Ctx).
+			Find(tx, t.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to verify: %w", err)
+		}
+		if reflect.ValueOf(u).IsNil() {
+			return request.NewHTTPError(fmt.Errorf("no user found"), http.StatusUnauthorized)
+		}
+
+		resp, err = o.issueTokens(r.Ctx, tx, u, t.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if useErr != nil {
+		if errors.Is(useErr, ErrRefreshTokenReused) && r.Log != nil {
+			r.Log.Warn("refresh token reused, revoking token family", "user_id", claims.Subject, "jti", claims.ID)
+		}
+		return nil, request.NewHTTPError(useErr, http.StatusUnauthorized)
+	}
+	return resp, nil
+}
+
+type LogoutRequest struct {
+	RefreshToken string          `json:"refresh"`
+	Claims       *Claims         `inject:""`
+	Update       database.Update `inject:""`
+	Ctx          context.Context `inject:""`
+}
+type LogoutResponse struct {
+}
+
+func (o *BasicAuthController[T]) Logout() http.Handler {
+	return request.Handler(o.RunLogout).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunLogout revokes the refresh token, along with every token refreshed from
+// it, and adds the current access token to the denylist.
+func (o *BasicAuthController[T]) RunLogout(r *LogoutRequest) (*LogoutResponse, error) {
+	if r.Claims == nil {
+		return nil, Err401Unauthorized
+	}
+	if r.RefreshToken != "" {
+		claims, err := Parse(r.RefreshToken)
+		if err != nil {
+			return nil, request.NewHTTPError(err, http.StatusUnauthorized)
+		}
+		if claims.Subject != r.Claims.Subject || claims.ID == "" {
+			return nil, request.NewHTTPError(ErrInvalidToken, http.StatusUnauthorized)
+		}
+		err = r.Update(func(tx *sqlx.Tx) error {
+			t, err := builder.From[*RefreshToken]().WithContext(r.Ctx).Find(tx, claims.ID)
+			if err != nil {
+				return err
+			}
+			if t == nil {
+				return nil
+			}
+			return revokeRefreshTokenFamily(r.Ctx, tx, t.FamilyID)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := denyAccessToken(r.Ctx, r.Claims)
+	if err != nil {
+		return nil, err
+	}
+	return &LogoutResponse{}, nil
+}
+
+func (o *BasicAuthController[T]) LogoutAll() http.Handler {
+	return request.Handler(o.RunLogoutAll).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunLogoutAll revokes every refresh token issued to the user, ends all of
+// their sessions and adds the current access token to the denylist. Other
+// access tokens stay valid until they expire.
+func (o *BasicAuthController[T]) RunLogoutAll(r *LogoutRequest) (*LogoutResponse, error) {
+	if r.Claims == nil {
+		return nil, Err401Unauthorized
+	}
+	err := r.Update(func(tx *sqlx.Tx) error {
+		return RevokeRefreshTokens(r.Ctx, tx, r.Claims.Subject)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.logoutSessions(r.Ctx, r.Claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	err = denyAccessToken(r.Ctx, r.Claims)
+	if err != nil {
+		return nil, err
+	}
+	return &LogoutResponse{}, nil
+}
+
+type PersonalAccessTokenCreateRequest struct {
+	Name      string          `json:"name" validate:"required"`
+	Scopes    []string        `json:"scopes"`
+	ExpiresAt *time.Time      `json:"expires_at"`
+	Claims    *Claims         `inject:""`
+	Update    database.Update `inject:""`
+	Ctx       context.Context `inject:""`
+}
+type PersonalAccessTokenCreateResponse struct {
+	// Token is only returned when the token is created.
+	Token               string               `json:"token"`
+	PersonalAccessToken *PersonalAccessToken `json:"personal_access_token"`
+}
+
+func (o *BasicAuthController[T]) PersonalAccessTokenCreate() http.Handler {
+	return request.Handler(o.RunPersonalAccessTokenCreate).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunPersonalAccessTokenCreate creates a personal access token for the
+// logged in user. Tokens can only have scopes the user's own claims have and
+// requests authenticated with a personal access token can't create new ones.
+func (o *BasicAuthController[T]) RunPersonalAccessTokenCreate(r *PersonalAccessTokenCreateRequest) (*PersonalAccessTokenCreateResponse, error) {
+	if slices.Contains(r.Claims.Scope, ScopePersonalAccessToken) {
+		return nil, request.NewHTTPError(fmt.Errorf("%w: personal access tokens can't create tokens", ErrForbidden), http.StatusForbidden)
+	}
+	for _, scope := range r.Scopes {
+		if !slices.Contains(r.Claims.Scope, scope) {
+			return nil, request.NewHTTPError(fmt.Errorf("%w: missing scope %q", ErrForbidden, scope), http.StatusForbidden)
+		}
+	}
+	if r.ExpiresAt != nil && r.ExpiresAt.Before(time.Now()) {
+		return nil, request.NewHTTPError(ErrPersonalAccessTokenExpired, http.StatusUnprocessableEntity)
+	}
+
+	resp := &PersonalAccessTokenCreateResponse{}
+	err := r.Update(func(tx *sqlx.Tx) error {
+		var err error
+		resp.PersonalAccessToken, resp.Token, err = CreatePersonalAccessToken(r.Ctx, tx, r.Claims.Subject, r.Name, r.Scopes, r.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type PersonalAccessTokenListRequest struct {
+	Claims *Claims         `inject:""`
+	Read   database.Read   `inject:""`
+	Ctx    context.Context `inject:""`
+}
+type PersonalAccessTokenListResponse struct {
+	PersonalAccessTokens []*PersonalAccessToken `json:"personal_access_tokens"`
+}
+
+func (o *BasicAuthController[T]) PersonalAccessTokenList() http.Handler {
+	return request.Handler(o.RunPersonalAccessTokenList).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+func (o *BasicAuthController[T]) RunPersonalAccessTokenList(r *PersonalAccessTokenListRequest) (*PersonalAccessTokenListResponse, error) {
+	tokens, err := database.Value(r.Read, func(tx *sqlx.Tx) ([]*PersonalAccessToken, error) {
+		return builder.From[*PersonalAccessToken]().
+			WithContext(r.Ctx).
+			Where("user_id", "=", r.Claims.Subject).
+			OrderBy("created_at").
+			Get(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PersonalAccessTokenListResponse{
+		PersonalAccessTokens: tokens,
+	}, nil
+}
+
+type PersonalAccessTokenRevokeRequest struct {
+	ID     string          `path:"id"`
+	Claims *Claims         `inject:""`
+	Update database.Update `inject:""`
+	Ctx    context.Context `inject:""`
+}
+type PersonalAccessTokenRevokeResponse struct {
+}
+
+func (o *BasicAuthController[T]) PersonalAccessTokenRevoke() http.Handler {
+	return request.Handler(o.RunPersonalAccessTokenRevoke).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+func (o *BasicAuthController[T]) RunPersonalAccessTokenRevoke(r *PersonalAccessTokenRevokeRequest) (*PersonalAccessTokenRevokeResponse, error) {
+	err := r.Update(func(tx *sqlx.Tx) error {
+		return RevokePersonalAccessToken(r.Ctx, tx, r.Claims.Subject, r.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PersonalAccessTokenRevokeResponse{}, nil
+}
+
+type TwoFactorEnableRequest[T User] struct {
+	User   T               `inject:""`
+	Update database.Update `inject:""`
+	Ctx    context.Context `inject:""`
+}
+type TwoFactorEnableResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+func (o *BasicAuthController[T]) TwoFactorEnable() http.Handler {
+	return request.Handler(o.RunTwoFactorEnable).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunTwoFactorEnable generates a new TOTP secret for the user. Two factor
+// authentication is not required until the secret is confirmed with
+// TwoFactorConfirm.
+func (o *BasicAuthController[T]) RunTwoFactorEnable(r *TwoFactorEnableRequest[T]) (*TwoFactorEnableResponse, error) {
+	tf, ok := cast[TwoFactorUser](r.User)
+	if !ok {
+		return nil, request.NewHTTPError(ErrNonTwoFactorUser, http.StatusBadRequest)
+	}
+	if tf.TwoFactorEnabled() {
+		return nil, request.NewHTTPError(ErrTwoFactorEnabled, http.StatusConflict)
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	tf.SetTOTPSecret(secret)
+	err = r.Update(func(tx *sqlx.Tx) error {
+		return model.SaveContext(r.Ctx, tx, r.User)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account := r.User.GetID()
+	if named, ok := cast[interface{ GetUsername() string }](r.User); ok {
+		account = named.GetUsername()
+	}
+	return &TwoFactorEnableResponse{
+		Secret: secret,
+		URI:    TOTPProvisioningURI(secret, o.twoFactorIssuer, account),
+	}, nil
+}
+
+type TwoFactorConfirmRequest[T User] struct {
+	Code   string          `json:"code" validate:"required"`
+	User   T               `inject:""`
+	Update database.Update `inject:""`
+	Ctx    context.Context `inject:""`
+}
+type TwoFactorConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (o *BasicAuthController[T]) TwoFactorConfirm() http.Handler {
+	return request.Handler(o.RunTwoFactorConfirm).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunTwoFactorConfirm enables two factor authentication once the user sends
+// a valid code for the secret from TwoFactorEnable. The response contains
+// recovery codes that can be used once each in place of a TOTP code.
+func (o *BasicAuthController[T]) RunTwoFactorConfirm(r *TwoFactorConfirmRequest[T]) (*TwoFactorConfirmResponse, error) {
+	tf, ok := cast[TwoFactorUser](r.User)
+	if !ok {
+		return nil, request.NewHTTPError(ErrNonTwoFactorUser, http.StatusBadRequest)
+	}
+	if tf.TwoFactorEnabled() {
+		return nil, request.NewHTTPError(ErrTwoFactorEnabled, http.StatusConflict)
+	}
+	if tf.GetTOTPSecret() == "" {
+		return nil, request.NewHTTPError(ErrTwoFactorNotEnrolled, http.StatusBadRequest)
+	}
+	if !checkTOTP(tf, strings.TrimSpace(r.Code)) {
+		return nil, request.NewHTTPError(ErrInvalidTwoFactorCode, http.StatusUnprocessableEntity)
+	}
+
+	codes, err := generateRecoveryCodes(tf)
+	if err != nil {
+		return nil, err
+	}
+	tf.SetTwoFactorEnabled(true)
+	err = r.Update(func(tx *sqlx.Tx) error {
+		return model.SaveContext(r.Ctx, tx, r.User)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorConfirmResponse{
+		RecoveryCodes: codes,
+	}, nil
+}
+
+type TwoFactorDisableRequest[T User] struct {
+	Code   string          `json:"code" validate:"required"`
+	User   T               `inject:""`
+	Update database.Update `inject:""`
+	Ctx    context.Context `inject:""`
+}
+type TwoFactorDisableResponse struct {
+}
+
+func (o *BasicAuthController[T]) TwoFactorDisable() http.Handler {
+	return request.Handler(o.RunTwoFactorDisable).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunTwoFactorDisable turns off two factor authentication after checking a
+// TOTP or recovery code.
+func (o *BasicAuthController[T]) RunTwoFactorDisable(r *TwoFactorDisableRequest[T]) (*TwoFactorDisableResponse, error) {
+	tf, ok := cast[TwoFactorUser](r.User)
+	if !ok {
+		return nil, request.NewHTTPError(ErrNonTwoFactorUser, http.StatusBadRequest)
+	}
+	if !tf.TwoFactorEnabled() {
+		return nil, request.NewHTTPError(ErrTwoFactorNotEnrolled, http.StatusBadRequest)
+	}
+	if !checkTwoFactorCode(tf, r.Code) {
+		return nil, request.NewHTTPError(ErrInvalidTwoFactorCode, http.StatusUnprocessableEntity)
+	}
+
+	tf.SetTwoFactorEnabled(false)
+	tf.SetTOTPSecret("")
+	tf.SetRecoveryCodes(nil)
+	err := r.Update(func(tx *sqlx.Tx) error {
+		return model.SaveContext(r.Ctx, tx, r.User)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorDisableResponse{}, nil
+}
+
+type TwoFactorLoginRequest struct {
+	MFAToken string          `json:"mfa_token" validate:"required"`
+	Code     string          `json:"code" validate:"required"`
+	Update   database.Update `inject:""`
+	Ctx      context.Context `inject:""`
+	Log      *slog.Logger    `inject:""`
+	Request  *http.Request   `inject:""`
+}
+
+func (o *BasicAuthController[T]) TwoFactorLogin() http.Handler {
+	return request.Handler(o.RunTwoFactorLogin).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunTwoFactorLogin exchanges the mfa token from RunLogin and a TOTP or
+// recovery code for access and refresh tokens.
+func (o *BasicAuthController[T]) RunTwoFactorLogin(r *TwoFactorLoginRequest) (*LoginResponse, error) {
+	var resp *LoginResponse
+	var invalid error
+	err := r.Update(func(tx *sqlx.Tx) error {
+		u, err := o.checkTwoFactorLogin(r.Ctx, tx, r.Log, r.Request, r.MFAToken, r.Code)
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			// Commit so the failed attempt is counted against the mfa token.
+			invalid = err
+			return nil
+		} else if err != nil {
+			return err
+		}
+		resp, err = o.issueTokens(r.Ctx, tx, u, "")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if invalid != nil {
+		return nil, invalid
+	}
+	return resp, nil
+}
+
+type SessionTwoFactorLoginRequest struct {
+	MFAToken string              `json:"mfa_token" validate:"required"`
+	Code     string              `json:"code" validate:"required"`
+	Update   database.Update     `inject:""`
+	Ctx      context.Context     `inject:""`
+	Log      *slog.Logger        `inject:""`
+	Request  *http.Request       `inject:""`
+	Response http.ResponseWriter `inject:""`
+}
+
+func (o *BasicAuthController[T]) SessionTwoFactorLogin() http.Handler {
+	if o.sessions == nil {
+		return nil
+	}
+	return request.Handler(o.RunSessionTwoFactorLogin).Docs(&spec.OperationProps{
+		Tags: []string{"auth"},
+	})
+}
+
+// RunSessionTwoFactorLogin exchanges the mfa token from RunSessionLogin and a
+// TOTP or recovery code for a session.
+func (o *BasicAuthController[T]) RunSessionTwoFactorLogin(r *SessionTwoFactorLoginRequest) (*SessionLoginResponse, error) {
+	var resp *SessionLoginResponse
+	var invalid error
+	err := r.Update(func(tx *sqlx.Tx) error {
+		u, err := o.checkTwoFactorLogin(r.Ctx, tx, r.Log, r.Request, r.MFAToken, r.Code)
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			// Commit so the failed attempt is counted against the mfa token.
+			invalid = err
+			return nil
+		} else if err != nil {
+			return err
+		}
+		resp, err = o.startSession(r.Ctx, tx, r.Response, u)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if invalid != nil {
+		return nil, invalid
+	}
+	return resp, nil
+}
+
+// withRoleClaims adds the users roles and permissions to claims when the
+// RoleClaims option is set.
+func (o *BasicAuthController[T]) withRoleClaims(ctx context.Context, tx database.DB, u T, claims *Claims) (*Claims, error) {
+	if !o.roleClaims {
+		return claims, nil
+	}
+	roles, permissions, err := UserRoles(ctx, tx, u.GetID())
+	if err != nil {
+		return nil, err
+	}
+	return claims.WithRoles(roles...).WithPermissions(permissions...), nil
+}
+
+// checkTwoFactorLogin returns the user of the mfa pending token if code is a
+// valid TOTP or recovery code. Used recovery codes are removed from the user.
+// Attempts are recorded with the throttle if one is set.
+func (o *BasicAuthController[T]) checkTwoFactorLogin(ctx context.Context, tx database.DB, log *slog.Logger, req *http.Request, token, code string) (T, error) {
+	var zero T
+	claims, err := Parse(token)
+	if err != nil {
+		return zero, request.NewHTTPError(err, http.StatusUnauthorized)
+	}
+	if !slices.Contains(claims.Scope, ScopeMFAPending) {
+		return zero, request.NewHTTPError(ErrInvalidToken, http.StatusUnauthorized)
+	}
+
+	ip := clientIP(req)
+	if o.throttle == nil {
+		return o.redeemMFAToken(ctx, tx, log, claims, code)
+	}
+
+	err = o.throttle.Attempt(ctx, "two-factor", claims.Subject, ip)
+	if err != nil {
+		log.Info("throttled two factor attempt", "user_id", claims.Subject, "ip", ip)
+		return zero, err
+	}
+	u, err := o.redeemMFAToken(ctx, tx, log, claims, code)
+	if err != nil {
+		return zero, o.attemptFailed(ctx, log, "two-factor", claims.Subject, ip, err)
+	}
+	err = o.throttle.Succeed(ctx, "two-factor", claims.Subject, ip)
+	if err != nil {
+		return zero, err
+	}
+	return u, nil
+}
+
+// redeemMFAToken returns the user of the mfa pending token claims if code is
+// a valid TOTP or recovery code and uses up the token.
+func (o *BasicAuthController[T]) redeemMFAToken(ctx context.Context, tx database.DB, log *slog.Logger, claims *Claims, code string) (T, error) {
+	var zero T
+	u, err := builder.From[T]().
+		WithContext(ctx).
+		Find(tx, claims.Subject)
+	if err != nil {
+		return zero, fmt.Errorf("failed to verify: %w", err)
+	}
+	if reflect.ValueOf(u).IsNil() {
+		return zero, request.NewHTTPError(fmt.Errorf("no user found"), http.StatusUnauthorized)
+	}
+	tf, ok := cast[TwoFactorUser](u)
+	if !ok || !tf.TwoFactorEnabled() {
+		return zero, request.NewHTTPError(ErrTwoFactorNotEnrolled, http.StatusUnauthorized)
+	}
+	if claims.ID == "" || subtle.ConstantTimeCompare([]byte(claims.ID), []byte(tf.GetMFATokenID())) != 1 {
+		return zero, request.NewHTTPError(ErrInvalidToken, http.StatusUnauthorized)
+	}
+	if !checkTwoFactorCode(tf, code) {
+		log.Info("login attempt with incorrect two factor code", "user_id", u.GetID())
+		tf.SetMFAAttempts(tf.GetMFAAttempts() + 1)
+		if tf.GetMFAAttempts() >= maxMFAAttempts {
+			tf.SetMFATokenID("")
+		}
+		err = model.SaveContext(ctx, tx, u)
+		if err != nil {
+			return zero, err
+		}
+		return zero, request.NewHTTPError(ErrInvalidTwoFactorCode, http.StatusUnauthorized)
+	}
+
+	tf.SetMFATokenID("")
+	tf.SetMFAAttempts(0)
+	err = model.SaveContext(ctx, tx, u)
+	if err != nil {
+		return zero, err
+	}
+	return u, nil
+}
+
+// checkPassword returns a request.ValidationError for the attribute if the
+// password fails the controllers password policy.
+func (o *BasicAuthController[T]) checkPassword(ctx context.Context, u User, attribute, password string) error {
+	personal := []string{}
+	for _, col := range u.UsernameColumns() {
+		v, err := helpers.RGetValue(reflect.ValueOf(u), col)
+		if err == nil && v.Kind() == reflect.String {
+			personal = append(personal, v.String())
+		}
+	}
+	if v, ok := cast[EmailVerified](u); ok {
+		personal = append(personal, v.GetEmail())
+	}
+
+	violations, err := o.passwordPolicy.Check(password, personal...)
+	if err != nil {
+		return fmt.Errorf("failed to check password: %w", err)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	vErr := request.ValidationError{}
+	for _, violation := range violations {
+		msg, err := request.RuleMessage(ctx, violation.Rule, &request.MessageOptions{
+			Attribute: attribute,
+			Value:     password,
+			Arguments: violation.Arguments,
+		})
+		if err != nil {
+			return err
+		}
+		vErr.AddError(attribute, msg)
+	}
+	return vErr
+}
+
 func updatePassword(u User, password string) error {
 	hash, err := bcrypt.GenerateFromPassword(u.SaltedPassword(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -592,15 +1724,21 @@ func (o *BasicAuthController[T]) sendEmails(opt *sendEmailOptions) {
 
 	opt.User.SetLookupToken(token)
 
+	o.mail(opt, map[string]any{
+		"ResetPasswordName": o.resetPasswordName,
+		"Token":             token,
+	})
+}
+
+// mail renders the template with data and sends it to the user in the
+// background.
+func (o *BasicAuthController[T]) mail(opt *sendEmailOptions, data map[string]any) {
 	go func() {
 		if opt.ViewTemplate == nil {
 			opt.ViewTemplate = defaultViewTemplate
 		}
 
-		b, err := view.View(opt.TemplateName, map[string]any{
-			"ResetPasswordName": o.resetPasswordName,
-			"Token":             token,
-		}).BytesData(&view.ViewData{
+		b, err := view.View(opt.TemplateName, data).BytesData(&view.ViewData{
 			URL:      opt.URL,
 			Template: opt.ViewTemplate,
 		})
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,12 +15,14 @@ import (
 	"github.com/abibby/salusa/database/dbtest"
 	"github.com/abibby/salusa/database/model"
 	"github.com/abibby/salusa/email/emailtest"
+	"github.com/abibby/salusa/request"
+	"github.com/abibby/salusa/request/rules"
 	"github.com/abibby/salusa/router/routertest"
 	"github.com/abibby/salusa/view"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type DevNull struct{}
@@ -143,6 +146,7 @@ func TestAuthRoutesLogin(t *testing.T) {
 			Username: "user",
 			Password: password,
 			Read:     dbtest.Read(tx),
+			Update:   dbtest.Update(tx),
 			Ctx:      ctx,
 			Log:      nullLogger,
 		})
@@ -167,6 +171,7 @@ func TestAuthRoutesLogin(t *testing.T) {
 			Username: "user",
 			Password: password,
 			Read:     dbtest.Read(tx),
+			Update:   dbtest.Update(tx),
 			Ctx:      ctx,
 			Log:      nullLogger,
 		})
@@ -186,6 +191,7 @@ func TestAuthRoutesLogin(t *testing.T) {
 			Username: "not user",
 			Password: password,
 			Read:     dbtest.Read(tx),
+			Update:   dbtest.Update(tx),
 			Ctx:      ctx,
 			Log:      nullLogger,
 		})
@@ -205,6 +211,7 @@ func TestAuthRoutesLogin(t *testing.T) {
 			Username: "user",
 			Password: "not pass",
 			Read:     dbtest.Read(tx),
+			Update:   dbtest.Update(tx),
 			Ctx:      ctx,
 			Log:      nullLogger,
 		})
@@ -317,38 +324,123 @@ func TestAuthRoutesChangePassword(t *testing.T) {
 }
 
 func TestAuthRoutesRefresh(t *testing.T) {
-	Run(t, "", func(t *testing.T, tx *sqlx.Tx) {
+	login := func(t *testing.T, tx *sqlx.Tx) (*auth.UsernameUser, *auth.LoginResponse) {
 		ctx := context.Background()
-		createdUser := &auth.UsernameUser{
-			ID:           uuid.New(),
-			PasswordHash: []byte(""),
-		}
-		err := model.Save(tx, createdUser)
+		createdUser, err := builder.From[*auth.UsernameUser]().Where("username", "=", "user").First(tx)
 		assert.NoError(t, err)
+		if createdUser == nil {
+			createdUser = &auth.UsernameUser{
+				ID:       uuid.New(),
+				Username: "user",
+			}
+			hash, err := bcrypt.GenerateFromPassword(createdUser.SaltedPassword("pass"), bcrypt.MinCost)
+			assert.NoError(t, err)
+			createdUser.PasswordHash = hash
+			assert.NoError(t, model.Save(tx, createdUser))
+		}
 
-		token, err := auth.GenerateToken(&auth.Claims{
-			RegisteredClaims: jwt.RegisteredClaims{
-				Subject: createdUser.GetID(),
-			},
-			Scope: []string{auth.ScopeRefresh},
+		resp, err := usernameRoutes.RunLogin(&auth.LoginRequest{
+			Username: "user",
+			Password: "pass",
+			Read:     dbtest.Read(tx),
+			Update:   dbtest.Update(tx),
+			Ctx:      ctx,
+			Log:      nullLogger,
 		})
 		assert.NoError(t, err)
-
-		resp, err := usernameRoutes.RunRefresh(&auth.RefreshRequest[*auth.UsernameUser]{
+		return createdUser, resp
+	}
+	refresh := func(tx *sqlx.Tx, token string) (*auth.LoginResponse, error) {
+		return usernameRoutes.RunRefresh(&auth.RefreshRequest[*auth.UsernameUser]{
 			RefreshToken: token,
-			Ctx:          ctx,
-			Read:         dbtest.Read(tx),
+			Ctx:          context.Background(),
+			Update:       dbtest.Update(tx),
+			Log:          nullLogger,
 		})
+	}
+
+	Run(t, "rotates refresh token", func(t *testing.T, tx *sqlx.Tx) {
+		createdUser, login := login(t, tx)
+
+		resp, err := refresh(tx, login.RefreshToken)
 		assert.NoError(t, err)
-		assert.Equal(t, token, resp.RefreshToken)
+		assert.NotEqual(t, login.RefreshToken, resp.RefreshToken)
 		assert.Equal(t, "Bearer", resp.TokenType)
-		assert.Equal(t, token, resp.RefreshToken)
 		assert.Equal(t, 3600, resp.ExpiresIn)
 
 		claims, err := auth.Parse(resp.AccessToken)
 		assert.NoError(t, err)
 		assert.Equal(t, createdUser.GetID(), claims.Subject)
 		assert.Equal(t, auth.ScopeStrings{auth.ScopeAccess}, claims.Scope)
+
+		_, err = refresh(tx, resp.RefreshToken)
+		assert.NoError(t, err)
+	})
+
+	Run(t, "reuse revokes family", func(t *testing.T, tx *sqlx.Tx) {
+		_, login := login(t, tx)
+
+		resp, err := refresh(tx, login.RefreshToken)
+		assert.NoError(t, err)
+
+		_, err = refresh(tx, login.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+
+		_, err = refresh(tx, resp.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrRefreshTokenRevoked)
+	})
+
+	Run(t, "unpersisted token", func(t *testing.T, tx *sqlx.Tx) {
+		token, err := auth.GenerateToken(auth.NewClaims().
+			WithSubject(uuid.NewString()).
+			WithJWTID(uuid.NewString()).
+			WithScopes(auth.ScopeRefresh))
+		assert.NoError(t, err)
+
+		_, err = refresh(tx, token)
+		assert.ErrorIs(t, err, auth.ErrRefreshTokenRevoked)
+	})
+
+	Run(t, "logout", func(t *testing.T, tx *sqlx.Tx) {
+		_, first := login(t, tx)
+		_, second := login(t, tx)
+
+		claims, err := auth.Parse(first.AccessToken)
+		assert.NoError(t, err)
+
+		_, err = usernameRoutes.RunLogout(&auth.LogoutRequest{
+			RefreshToken: first.RefreshToken,
+			Claims:       claims,
+			Update:       dbtest.Update(tx),
+			Ctx:          context.Background(),
+		})
+		assert.NoError(t, err)
+
+		_, err = refresh(tx, first.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrRefreshTokenRevoked)
+
+		_, err = refresh(tx, second.RefreshToken)
+		assert.NoError(t, err)
+	})
+
+	Run(t, "logout all", func(t *testing.T, tx *sqlx.Tx) {
+		_, first := login(t, tx)
+		_, second := login(t, tx)
+
+		claims, err := auth.Parse(first.AccessToken)
+		assert.NoError(t, err)
+
+		_, err = usernameRoutes.RunLogoutAll(&auth.LogoutRequest{
+			Claims: claims,
+			Update: dbtest.Update(tx),
+			Ctx:    context.Background(),
+		})
+		assert.NoError(t, err)
+
+		_, err = refresh(tx, first.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrRefreshTokenRevoked)
+		_, err = refresh(tx, second.RefreshToken)
+		assert.ErrorIs(t, err, auth.ErrRefreshTokenRevoked)
 	})
 }
 
@@ -393,3 +485,56 @@ func TestAuthRoutesForgotPassword(t *testing.T) {
 
 	})
 }
+
+func TestAuthRoutesPasswordPolicy(t *testing.T) {
+	routes := auth.NewBasicAuthController[*auth.UsernameUser](auth.PasswordPolicy(
+		rules.NewPasswordPolicy().Min(10).Numbers().NotPersonal(),
+	))
+	create := func(tx *sqlx.Tx, password string) error {
+		_, err := routes.RunUserCreate(&auth.UsernameUser{
+			ID:           uuid.New(),
+			Username:     "username",
+			PasswordHash: []byte{},
+		}, &auth.UserCreateRequest{
+			Password: password,
+			Update:   dbtest.Update(tx),
+			Ctx:      context.Background(),
+			Logger:   nullLogger,
+		})
+		return err
+	}
+
+	Run(t, "rejects weak passwords", func(t *testing.T, tx *sqlx.Tx) {
+		err := create(tx, "username")
+		assert.Equal(t, request.ValidationError{
+			"password": {
+				"The password must be at least 10 characters.",
+				"The password must contain at least one number.",
+				"The password must not contain your username or email.",
+			},
+		}, err)
+	})
+
+	Run(t, "accepts strong passwords", func(t *testing.T, tx *sqlx.Tx) {
+		assert.NoError(t, create(tx, "correct horse 1"))
+	})
+
+	Run(t, "bcrypt limit without a policy", func(t *testing.T, tx *sqlx.Tx) {
+		u := &auth.UsernameUser{ID: uuid.New(), PasswordHash: []byte{}}
+		assert.NoError(t, model.Save(tx, u))
+		hash, err := bcrypt.GenerateFromPassword(u.SaltedPassword("pass"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		u.PasswordHash = hash
+
+		_, err = usernameRoutes.RunChangePassword(&auth.ChangePasswordRequest[*auth.UsernameUser]{
+			OldPassword: "pass",
+			NewPassword: strings.Repeat("a", 73),
+			User:        u,
+			Ctx:         context.Background(),
+			Update:      dbtest.Update(tx),
+		})
+		assert.Equal(t, request.ValidationError{
+			"new_password": {"The new_password must not be greater than 72 bytes."},
+		}, err)
+	})
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/schema"
+)
+
+// Session is a server side login session. The id is a hash of the value in
+// the session cookie.
+type Session struct {
+	model.BaseModel
+	ID        string    `json:"id"         db:"id,primary"`
+	UserID    string    `json:"user_id"    db:"user_id"`
+	Claims    *Claims   `json:"claims"     db:"claims"`
+	CSRFToken string    `json:"csrf_token" db:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+func (*Session) Table() string {
+	return "sessions"
+}
+
+// SessionStore stores sessions for a SessionGuard.
+type SessionStore interface {
+	// Get returns the session with the id or nil if there is no session.
+	Get(ctx context.Context, id string) (*Session, error)
+	Save(ctx context.Context, s *Session) error
+	Delete(ctx context.Context, id string) error
+	// DeleteUser deletes every session of the user.
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+// MemorySessionStore is a SessionStore that keeps sessions in memory.
+type MemorySessionStore struct {
+	mtx      sync.Mutex
+	sessions map[string]*Session
+}
+
+var _ SessionStore = (*MemorySessionStore)(nil)
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: map[string]*Session{},
+	}
+}
+
+func (s *MemorySessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(session.ExpiresAt) {
+		delete(s.sessions, id)
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (s *MemorySessionStore) Save(ctx context.Context, session *Session) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.sessions[session.ID] = session
+	return nil
+}
+
+func (s *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *MemorySessionStore) DeleteUser(ctx context.Context, userID string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+// DBSessionStore is a SessionStore that keeps sessions in the sessions table.
+type DBSessionStore struct {
+	db database.DB
+}
+
+var _ SessionStore = (*DBSessionStore)(nil)
+
+func NewDBSessionStore(db database.DB) *DBSessionStore {
+	return &DBSessionStore{db: db}
+}
+
+func (s *DBSessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	session, err := builder.From[*Session]().
+		WithContext(ctx).
+		Find(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || time.Now().After(session.ExpiresAt) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (s *DBSessionStore) Save(ctx context.Context, session *Session) error {
+	return model.SaveContext(ctx, s.db, session)
+}
+
+func (s *DBSessionStore) Delete(ctx context.Context, id string) error {
+	return builder.From[*Session]().
+		WithContext(ctx).
+		Where("id", "=", id).
+		Delete(s.db)
+}
+
+func (s *DBSessionStore) DeleteUser(ctx context.Context, userID string) error {
+	return builder.From[*Session]().
+		WithContext(ctx).
+		Where("user_id", "=", userID).
+		Delete(s.db)
+}
+
+// SessionMigration creates the sessions table used by DBSessionStore.
+func SessionMigration(name string) *migrate.Migration {
+	return &migrate.Migration{
+		Name: name,
+		Up: schema.Create("sessions", func(table *schema.Blueprint) {
+			table.String("id").Primary()
+			table.String("user_id")
+			table.JSON("claims")
+			table.String("csrf_token")
+			table.DateTime("expires_at")
+			table.Index("sessions-user_id").AddColumn("user_id")
+		}),
+		Down: schema.DropIfExists("sessions"),
+	}
+}
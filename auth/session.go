@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrNoSession    = fmt.Errorf("no session: %w", ErrNoCredentials)
+	ErrCSRFMismatch = errors.New("csrf token mismatch")
+)
+
+const (
+	// CSRFHeader is the header state changing requests authenticated by a
+	// SessionGuard must send the session's csrf token in.
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFField is the form field the csrf token can be sent in instead of
+	// CSRFHeader.
+	CSRFField = "_csrf"
+)
+
+// SessionGuard authenticates requests with a session cookie. State changing
+// requests, anything other than GET, HEAD, OPTIONS and TRACE, must also send
+// the session's csrf token in the CSRFHeader header or CSRFField form field.
+type SessionGuard struct {
+	store      SessionStore
+	cookieName string
+	lifetime   time.Duration
+	secure     bool
+	sameSite   http.SameSite
+}
+
+var _ Guard = (*SessionGuard)(nil)
+
+func NewSessionGuard(store SessionStore) *SessionGuard {
+	return &SessionGuard{
+		store:      store,
+		cookieName: "salusa_session",
+		lifetime:   time.Hour * 24 * 14,
+		secure:     true,
+		sameSite:   http.SameSiteLaxMode,
+	}
+}
+
+// CookieName sets the name of the session cookie, the default is
+// salusa_session.
+func (g *SessionGuard) CookieName(name string) *SessionGuard {
+	g.cookieName = name
+	return g
+}
+
+// Lifetime sets how long sessions last, the default is 14 days.
+func (g *SessionGuard) Lifetime(lifetime time.Duration) *SessionGuard {
+	g.lifetime = lifetime
+	return g
+}
+
+// Secure sets if the session cookie is only sent over https, the default is
+// true.
+func (g *SessionGuard) Secure(secure bool) *SessionGuard {
+	g.secure = secure
+	return g
+}
+
+// SameSite sets the SameSite attribute of the session cookie, the default is
+// http.SameSiteLaxMode.
+func (g *SessionGuard) SameSite(sameSite http.SameSite) *SessionGuard {
+	g.sameSite = sameSite
+	return g
+}
+
+func (g *SessionGuard) Authenticate(r *http.Request) (*Claims, error) {
+	s, err := g.Session(r)
+	if err != nil {
+		return nil, err
+	}
+	if !isSafeMethod(r.Method) {
+		token := r.Header.Get(CSRFHeader)
+		if token == "" {
+			token = r.PostFormValue(CSRFField)
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.CSRFToken)) != 1 {
+			return nil, ErrCSRFMismatch
+		}
+	}
+	return s.Claims, nil
+}
+
+// Session returns the session of the request. Use the session's CSRFToken in
+// forms and requests that change state.
+func (g *SessionGuard) Session(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(g.cookieName)
+	if errors.Is(err, http.ErrNoCookie) {
+		return nil, ErrNoSession
+	} else if err != nil {
+		return nil, err
+	}
+	s, err := g.store.Get(r.Context(), hashSessionID(cookie.Value))
+	if err != nil {
+		return nil, fmt.Errorf("could not load session: %w", err)
+	}
+	if s == nil {
+		return nil, ErrNoSession
+	}
+	return s, nil
+}
+
+// Login starts a new session with claims and sets the session cookie.
+func (g *SessionGuard) Login(ctx context.Context, w http.ResponseWriter, claims *Claims) (*Session, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		ID:        hashSessionID(id),
+		UserID:    claims.Subject,
+		Claims:    claims,
+		CSRFToken: csrf,
+		ExpiresAt: time.Now().Add(g.lifetime),
+	}
+	err = g.store.Save(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("could not save session: %w", err)
+	}
+	http.SetCookie(w, g.cookie(id, s.ExpiresAt))
+	return s, nil
+}
+
+// Logout ends the session of the request and removes the session cookie.
+func (g *SessionGuard) Logout(w http.ResponseWriter, r *http.Request) error {
+	cookie, err := r.Cookie(g.cookieName)
+	if err == nil {
+		err = g.store.Delete(r.Context(), hashSessionID(cookie.Value))
+		if err != nil {
+			return fmt.Errorf("could not delete session: %w", err)
+		}
+	}
+	c := g.cookie("", time.Unix(0, 0))
+	c.MaxAge = -1
+	http.SetCookie(w, c)
+	return nil
+}
+
+// LogoutUser ends every session of the user, requests using them are
+// rejected from then on.
+func (g *SessionGuard) LogoutUser(ctx context.Context, userID string) error {
+	err := g.store.DeleteUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("could not delete sessions: %w", err)
+	}
+	return nil
+}
+
+func (g *SessionGuard) cookie(value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     g.cookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   g.secure,
+		SameSite: g.sameSite,
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// hashSessionID hashes the id sent in the cookie so the stored sessions can't
+// be used if the store is leaked.
+func hashSessionID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("could not generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
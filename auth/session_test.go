@@ -0,0 +1,236 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/database/model"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func sessionRequest(method string, cookies []*http.Cookie) *http.Request {
+	r := httptest.NewRequest(method, "/", http.NoBody)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func testSessionGuard(t *testing.T, store auth.SessionStore) {
+	ctx := context.Background()
+	g := auth.NewSessionGuard(store)
+
+	w := httptest.NewRecorder()
+	s, err := g.Login(ctx, w, auth.NewClaims().WithSubject("user"))
+	assert.NoError(t, err)
+	cookies := w.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		return
+	}
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+
+	claims, err := g.Authenticate(sessionRequest(http.MethodGet, cookies))
+	assert.NoError(t, err)
+	assert.Equal(t, "user", claims.Subject)
+
+	_, err = g.Authenticate(sessionRequest(http.MethodPost, cookies))
+	assert.ErrorIs(t, err, auth.ErrCSRFMismatch)
+
+	r := sessionRequest(http.MethodPost, cookies)
+	r.Header.Set(auth.CSRFHeader, s.CSRFToken)
+	_, err = g.Authenticate(r)
+	assert.NoError(t, err)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{auth.CSRFField: {s.CSRFToken}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(cookies[0])
+	_, err = g.Authenticate(r)
+	assert.NoError(t, err)
+
+	_, err = g.Authenticate(sessionRequest(http.MethodGet, nil))
+	assert.ErrorIs(t, err, auth.ErrNoCredentials)
+
+	w = httptest.NewRecorder()
+	err = g.Logout(w, sessionRequest(http.MethodPost, cookies))
+	assert.NoError(t, err)
+	if assert.Len(t, w.Result().Cookies(), 1) {
+		assert.Equal(t, "", w.Result().Cookies()[0].Value)
+		assert.Less(t, w.Result().Cookies()[0].MaxAge, 0)
+	}
+
+	_, err = g.Authenticate(sessionRequest(http.MethodGet, cookies))
+	assert.ErrorIs(t, err, auth.ErrNoSession)
+
+	first, second, other := httptest.NewRecorder(), httptest.NewRecorder(), httptest.NewRecorder()
+	_, err = g.Login(ctx, first, auth.NewClaims().WithSubject("user"))
+	assert.NoError(t, err)
+	_, err = g.Login(ctx, second, auth.NewClaims().WithSubject("user"))
+	assert.NoError(t, err)
+	_, err = g.Login(ctx, other, auth.NewClaims().WithSubject("other"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, g.LogoutUser(ctx, "user"))
+
+	_, err = g.Authenticate(sessionRequest(http.MethodGet, first.Result().Cookies()))
+	assert.ErrorIs(t, err, auth.ErrNoSession)
+	_, err = g.Authenticate(sessionRequest(http.MethodGet, second.Result().Cookies()))
+	assert.ErrorIs(t, err, auth.ErrNoSession)
+	claims, err = g.Authenticate(sessionRequest(http.MethodGet, other.Result().Cookies()))
+	assert.NoError(t, err)
+	assert.Equal(t, "other", claims.Subject)
+}
+
+func TestSessionGuard(t *testing.T) {
+	t.Run("memory store", func(t *testing.T) {
+		testSessionGuard(t, auth.NewMemorySessionStore())
+	})
+
+	Run(t, "db store", func(t *testing.T, tx *sqlx.Tx) {
+		testSessionGuard(t, auth.NewDBSessionStore(tx))
+	})
+}
+
+func TestAttachUserGuards(t *testing.T) {
+	g := auth.NewSessionGuard(auth.NewMemorySessionStore())
+	w := httptest.NewRecorder()
+	_, err := g.Login(context.Background(), w, auth.NewClaims().WithSubject("user"))
+	assert.NoError(t, err)
+
+	token, err := auth.GenerateToken(auth.NewClaims().WithSubject("bearer").WithScopes(auth.ScopeAccess))
+	assert.NoError(t, err)
+
+	subject := ""
+	h := auth.AttachUser(auth.NewBearerGuard(), g)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := auth.GetClaims(r)
+		if ok {
+			subject = claims.Subject
+		} else {
+			subject = ""
+		}
+	}))
+
+	r := sessionRequest(http.MethodGet, w.Result().Cookies())
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "user", subject)
+
+	r = sessionRequest(http.MethodGet, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "bearer", subject)
+
+	h.ServeHTTP(httptest.NewRecorder(), sessionRequest(http.MethodGet, nil))
+	assert.Equal(t, "", subject)
+}
+
+func TestAuthRoutesSessionLogin(t *testing.T) {
+	Run(t, "", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		g := auth.NewSessionGuard(auth.NewDBSessionStore(tx))
+		controller := auth.NewBasicAuthController[*auth.UsernameUser](auth.Sessions(g))
+
+		u := &auth.UsernameUser{ID: uuid.New(), Username: "user"}
+		hash, err := bcrypt.GenerateFromPassword(u.SaltedPassword("pass"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		u.PasswordHash = hash
+		assert.NoError(t, model.Save(tx, u))
+
+		w := httptest.NewRecorder()
+		resp, err := controller.RunSessionLogin(&auth.SessionLoginRequest{
+			Username: "user",
+			Password: "pass",
+			Ctx:      ctx,
+			Read:     dbtest.Read(tx),
+			Log:      nullLogger,
+			Response: w,
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.CSRFToken)
+
+		claims, err := g.Authenticate(sessionRequest(http.MethodGet, w.Result().Cookies()))
+		assert.NoError(t, err)
+		assert.Equal(t, u.GetID(), claims.Subject)
+
+		_, err = controller.RunSessionLogout(&auth.SessionLogoutRequest{
+			Request:  sessionRequest(http.MethodPost, w.Result().Cookies()),
+			Response: httptest.NewRecorder(),
+		})
+		assert.ErrorIs(t, err, auth.ErrCSRFMismatch)
+
+		r := sessionRequest(http.MethodPost, w.Result().Cookies())
+		r.Header.Set(auth.CSRFHeader, resp.CSRFToken)
+		_, err = controller.RunSessionLogout(&auth.SessionLogoutRequest{
+			Request:  r,
+			Response: httptest.NewRecorder(),
+		})
+		assert.NoError(t, err)
+
+		_, err = g.Authenticate(sessionRequest(http.MethodGet, w.Result().Cookies()))
+		assert.ErrorIs(t, err, auth.ErrNoSession)
+	})
+}
+
+func TestAuthRoutesSessionRevoke(t *testing.T) {
+	setup := func(t *testing.T, tx *sqlx.Tx) (*auth.SessionGuard, *auth.BasicAuthController[*auth.UsernameUser], *auth.UsernameUser, []*http.Cookie) {
+		g := auth.NewSessionGuard(auth.NewDBSessionStore(tx))
+		controller := auth.NewBasicAuthController[*auth.UsernameUser](auth.Sessions(g))
+
+		u := &auth.UsernameUser{ID: uuid.New(), Username: "user"}
+		hash, err := bcrypt.GenerateFromPassword(u.SaltedPassword("pass"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		u.PasswordHash = hash
+		assert.NoError(t, model.Save(tx, u))
+
+		w := httptest.NewRecorder()
+		_, err = controller.RunSessionLogin(&auth.SessionLoginRequest{
+			Username: "user",
+			Password: "pass",
+			Ctx:      context.Background(),
+			Read:     dbtest.Read(tx),
+			Log:      nullLogger,
+			Response: w,
+		})
+		assert.NoError(t, err)
+		return g, controller, u, w.Result().Cookies()
+	}
+
+	Run(t, "logout all", func(t *testing.T, tx *sqlx.Tx) {
+		g, controller, u, cookies := setup(t, tx)
+
+		_, err := controller.RunLogoutAll(&auth.LogoutRequest{
+			Claims: auth.NewClaims().WithSubject(u.GetID()),
+			Update: dbtest.Update(tx),
+			Ctx:    context.Background(),
+		})
+		assert.NoError(t, err)
+
+		_, err = g.Authenticate(sessionRequest(http.MethodGet, cookies))
+		assert.ErrorIs(t, err, auth.ErrNoSession)
+	})
+
+	Run(t, "change password", func(t *testing.T, tx *sqlx.Tx) {
+		g, controller, u, cookies := setup(t, tx)
+
+		_, err := controller.RunChangePassword(&auth.ChangePasswordRequest[*auth.UsernameUser]{
+			OldPassword: "pass",
+			NewPassword: "new password",
+			User:        u,
+			Update:      dbtest.Update(tx),
+			Ctx:         context.Background(),
+		})
+		assert.NoError(t, err)
+
+		_, err = g.Authenticate(sessionRequest(http.MethodGet, cookies))
+		assert.ErrorIs(t, err, auth.ErrNoSession)
+	})
+}
@@ -37,6 +37,13 @@ func (u *AutoIncrementUser) UsernameColumns() []string {
 	return []string{"username"}
 }
 
+type TwoFactorUser struct {
+	auth.UsernameUser
+	auth.TwoFactor
+}
+
+var _ auth.TwoFactorUser = (*TwoFactorUser)(nil)
+
 var runner = dbtest.NewRunner(func() (*sqlx.DB, error) {
 	sqlite.UseSQLite()
 	db, err := sqlx.Open("sqlite3", ":memory:")
@@ -44,7 +51,7 @@ var runner = dbtest.NewRunner(func() (*sqlx.DB, error) {
 		return nil, err
 	}
 	ctx := context.Background()
-	err = migrate.RunModelCreate(ctx, db, &auth.UsernameUser{}, &auth.EmailVerifiedUser{}, &AutoIncrementUser{})
+	err = migrate.RunModelCreate(ctx, db, &auth.UsernameUser{}, &auth.EmailVerifiedUser{}, &AutoIncrementUser{}, &TwoFactorUser{}, &auth.RefreshToken{}, &auth.Session{}, &auth.Role{}, &auth.Permission{}, &auth.RolePermission{}, &auth.UserRole{}, &auth.LoginAttempt{}, &auth.PersonalAccessToken{}, &auth.LoginToken{})
 	if err != nil {
 		return nil, err
 	}
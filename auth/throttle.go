@@ -0,0 +1,545 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/dialects"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/abibby/salusa/event"
+	"github.com/abibby/salusa/request"
+)
+
+var ErrTooManyAttempts = errors.New("too many failed attempts")
+
+// Attempts is the failed attempts recorded for a throttle key.
+type Attempts struct {
+	Count int
+	Last  time.Time
+}
+
+// ThrottleStore stores failed attempts for a LoginThrottle. Increment and
+// Decrement must be atomic so parallel requests are all counted.
+type ThrottleStore interface {
+	// Get returns the attempts for the key or nil if there are none.
+	Get(ctx context.Context, key string) (*Attempts, error)
+	// Increment adds an attempt at now to the key and returns the updated
+	// attempts. Attempts last updated more than expire ago are reset first.
+	Increment(ctx context.Context, key string, now time.Time, expire time.Duration) (*Attempts, error)
+	// Decrement removes an attempt from the key.
+	Decrement(ctx context.Context, key string) error
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryThrottleStore is a ThrottleStore that keeps attempts in memory.
+// Expired attempts are swept on Increment.
+type MemoryThrottleStore struct {
+	mtx      sync.Mutex
+	attempts map[string]Attempts
+	swept    time.Time
+}
+
+var _ ThrottleStore = (*MemoryThrottleStore)(nil)
+
+func NewMemoryThrottleStore() *MemoryThrottleStore {
+	return &MemoryThrottleStore{
+		attempts: map[string]Attempts{},
+	}
+}
+
+func (s *MemoryThrottleStore) Get(ctx context.Context, key string) (*Attempts, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	a, ok := s.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+func (s *MemoryThrottleStore) Increment(ctx context.Context, key string, now time.Time, expire time.Duration) (*Attempts, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// Sweeping at most once per expire keeps Increment cheap while bounding
+	// the map to the keys seen in the last two expire periods.
+	if now.Sub(s.swept) > expire {
+		for k, a := range s.attempts {
+			if now.Sub(a.Last) > expire {
+				delete(s.attempts, k)
+			}
+		}
+		s.swept = now
+	}
+
+	a, ok := s.attempts[key]
+	if !ok || now.Sub(a.Last) > expire {
+		a = Attempts{}
+	}
+	a.Count++
+	a.Last = now
+	s.attempts[key] = a
+	return &a, nil
+}
+
+func (s *MemoryThrottleStore) Decrement(ctx context.Context, key string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	a, ok := s.attempts[key]
+	if !ok {
+		return nil
+	}
+	a.Count--
+	if a.Count <= 0 {
+		delete(s.attempts, key)
+	} else {
+		s.attempts[key] = a
+	}
+	return nil
+}
+
+func (s *MemoryThrottleStore) Delete(ctx context.Context, key string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// LoginAttempt is a row in the login_attempts table used by
+// DBThrottleStore.
+type LoginAttempt struct {
+	model.BaseModel
+	Key           string    `json:"key"             db:"key,primary"`
+	Count         int       `json:"count"           db:"count"`
+	LastAttemptAt time.Time `json:"last_attempt_at" db:"last_attempt_at"`
+}
+
+func (*LoginAttempt) Table() string {
+	return "login_attempts"
+}
+
+// LoginAttemptMigration creates the login_attempts table.
+func LoginAttemptMigration(name string) *migrate.Migration {
+	return &migrate.Migration{
+		Name: name,
+		Up: schema.Create("login_attempts", func(table *schema.Blueprint) {
+			table.String("key").Primary()
+			table.Int("count")
+			table.DateTime("last_attempt_at")
+		}),
+		Down: schema.DropIfExists("login_attempts"),
+	}
+}
+
+// DBThrottleStore is a ThrottleStore that keeps attempts in the
+// login_attempts table so they are shared between servers.
+type DBThrottleStore struct {
+	db database.DB
+}
+
+var _ ThrottleStore = (*DBThrottleStore)(nil)
+
+func NewDBThrottleStore(db database.DB) *DBThrottleStore {
+	return &DBThrottleStore{
+		db: db,
+	}
+}
+
+func (s *DBThrottleStore) Get(ctx context.Context, key string) (*Attempts, error) {
+	a, err := builder.From[*LoginAttempt]().
+		WithContext(ctx).
+		Where("key", "=", key).
+		First(s.db)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, nil
+	}
+	return &Attempts{
+		Count: a.Count,
+		Last:  a.LastAttemptAt,
+	}, nil
+}
+
+// dbThrottleRetries is how many times DBThrottleStore retries an update that
+// lost a race with another request.
+const dbThrottleRetries = 10
+
+var errThrottleConflict = errors.New("throttle attempt changed while updating")
+
+// Increment updates the row only if its count hasn't changed since it was
+// read, retrying if another request updated it first.
+func (s *DBThrottleStore) Increment(ctx context.Context, key string, now time.Time, expire time.Duration) (*Attempts, error) {
+	for range dbThrottleRetries {
+		a, err := s.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			next := &Attempts{Count: 1, Last: now}
+			err = model.SaveContext(ctx, s.db, &LoginAttempt{
+				Key:           key,
+				Count:         next.Count,
+				LastAttemptAt: next.Last,
+			})
+			if err != nil {
+				existing, getErr := s.Get(ctx, key)
+				if getErr == nil && existing != nil {
+					// Another request inserted the key first.
+					continue
+				}
+				return nil, err
+			}
+			return next, nil
+		}
+
+		next := &Attempts{Count: a.Count + 1, Last: now}
+		if now.Sub(a.Last) > expire {
+			next.Count = 1
+		}
+		ok, err := s.compareAndSet(ctx, key, a.Count, next)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return next, nil
+		}
+	}
+	return nil, errThrottleConflict
+}
+
+func (s *DBThrottleStore) Decrement(ctx context.Context, key string) error {
+	for range dbThrottleRetries {
+		a, err := s.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if a == nil || a.Count <= 0 {
+			return nil
+		}
+		ok, err := s.compareAndSet(ctx, key, a.Count, &Attempts{Count: a.Count - 1, Last: a.Last})
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return errThrottleConflict
+}
+
+// compareAndSet updates the key to a if its count is still count.
+func (s *DBThrottleStore) compareAndSet(ctx context.Context, key string, count int, a *Attempts) (bool, error) {
+	q, bindings, err := builder.From[*LoginAttempt]().
+		Where("key", "=", key).
+		Where("count", "=", count).
+		Updater(builder.Updates{
+			"count":           a.Count,
+			"last_attempt_at": a.Last,
+		}).
+		SQLString(dialects.New())
+	if err != nil {
+		return false, err
+	}
+	result, err := s.db.ExecContext(ctx, q, bindings...)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+func (s *DBThrottleStore) Delete(ctx context.Context, key string) error {
+	return builder.From[*LoginAttempt]().
+		WithContext(ctx).
+		Where("key", "=", key).
+		Delete(s.db)
+}
+
+// LockoutEvent is pushed to the throttles queue when a username or ip is
+// locked out.
+type LockoutEvent struct {
+	// Action is "login", "forgot-password", "passwordless-send",
+	// "passwordless-login" or "two-factor".
+	Action   string
+	Username string
+	IP       string
+	Until    time.Time
+}
+
+var _ event.Event = (*LockoutEvent)(nil)
+
+func (*LockoutEvent) Type() event.EventType {
+	return "auth:lockout"
+}
+
+// LoginThrottle limits failed login attempts per username and per ip. Each
+// failure doubles the delay before the next attempt is allowed and reaching
+// the max attempts locks the key out.
+type LoginThrottle struct {
+	store         ThrottleStore
+	queue         event.Queue
+	maxAttempts   int
+	maxIPAttempts int
+	window        time.Duration
+	lockout       time.Duration
+	delay         time.Duration
+}
+
+func NewLoginThrottle(store ThrottleStore) *LoginThrottle {
+	return &LoginThrottle{
+		store:         store,
+		maxAttempts:   5,
+		maxIPAttempts: 20,
+		window:        15 * time.Minute,
+		lockout:       15 * time.Minute,
+		delay:         time.Second,
+	}
+}
+
+// MaxAttempts sets the failures allowed for a username before it is locked
+// out, the default is 5.
+func (t *LoginThrottle) MaxAttempts(attempts int) *LoginThrottle {
+	t.maxAttempts = attempts
+	return t
+}
+
+// MaxIPAttempts sets the failures allowed from an ip before it is locked out,
+// the default is 20.
+func (t *LoginThrottle) MaxIPAttempts(attempts int) *LoginThrottle {
+	t.maxIPAttempts = attempts
+	return t
+}
+
+// Window sets how long failures are remembered, the default is 15 minutes.
+func (t *LoginThrottle) Window(window time.Duration) *LoginThrottle {
+	t.window = window
+	return t
+}
+
+// Lockout sets how long a locked out key is blocked, the default is 15
+// minutes.
+func (t *LoginThrottle) Lockout(lockout time.Duration) *LoginThrottle {
+	t.lockout = lockout
+	return t
+}
+
+// Delay sets the delay after the first failure, the default is 1 second.
+func (t *LoginThrottle) Delay(delay time.Duration) *LoginThrottle {
+	t.delay = delay
+	return t
+}
+
+// Queue sets the queue LockoutEvents are pushed to.
+func (t *LoginThrottle) Queue(queue event.Queue) *LoginThrottle {
+	t.queue = queue
+	return t
+}
+
+type throttleKey struct {
+	key         string
+	maxAttempts int
+}
+
+func (t *LoginThrottle) keys(action, username, ip string) []throttleKey {
+	keys := []throttleKey{{
+		key:         action + ":user:" + strings.ToLower(username),
+		maxAttempts: t.maxAttempts,
+	}}
+	if ip != "" {
+		keys = append(keys, throttleKey{
+			key:         action + ":ip:" + ip,
+			maxAttempts: t.maxIPAttempts,
+		})
+	}
+	return keys
+}
+
+// retryAt returns the time the next attempt is allowed.
+func (t *LoginThrottle) retryAt(a *Attempts, maxAttempts int) time.Time {
+	if a.Count >= maxAttempts {
+		return a.Last.Add(t.lockout)
+	}
+	delay := time.Duration(float64(t.delay) * math.Pow(2, float64(a.Count-1)))
+	return a.Last.Add(min(delay, t.lockout))
+}
+
+// Check returns a 429 error with a Retry-After header if the username or ip
+// have to wait before trying again.
+func (t *LoginThrottle) Check(ctx context.Context, action, username, ip string) error {
+	now := time.Now()
+	var retryAt time.Time
+	for _, k := range t.keys(action, username, ip) {
+		a, err := t.attempts(ctx, k.key, now)
+		if err != nil {
+			return err
+		}
+		if a == nil {
+			continue
+		}
+		if r := t.retryAt(a, k.maxAttempts); r.After(retryAt) {
+			retryAt = r
+		}
+	}
+
+	if !retryAt.After(now) {
+		return nil
+	}
+	return tooManyAttempts(retryAt, now)
+}
+
+func tooManyAttempts(retryAt, now time.Time) error {
+	seconds := int(math.Ceil(retryAt.Sub(now).Seconds()))
+	return request.NewHTTPError(ErrTooManyAttempts, http.StatusTooManyRequests).
+		WithHeader("Retry-After", strconv.Itoa(seconds))
+}
+
+// attempts returns the attempts for the key, ignoring attempts older than the
+// window.
+func (t *LoginThrottle) attempts(ctx context.Context, key string, now time.Time) (*Attempts, error) {
+	a, err := t.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil || a.Count <= 0 || now.Sub(a.Last) > t.expire() {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// expire is how long attempts are kept in the store.
+func (t *LoginThrottle) expire() time.Duration {
+	return max(t.window, t.lockout)
+}
+
+// Attempt checks the username and ip like Check and records the attempt as a
+// failure before it is made, so parallel requests can't get past the max
+// attempts. Call Succeed if the attempt succeeds and AttemptFailed if it
+// doesn't.
+func (t *LoginThrottle) Attempt(ctx context.Context, action, username, ip string) error {
+	err := t.Check(ctx, action, username, ip)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	recorded := []string{}
+	for _, k := range t.keys(action, username, ip) {
+		a, err := t.store.Increment(ctx, k.key, now, t.expire())
+		if err != nil {
+			return err
+		}
+		recorded = append(recorded, k.key)
+		if a.Count > k.maxAttempts {
+			errs := []error{}
+			for _, key := range recorded {
+				errs = append(errs, t.store.Decrement(ctx, key))
+			}
+			err = errors.Join(errs...)
+			if err != nil {
+				return err
+			}
+			return tooManyAttempts(now.Add(t.lockout), now)
+		}
+	}
+	return nil
+}
+
+// AttemptFailed confirms that an attempt recorded by Attempt failed and pushes
+// a LockoutEvent if it used the last attempt of a key.
+func (t *LoginThrottle) AttemptFailed(ctx context.Context, log *slog.Logger, action, username, ip string) error {
+	now := time.Now()
+	for _, k := range t.keys(action, username, ip) {
+		a, err := t.attempts(ctx, k.key, now)
+		if err != nil {
+			return err
+		}
+		if a != nil && a.Count >= k.maxAttempts {
+			err = t.lockedOut(log, action, username, ip, k, a, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Fail records a failed attempt and pushes a LockoutEvent when a key reaches
+// its max attempts. Use AttemptFailed for attempts recorded by Attempt.
+func (t *LoginThrottle) Fail(ctx context.Context, log *slog.Logger, action, username, ip string) error {
+	now := time.Now()
+	for _, k := range t.keys(action, username, ip) {
+		a, err := t.store.Increment(ctx, k.key, now, t.expire())
+		if err != nil {
+			return err
+		}
+		if a.Count == k.maxAttempts {
+			err = t.lockedOut(log, action, username, ip, k, a, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *LoginThrottle) lockedOut(log *slog.Logger, action, username, ip string, k throttleKey, a *Attempts, now time.Time) error {
+	log.Warn("too many failed attempts", "key", k.key, "attempts", a.Count)
+	if t.queue == nil {
+		return nil
+	}
+	err := t.queue.Push(&LockoutEvent{
+		Action:   action,
+		Username: username,
+		IP:       ip,
+		Until:    now.Add(t.lockout),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to push lockout event: %w", err)
+	}
+	return nil
+}
+
+// Succeed clears the failed attempts for the username and removes the
+// attempt recorded for the ip by Attempt.
+func (t *LoginThrottle) Succeed(ctx context.Context, action, username, ip string) error {
+	keys := t.keys(action, username, ip)
+	err := t.store.Delete(ctx, keys[0].key)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 1 {
+		return t.store.Decrement(ctx, keys[1].key)
+	}
+	return nil
+}
+
+// clientIP returns the ip of the client that sent r or an empty string if r
+// is nil.
+func clientIP(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
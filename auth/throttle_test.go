@@ -0,0 +1,260 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/event"
+	"github.com/abibby/salusa/request"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testQueue struct {
+	events []event.Event
+}
+
+func (q *testQueue) Push(e event.Event) error {
+	q.events = append(q.events, e)
+	return nil
+}
+func (q *testQueue) Pop(events map[event.EventType]reflect.Type) (event.Event, error) {
+	return nil, nil
+}
+
+func retryAfter(err error) string {
+	httpErr, ok := err.(*request.HTTPError)
+	if !ok {
+		return ""
+	}
+	return httpErr.Header().Get("Retry-After")
+}
+
+func testLoginThrottle(t *testing.T, store auth.ThrottleStore) {
+	ctx := context.Background()
+	q := &testQueue{}
+	throttle := auth.NewLoginThrottle(store).
+		MaxAttempts(3).
+		MaxIPAttempts(5).
+		Delay(0).
+		Lockout(time.Hour).
+		Queue(q)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, throttle.Check(ctx, "login", "user", "10.0.0.1"))
+		assert.NoError(t, throttle.Fail(ctx, nullLogger, "login", "User", "10.0.0.1"))
+	}
+
+	err := throttle.Check(ctx, "login", "user", "10.0.0.2")
+	assert.ErrorIs(t, err, auth.ErrTooManyAttempts)
+	assert.Equal(t, "3600", retryAfter(err))
+	if assert.Len(t, q.events, 1) {
+		assert.Equal(t, "User", q.events[0].(*auth.LockoutEvent).Username)
+	}
+
+	assert.NoError(t, throttle.Check(ctx, "login", "other", "10.0.0.2"))
+	assert.NoError(t, throttle.Check(ctx, "forgot-password", "user", "10.0.0.1"))
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, throttle.Fail(ctx, nullLogger, "login", "other", "10.0.0.1"))
+	}
+	assert.ErrorIs(t, throttle.Check(ctx, "login", "new", "10.0.0.1"), auth.ErrTooManyAttempts)
+	assert.Len(t, q.events, 2)
+
+	assert.NoError(t, throttle.Succeed(ctx, "login", "user", ""))
+	assert.NoError(t, throttle.Check(ctx, "login", "user", "10.0.0.2"))
+}
+
+func testLoginThrottleAttempt(t *testing.T, store auth.ThrottleStore) {
+	ctx := context.Background()
+	throttle := auth.NewLoginThrottle(store).
+		MaxAttempts(2).
+		MaxIPAttempts(3).
+		Delay(0)
+
+	assert.NoError(t, throttle.Attempt(ctx, "login", "user", "10.0.0.1"))
+	assert.NoError(t, throttle.Succeed(ctx, "login", "user", "10.0.0.1"))
+
+	a, err := store.Get(ctx, "login:ip:10.0.0.1")
+	assert.NoError(t, err)
+	if a != nil {
+		assert.Equal(t, 0, a.Count)
+	}
+
+	assert.NoError(t, throttle.Attempt(ctx, "login", "user", "10.0.0.1"))
+	assert.NoError(t, throttle.Attempt(ctx, "login", "user", "10.0.0.1"))
+	err = throttle.Attempt(ctx, "login", "user", "10.0.0.1")
+	assert.ErrorIs(t, err, auth.ErrTooManyAttempts)
+
+	a, err = store.Get(ctx, "login:user:user")
+	assert.NoError(t, err)
+	if assert.NotNil(t, a) {
+		assert.Equal(t, 2, a.Count)
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	t.Run("memory store", func(t *testing.T) {
+		testLoginThrottle(t, auth.NewMemoryThrottleStore())
+		testLoginThrottleAttempt(t, auth.NewMemoryThrottleStore())
+	})
+
+	Run(t, "db store", func(t *testing.T, tx *sqlx.Tx) {
+		testLoginThrottle(t, auth.NewDBThrottleStore(tx))
+	})
+
+	Run(t, "db store attempt", func(t *testing.T, tx *sqlx.Tx) {
+		testLoginThrottleAttempt(t, auth.NewDBThrottleStore(tx))
+	})
+
+	Run(t, "db store resets expired attempts", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		store := auth.NewDBThrottleStore(tx)
+		now := time.Now()
+
+		_, err := store.Increment(ctx, "key", now.Add(-time.Hour), time.Minute)
+		assert.NoError(t, err)
+		a, err := store.Increment(ctx, "key", now.Add(-time.Hour), time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, a.Count)
+
+		a, err = store.Increment(ctx, "key", now, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, a.Count)
+	})
+
+	t.Run("memory store evicts expired attempts", func(t *testing.T) {
+		ctx := context.Background()
+		store := auth.NewMemoryThrottleStore()
+		now := time.Now()
+
+		_, err := store.Increment(ctx, "old", now, time.Minute)
+		assert.NoError(t, err)
+		_, err = store.Increment(ctx, "new", now.Add(2*time.Minute), time.Minute)
+		assert.NoError(t, err)
+
+		a, err := store.Get(ctx, "old")
+		assert.NoError(t, err)
+		assert.Nil(t, a)
+		a, err = store.Get(ctx, "new")
+		assert.NoError(t, err)
+		assert.NotNil(t, a)
+	})
+
+	t.Run("parallel attempts", func(t *testing.T) {
+		ctx := context.Background()
+		throttle := auth.NewLoginThrottle(auth.NewMemoryThrottleStore()).
+			MaxAttempts(3).
+			Delay(0)
+
+		allowed := atomic.Int32{}
+		wg := sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if throttle.Attempt(ctx, "login", "user", "") == nil {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(3), allowed.Load())
+	})
+
+	t.Run("lockout events wait for the outcome", func(t *testing.T) {
+		ctx := context.Background()
+		q := &testQueue{}
+		throttle := auth.NewLoginThrottle(auth.NewMemoryThrottleStore()).
+			MaxAttempts(2).
+			Delay(0).
+			Queue(q)
+
+		assert.NoError(t, throttle.Attempt(ctx, "login", "user", ""))
+		assert.NoError(t, throttle.AttemptFailed(ctx, nullLogger, "login", "user", ""))
+		assert.NoError(t, throttle.Attempt(ctx, "login", "user", ""))
+		assert.NoError(t, throttle.Succeed(ctx, "login", "user", ""))
+		assert.Len(t, q.events, 0)
+
+		assert.NoError(t, throttle.Attempt(ctx, "login", "user", ""))
+		assert.NoError(t, throttle.AttemptFailed(ctx, nullLogger, "login", "user", ""))
+		assert.NoError(t, throttle.Attempt(ctx, "login", "user", ""))
+		assert.Len(t, q.events, 0)
+		assert.NoError(t, throttle.AttemptFailed(ctx, nullLogger, "login", "user", ""))
+		assert.Len(t, q.events, 1)
+	})
+
+	t.Run("exponential delay", func(t *testing.T) {
+		ctx := context.Background()
+		throttle := auth.NewLoginThrottle(auth.NewMemoryThrottleStore()).
+			Delay(time.Minute)
+
+		assert.NoError(t, throttle.Fail(ctx, nullLogger, "login", "user", ""))
+		err := throttle.Check(ctx, "login", "user", "")
+		assert.ErrorIs(t, err, auth.ErrTooManyAttempts)
+		assert.Equal(t, "60", retryAfter(err))
+
+		assert.NoError(t, throttle.Fail(ctx, nullLogger, "login", "user", ""))
+		assert.Equal(t, "120", retryAfter(throttle.Check(ctx, "login", "user", "")))
+	})
+}
+
+func TestAuthRoutesLoginThrottle(t *testing.T) {
+	q := &testQueue{}
+	routes := auth.NewBasicAuthController[*auth.UsernameUser](auth.Throttle(
+		auth.NewLoginThrottle(auth.NewMemoryThrottleStore()).
+			MaxAttempts(2).
+			Delay(0).
+			Queue(q),
+	))
+
+	Run(t, "", func(t *testing.T, tx *sqlx.Tx) {
+		u := &auth.UsernameUser{
+			ID:       uuid.New(),
+			Username: "user",
+		}
+		hash, err := bcrypt.GenerateFromPassword(u.SaltedPassword("pass"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		u.PasswordHash = hash
+		assert.NoError(t, model.Save(tx, u))
+
+		login := func(password string) error {
+			_, err := routes.RunLogin(&auth.LoginRequest{
+				Username: "user",
+				Password: password,
+				Read:     dbtest.Read(tx),
+				Update:   dbtest.Update(tx),
+				Ctx:      context.Background(),
+				Log:      nullLogger,
+				Request:  httptest.NewRequest(http.MethodPost, "/login", http.NoBody),
+			})
+			return err
+		}
+
+		assert.ErrorIs(t, login("wrong"), auth.ErrInvalidUserPass)
+		assert.NoError(t, login("pass"))
+		assert.Len(t, q.events, 0)
+
+		assert.ErrorIs(t, login("wrong"), auth.ErrInvalidUserPass)
+		assert.ErrorIs(t, login("wrong"), auth.ErrInvalidUserPass)
+		assert.Len(t, q.events, 1)
+
+		err = login("pass")
+		assert.ErrorIs(t, err, auth.ErrTooManyAttempts)
+		if httpErr, ok := err.(*request.HTTPError); assert.True(t, ok) {
+			assert.Equal(t, http.StatusTooManyRequests, httpErr.Status())
+		}
+	})
+}
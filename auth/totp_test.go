@@ -0,0 +1,48 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 test secret "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	t.Run("rfc vectors", func(t *testing.T) {
+		vectors := map[int64]string{
+			59:         "287082",
+			1111111109: "081804",
+			1234567890: "005924",
+			2000000000: "279037",
+		}
+		for unix, expected := range vectors {
+			code, err := auth.TOTPCode(secret, time.Unix(unix, 0))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, code)
+		}
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		now := time.Unix(1234567890, 0)
+		assert.True(t, auth.ValidateTOTP(secret, "005924", now))
+		assert.True(t, auth.ValidateTOTP(secret, "005924", now.Add(30*time.Second)))
+		assert.False(t, auth.ValidateTOTP(secret, "005924", now.Add(90*time.Second)))
+		assert.False(t, auth.ValidateTOTP(secret, "000000", now))
+	})
+
+	t.Run("validate step", func(t *testing.T) {
+		now := time.Unix(1234567890, 0)
+		step, ok := auth.ValidateTOTPStep(secret, "005924", now.Add(30*time.Second))
+		assert.True(t, ok)
+		assert.Equal(t, int64(1234567890/30), step)
+	})
+
+	t.Run("provisioning uri", func(t *testing.T) {
+		uri := auth.TOTPProvisioningURI("ABC", "My App", "user@example.com")
+		assert.Equal(t, "otpauth://totp/My%20App:user@example.com?algorithm=SHA1&digits=6&issuer=My+App&period=30&secret=ABC", uri)
+	})
+}
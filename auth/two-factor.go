@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/jsoncolumn"
+	"github.com/abibby/salusa/database/model"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNonTwoFactorUser     = errors.New("user does not support two factor authentication")
+	ErrInvalidTwoFactorCode = errors.New("invalid two factor code")
+	ErrTwoFactorNotEnrolled = errors.New("two factor authentication has not been enrolled")
+	ErrTwoFactorEnabled     = errors.New("two factor authentication is already enabled")
+)
+
+const (
+	recoveryCodeCount       = 10
+	recoveryCodeAlphabet    = "abcdefghijkmnpqrstuvwxyz23456789"
+	mfaPendingTokenLifetime = 5 * time.Minute
+	maxMFAAttempts          = 5
+)
+
+// TwoFactorUser is implemented by users that can enable TOTP two factor
+// authentication. Embed TwoFactor to implement it.
+type TwoFactorUser interface {
+	GetTOTPSecret() string
+	SetTOTPSecret(secret string)
+	TwoFactorEnabled() bool
+	SetTwoFactorEnabled(enabled bool)
+	// GetRecoveryCodes returns the hashes of the unused recovery codes.
+	GetRecoveryCodes() []string
+	SetRecoveryCodes(hashes []string)
+	// GetTOTPLastStep returns the time step of the last accepted TOTP code.
+	// Codes from that step or earlier are rejected so they can't be replayed.
+	GetTOTPLastStep() int64
+	SetTOTPLastStep(step int64)
+	// GetMFATokenID returns the jti of the users outstanding mfa token, only
+	// that token can be exchanged for a login.
+	GetMFATokenID() string
+	SetMFATokenID(id string)
+	// GetMFAAttempts returns the wrong codes sent with the outstanding mfa
+	// token.
+	GetMFAAttempts() int
+	SetMFAAttempts(attempts int)
+}
+
+// TwoFactor stores the TOTP secret and recovery codes of a user.
+type TwoFactor struct {
+	TOTPSecret         string                   `json:"-" db:"totp_secret"`
+	TwoFactorConfirmed bool                     `json:"-" db:"two_factor_enabled"`
+	RecoveryCodes      jsoncolumn.Slice[string] `json:"-" db:"recovery_codes"`
+	TOTPLastStep       int64                    `json:"-" db:"totp_last_step"`
+	MFATokenID         string                   `json:"-" db:"mfa_token_id"`
+	MFAAttempts        int                      `json:"-" db:"mfa_attempts"`
+}
+
+var _ TwoFactorUser = (*TwoFactor)(nil)
+
+func (t *TwoFactor) GetTOTPSecret() string {
+	return t.TOTPSecret
+}
+func (t *TwoFactor) SetTOTPSecret(secret string) {
+	t.TOTPSecret = secret
+}
+func (t *TwoFactor) TwoFactorEnabled() bool {
+	return t.TwoFactorConfirmed
+}
+func (t *TwoFactor) SetTwoFactorEnabled(enabled bool) {
+	t.TwoFactorConfirmed = enabled
+}
+func (t *TwoFactor) GetRecoveryCodes() []string {
+	return t.RecoveryCodes
+}
+func (t *TwoFactor) SetRecoveryCodes(hashes []string) {
+	t.RecoveryCodes = hashes
+}
+func (t *TwoFactor) GetTOTPLastStep() int64 {
+	return t.TOTPLastStep
+}
+func (t *TwoFactor) SetTOTPLastStep(step int64) {
+	t.TOTPLastStep = step
+}
+func (t *TwoFactor) GetMFATokenID() string {
+	return t.MFATokenID
+}
+func (t *TwoFactor) SetMFATokenID(id string) {
+	t.MFATokenID = id
+}
+func (t *TwoFactor) GetMFAAttempts() int {
+	return t.MFAAttempts
+}
+func (t *TwoFactor) SetMFAAttempts(attempts int) {
+	t.MFAAttempts = attempts
+}
+
+// checkTwoFactorCode checks code against the users TOTP secret and recovery
+// codes. A recovery code is removed from the user when it is used and a TOTP
+// code can't be used again.
+func checkTwoFactorCode(u TwoFactorUser, code string) bool {
+	code = strings.TrimSpace(code)
+	if checkTOTP(u, code) {
+		return true
+	}
+
+	hash := hashRecoveryCode(code)
+	hashes := u.GetRecoveryCodes()
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			remaining := make([]string, 0, len(hashes)-1)
+			remaining = append(remaining, hashes[:i]...)
+			remaining = append(remaining, hashes[i+1:]...)
+			u.SetRecoveryCodes(remaining)
+			return true
+		}
+	}
+	return false
+}
+
+// checkTOTP reports if code is a TOTP code for the user newer than the last
+// one accepted and records its step.
+func checkTOTP(u TwoFactorUser, code string) bool {
+	step, ok := ValidateTOTPStep(u.GetTOTPSecret(), code, time.Now())
+	if !ok || step <= u.GetTOTPLastStep() {
+		return false
+	}
+	u.SetTOTPLastStep(step)
+	return true
+}
+
+// generateRecoveryCodes sets new recovery codes on the user and returns them.
+func generateRecoveryCodes(u TwoFactorUser) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate recovery code: %w", err)
+		}
+		code := make([]byte, len(b))
+		for j, c := range b {
+			code[j] = recoveryCodeAlphabet[int(c)%len(recoveryCodeAlphabet)]
+		}
+		codes[i] = string(code[:5]) + "-" + string(code[5:])
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	u.SetRecoveryCodes(hashes)
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+func requiresTwoFactor(u any) bool {
+	tf, ok := u.(TwoFactorUser)
+	return ok && tf.TwoFactorEnabled()
+}
+
+// mfaPendingToken returns a token that can be exchanged for a login with a
+// two factor code. Its jti is saved on the user so only the newest token can
+// be used and only until it succeeds or runs out of attempts.
+func mfaPendingToken(ctx context.Context, tx database.DB, u User) (string, error) {
+	tf, ok := u.(TwoFactorUser)
+	if !ok {
+		return "", ErrNonTwoFactorUser
+	}
+	jti := uuid.NewString()
+	tf.SetMFATokenID(jti)
+	tf.SetMFAAttempts(0)
+	err := model.SaveContext(ctx, tx, u)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := GenerateToken(NewClaims().
+		WithSubject(u.GetID()).
+		WithJWTID(jti).
+		WithLifetime(mfaPendingTokenLifetime).
+		WithScopes(ScopeMFAPending))
+	if err != nil {
+		return "", fmt.Errorf("could not generate mfa token: %w", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,253 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/database/model"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var twoFactorRoutes = auth.NewBasicAuthController[*TwoFactorUser]()
+
+func TestAuthRoutesTwoFactor(t *testing.T) {
+	createUser := func(t *testing.T, tx *sqlx.Tx) *TwoFactorUser {
+		u := &TwoFactorUser{}
+		u.ID = uuid.New()
+		u.Username = "user"
+		hash, err := bcrypt.GenerateFromPassword(u.SaltedPassword("pass"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		u.PasswordHash = hash
+		assert.NoError(t, model.Save(tx, u))
+		return u
+	}
+	enroll := func(t *testing.T, tx *sqlx.Tx, u *TwoFactorUser) []string {
+		ctx := context.Background()
+		enable, err := twoFactorRoutes.RunTwoFactorEnable(&auth.TwoFactorEnableRequest[*TwoFactorUser]{
+			User:   u,
+			Update: dbtest.Update(tx),
+			Ctx:    ctx,
+		})
+		assert.NoError(t, err)
+		assert.Contains(t, enable.URI, "otpauth://totp/")
+
+		code, err := auth.TOTPCode(enable.Secret, time.Now())
+		assert.NoError(t, err)
+		confirm, err := twoFactorRoutes.RunTwoFactorConfirm(&auth.TwoFactorConfirmRequest[*TwoFactorUser]{
+			Code:   code,
+			User:   u,
+			Update: dbtest.Update(tx),
+			Ctx:    ctx,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, confirm.RecoveryCodes, 10)
+		return confirm.RecoveryCodes
+	}
+	login := func(t *testing.T, tx *sqlx.Tx) *auth.LoginResponse {
+		resp, err := twoFactorRoutes.RunLogin(&auth.LoginRequest{
+			Username: "user",
+			Password: "pass",
+			Read:     dbtest.Read(tx),
+			Update:   dbtest.Update(tx),
+			Ctx:      context.Background(),
+			Log:      nullLogger,
+		})
+		assert.NoError(t, err)
+		return resp
+	}
+	twoFactorLogin := func(tx *sqlx.Tx, token, code string) (*auth.LoginResponse, error) {
+		return twoFactorRoutes.RunTwoFactorLogin(&auth.TwoFactorLoginRequest{
+			MFAToken: token,
+			Code:     code,
+			Update:   dbtest.Update(tx),
+			Ctx:      context.Background(),
+			Log:      nullLogger,
+		})
+	}
+
+	Run(t, "login requires code", func(t *testing.T, tx *sqlx.Tx) {
+		u := createUser(t, tx)
+
+		resp := login(t, tx)
+		assert.NotZero(t, resp.AccessToken)
+		assert.Zero(t, resp.MFAToken)
+
+		enroll(t, tx, u)
+
+		resp = login(t, tx)
+		assert.Zero(t, resp.AccessToken)
+		assert.Zero(t, resp.RefreshToken)
+		assert.NotZero(t, resp.MFAToken)
+
+		_, err := twoFactorLogin(tx, resp.MFAToken, "000000")
+		assert.ErrorIs(t, err, auth.ErrInvalidTwoFactorCode)
+
+		code, err := auth.TOTPCode(u.TOTPSecret, time.Now().Add(30*time.Second))
+		assert.NoError(t, err)
+		tokens, err := twoFactorLogin(tx, resp.MFAToken, code)
+		assert.NoError(t, err)
+		assert.NotZero(t, tokens.AccessToken)
+		assert.NotZero(t, tokens.RefreshToken)
+
+		claims, err := auth.Parse(tokens.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, u.GetID(), claims.Subject)
+	})
+
+	Run(t, "mfa token is not an access token", func(t *testing.T, tx *sqlx.Tx) {
+		u := createUser(t, tx)
+		enroll(t, tx, u)
+
+		resp := login(t, tx)
+		claims, err := auth.Parse(resp.MFAToken)
+		assert.NoError(t, err)
+		assert.Equal(t, auth.ScopeStrings{auth.ScopeMFAPending}, claims.Scope)
+
+		access, err := auth.GenerateToken(auth.NewClaims().WithSubject(u.GetID()).WithScopes(auth.ScopeAccess))
+		assert.NoError(t, err)
+		code, err := auth.TOTPCode(u.TOTPSecret, time.Now().Add(30*time.Second))
+		assert.NoError(t, err)
+		_, err = twoFactorLogin(tx, access, code)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	Run(t, "login is throttled", func(t *testing.T, tx *sqlx.Tx) {
+		routes := auth.NewBasicAuthController[*TwoFactorUser](auth.Throttle(
+			auth.NewLoginThrottle(auth.NewMemoryThrottleStore()).
+				MaxAttempts(2).
+				Delay(0),
+		))
+		u := createUser(t, tx)
+		enroll(t, tx, u)
+
+		resp := login(t, tx)
+		twoFactorLogin := func(code string) error {
+			_, err := routes.RunTwoFactorLogin(&auth.TwoFactorLoginRequest{
+				MFAToken: resp.MFAToken,
+				Code:     code,
+				Update:   dbtest.Update(tx),
+				Ctx:      context.Background(),
+				Log:      nullLogger,
+				Request:  httptest.NewRequest(http.MethodPost, "/login/2fa", http.NoBody),
+			})
+			return err
+		}
+
+		assert.ErrorIs(t, twoFactorLogin("000000"), auth.ErrInvalidTwoFactorCode)
+		assert.ErrorIs(t, twoFactorLogin("000000"), auth.ErrInvalidTwoFactorCode)
+
+		code, err := auth.TOTPCode(u.TOTPSecret, time.Now().Add(30*time.Second))
+		assert.NoError(t, err)
+		assert.ErrorIs(t, twoFactorLogin(code), auth.ErrTooManyAttempts)
+	})
+
+	Run(t, "recovery codes are single use", func(t *testing.T, tx *sqlx.Tx) {
+		u := createUser(t, tx)
+		codes := enroll(t, tx, u)
+
+		resp := login(t, tx)
+		_, err := twoFactorLogin(tx, resp.MFAToken, codes[0])
+		assert.NoError(t, err)
+
+		resp = login(t, tx)
+		_, err = twoFactorLogin(tx, resp.MFAToken, codes[0])
+		assert.ErrorIs(t, err, auth.ErrInvalidTwoFactorCode)
+
+		saved, err := builder.From[*TwoFactorUser]().Find(tx, u.ID)
+		assert.NoError(t, err)
+		assert.Len(t, saved.RecoveryCodes, 9)
+	})
+
+	Run(t, "mfa token is single use", func(t *testing.T, tx *sqlx.Tx) {
+		u := createUser(t, tx)
+		codes := enroll(t, tx, u)
+
+		resp := login(t, tx)
+		_, err := twoFactorLogin(tx, resp.MFAToken, codes[0])
+		assert.NoError(t, err)
+
+		_, err = twoFactorLogin(tx, resp.MFAToken, codes[1])
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+		noID, err := auth.GenerateToken(auth.NewClaims().
+			WithSubject(u.GetID()).
+			WithLifetime(time.Minute).
+			WithScopes(auth.ScopeMFAPending))
+		assert.NoError(t, err)
+		_, err = twoFactorLogin(tx, noID, codes[1])
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	Run(t, "mfa token has limited attempts", func(t *testing.T, tx *sqlx.Tx) {
+		u := createUser(t, tx)
+		codes := enroll(t, tx, u)
+
+		resp := login(t, tx)
+		for i := 0; i < 5; i++ {
+			_, err := twoFactorLogin(tx, resp.MFAToken, "000000")
+			assert.ErrorIs(t, err, auth.ErrInvalidTwoFactorCode)
+		}
+
+		_, err := twoFactorLogin(tx, resp.MFAToken, codes[0])
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+		resp = login(t, tx)
+		_, err = twoFactorLogin(tx, resp.MFAToken, codes[0])
+		assert.NoError(t, err)
+	})
+
+	Run(t, "totp codes can't be reused", func(t *testing.T, tx *sqlx.Tx) {
+		u := createUser(t, tx)
+		enroll(t, tx, u)
+
+		code, err := auth.TOTPCode(u.TOTPSecret, time.Now().Add(30*time.Second))
+		assert.NoError(t, err)
+
+		resp := login(t, tx)
+		_, err = twoFactorLogin(tx, resp.MFAToken, code)
+		assert.NoError(t, err)
+
+		resp = login(t, tx)
+		_, err = twoFactorLogin(tx, resp.MFAToken, code)
+		assert.ErrorIs(t, err, auth.ErrInvalidTwoFactorCode)
+
+		previous, err := auth.TOTPCode(u.TOTPSecret, time.Now())
+		assert.NoError(t, err)
+		_, err = twoFactorLogin(tx, resp.MFAToken, previous)
+		assert.ErrorIs(t, err, auth.ErrInvalidTwoFactorCode)
+	})
+
+	Run(t, "disable", func(t *testing.T, tx *sqlx.Tx) {
+		u := createUser(t, tx)
+		enroll(t, tx, u)
+
+		_, err := twoFactorRoutes.RunTwoFactorEnable(&auth.TwoFactorEnableRequest[*TwoFactorUser]{
+			User:   u,
+			Update: dbtest.Update(tx),
+			Ctx:    context.Background(),
+		})
+		assert.ErrorIs(t, err, auth.ErrTwoFactorEnabled)
+
+		code, err := auth.TOTPCode(u.TOTPSecret, time.Now().Add(30*time.Second))
+		assert.NoError(t, err)
+		_, err = twoFactorRoutes.RunTwoFactorDisable(&auth.TwoFactorDisableRequest[*TwoFactorUser]{
+			Code:   code,
+			User:   u,
+			Update: dbtest.Update(tx),
+			Ctx:    context.Background(),
+		})
+		assert.NoError(t, err)
+
+		resp := login(t, tx)
+		assert.NotZero(t, resp.AccessToken)
+	})
+}
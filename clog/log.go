@@ -16,6 +16,9 @@ const (
 	withKey key = iota
 )
 
+// RequestIDKey is the attribute the id of the current request is stored under.
+const RequestIDKey = "request_id"
+
 type RootLogger slog.Logger
 
 type LoggerConfiger interface {
@@ -130,6 +133,18 @@ func With(ctx context.Context, attrs ...slog.Attr) context.Context {
 	return context.WithValue(ctx, withKey, with)
 }
 
+// Value returns the value of the last attribute with the given key added to
+// ctx with With.
+func Value(ctx context.Context, key string) (slog.Value, bool) {
+	with := get(ctx)
+	for i := len(with) - 1; i >= 0; i-- {
+		if attr, ok := with[i].(slog.Attr); ok && attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return slog.Value{}, false
+}
+
 func get(ctx context.Context) []any {
 	iWith := ctx.Value(withKey)
 	if iWith == nil {
@@ -73,3 +73,16 @@ func TestResolve(t *testing.T) {
 		assert.NotNil(t, l)
 	})
 }
+
+func TestValue(t *testing.T) {
+	ctx := context.Background()
+	ctx = clog.With(ctx, slog.String("a", "1"))
+	ctx = clog.With(ctx, slog.String("a", "2"))
+
+	v, ok := clog.Value(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "2", v.String())
+
+	_, ok = clog.Value(ctx, "b")
+	assert.False(t, ok)
+}
@@ -2,10 +2,12 @@ package builder
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 
 	"github.com/abibby/salusa/database"
 	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/internal/helpers"
 )
 
 // BelongsTo represents a belongs to relationship on a model. The parent model
@@ -67,3 +69,26 @@ func (r *BelongsTo[T]) ForeignKeys() []*ForeignKey {
 		RelatedKey:   r.getRelatedKey(),
 	}}
 }
+
+// Associate sets the foreign key on the parent model to the key of related and
+// marks the relationship as loaded. The parent model is not saved.
+func (r *BelongsTo[T]) Associate(related T) error {
+	v, ok := helpers.GetValue(related, r.relatedKey)
+	if !ok {
+		return fmt.Errorf("%s has no field %s: %w", reflect.TypeOf(related), r.relatedKey, ErrMissingField)
+	}
+	field, err := helpers.RGetValue(reflect.ValueOf(r.parent), r.parentKey)
+	if err != nil {
+		return fmt.Errorf("%s has no field %s: %w", reflect.TypeOf(r.parent), r.parentKey, ErrMissingField)
+	}
+
+	rv := reflect.ValueOf(v)
+	if !rv.Type().ConvertibleTo(field.Type()) {
+		return fmt.Errorf("can not assign %s to %s", rv.Type(), field.Type())
+	}
+	field.Set(rv.Convert(field.Type()))
+
+	r.value = related
+	r.loaded = true
+	return nil
+}
@@ -2,6 +2,7 @@ package builder
 
 import (
 	"context"
+	"time"
 
 	"github.com/abibby/salusa/database"
 	"github.com/abibby/salusa/database/model"
@@ -28,6 +29,7 @@ type Builder struct {
 	orderBys orderBys
 	scopes   *scopes
 	ctx      context.Context
+	cacheTTL time.Duration
 }
 
 // ModelBuilder represents an sql query and any bindings needed to run it.
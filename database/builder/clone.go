@@ -19,6 +19,7 @@ func (b *Builder) Clone() *Builder {
 		orderBys: b.orderBys.Clone(),
 		scopes:   b.scopes.Clone(),
 		ctx:      b.ctx,
+		cacheTTL: b.cacheTTL,
 	}
 }
 
@@ -1,7 +1,11 @@
 package builder
 
 import (
+	"fmt"
+	"slices"
+
 	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/cache"
 	"github.com/abibby/salusa/database/dialects"
 	"github.com/abibby/salusa/internal/helpers"
 )
@@ -30,11 +34,30 @@ func delete(b *Builder, tx database.DB) error {
 	if err != nil {
 		return err
 	}
+	err = cache.FlushTableAfterWrite(b.ctx, tx, b.GetTable())
+	if err != nil {
+		return fmt.Errorf("cache flush: %w", err)
+	}
 	return nil
 }
+
+// Delete deletes the rows matching the query. The delete is wrapped by the
+// Delete function of each active scope. SoftDeleteScope replaces the delete
+// with an update so it is always applied first, that way the other scopes wrap
+// soft deletes the same way they wrap deletes.
 func (b *Builder) Delete(tx database.DB) error {
+	scopes := slices.Clone(b.ActiveScopes())
+	slices.SortStableFunc(scopes, func(a, b *Scope) int {
+		if isSoftDeleteScope(a) == isSoftDeleteScope(b) {
+			return 0
+		} else if isSoftDeleteScope(a) {
+			return -1
+		}
+		return 1
+	})
+
 	current := delete
-	for _, s := range b.ActiveScopes() {
+	for _, s := range scopes {
 		if s.Delete != nil {
 			current = s.Delete(current)
 		}
@@ -196,6 +196,20 @@ func (b *Builder) Distinct() *Builder {
 	return b
 }
 
+// WithTrashed includes soft deleted rows in the query.
+func (b *Builder) WithTrashed() *Builder {
+	b = b.Clone()
+	b.scopes = b.scopes.WithTrashed()
+	return b
+}
+
+// OnlyTrashed limits the query to soft deleted rows.
+func (b *Builder) OnlyTrashed() *Builder {
+	b = b.Clone()
+	b.scopes = b.scopes.OnlyTrashed()
+	return b
+}
+
 // Where adds a basic where clause to the query.
 func (b *Builder) Where(column, operator string, value any) *Builder {
 	b = b.Clone()
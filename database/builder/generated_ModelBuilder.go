@@ -1,6 +1,9 @@
 package builder
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // WithContext adds a context to the query that will be used when fetching results.
 func (b *ModelBuilder[T]) WithContext(ctx context.Context) *ModelBuilder[T] {
@@ -205,6 +208,20 @@ func (b *ModelBuilder[T]) Distinct() *ModelBuilder[T] {
 	return b
 }
 
+// WithTrashed includes soft deleted rows in the query.
+func (b *ModelBuilder[T]) WithTrashed() *ModelBuilder[T] {
+	b = b.Clone()
+	b.builder = b.builder.WithTrashed()
+	return b
+}
+
+// OnlyTrashed limits the query to soft deleted rows.
+func (b *ModelBuilder[T]) OnlyTrashed() *ModelBuilder[T] {
+	b = b.Clone()
+	b.builder = b.builder.OnlyTrashed()
+	return b
+}
+
 // Where adds a basic where clause to the query.
 func (b *ModelBuilder[T]) Where(column, operator string, value any) *ModelBuilder[T] {
 	b = b.Clone()
@@ -456,6 +473,24 @@ func (b *ModelBuilder[T]) HavingOr(cb func(q *Conditions)) *ModelBuilder[T] {
 	b.builder = b.builder.HavingOr(cb)
 	return b
 }
+
+// Remember caches the result of the query in cache.Default for ttl. Cached
+// results are flushed when a model from the query's table is saved or the table
+// is updated or deleted from with a builder. Changes made to other tables, e.g.
+// joined tables, or with raw sql do not flush the cache.
+func (b *ModelBuilder[T]) Remember(ttl time.Duration) *ModelBuilder[T] {
+	b = b.Clone()
+	b.builder = b.builder.Remember(ttl)
+	return b
+}
+
+// Search limits the query to rows whose searchable columns match term using
+// the dialects full text search.
+func (b *ModelBuilder[T]) Search(term string) *ModelBuilder[T] {
+	b = b.Clone()
+	b.builder = b.builder.Search(term)
+	return b
+}
 func (b *ModelBuilder[T]) Dump() *ModelBuilder[T] {
 	b = b.Clone()
 	b.builder = b.builder.Dump()
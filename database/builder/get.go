@@ -1,12 +1,14 @@
 package builder
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 
 	"github.com/abibby/salusa/database"
 	"github.com/abibby/salusa/database/dialects"
 	"github.com/abibby/salusa/database/hooks"
+	"github.com/abibby/salusa/database/model"
 	"github.com/abibby/salusa/internal/helpers"
 	"github.com/abibby/salusa/internal/relationship"
 	"github.com/jmoiron/sqlx"
@@ -91,10 +93,10 @@ func (b *Builder) Load(tx database.DB, v any) (err error) {
 			query: q,
 		}
 	}()
-	if reflect.TypeOf(v).Elem().Kind() == reflect.Slice {
-		err = sqlx.SelectContext(b.Context(), tx, v, q, bindings...)
+	if b.cacheTTL > 0 {
+		err = b.loadCached(tx, v, q, bindings)
 	} else {
-		err = sqlx.GetContext(b.Context(), tx, v, q, bindings...)
+		err = load(b.Context(), tx, v, q, bindings)
 	}
 	if err != nil {
 		return err
@@ -105,6 +107,8 @@ func (b *Builder) Load(tx database.DB, v any) (err error) {
 		return err
 	}
 
+	model.SyncOriginal(v)
+
 	err = hooks.AfterLoad(b.Context(), tx, v)
 	if err != nil {
 		return err
@@ -112,6 +116,13 @@ func (b *Builder) Load(tx database.DB, v any) (err error) {
 	return nil
 }
 
+func load(ctx context.Context, tx database.DB, v any, q string, bindings []any) error {
+	if reflect.TypeOf(v).Elem().Kind() == reflect.Slice {
+		return sqlx.SelectContext(ctx, tx, v, q, bindings...)
+	}
+	return sqlx.GetContext(ctx, tx, v, q, bindings...)
+}
+
 // Load executes the query as a select statement and sets v to the result.
 //
 // Deprecated: Use ModelBuilder.Load
@@ -11,9 +11,12 @@ import (
 // # Tags:
 //   - local: parent model
 //   - foreign: related model
+//   - cascade: set to "delete" to delete related models when the parent is
+//     soft deleted, restored or force deleted
 type HasMany[T model.Model] struct {
 	hasOneOrMany[T]
 	relationValue[[]T]
+	cascade bool
 }
 
 var _ Relationship = &HasMany[model.Model]{}
@@ -31,6 +34,7 @@ func (r *HasMany[T]) Initialize(parent any, field reflect.StructField) error {
 
 	r.parentKey = parentKey
 	r.relatedKey = relatedKey
+	r.cascade = field.Tag.Get("cascade") == "delete"
 	return nil
 }
 func (r *HasMany[T]) Load(ctx context.Context, tx database.DB, relations []Relationship) error {
@@ -51,3 +55,32 @@ func (r *HasMany[T]) Load(ctx context.Context, tx database.DB, relations []Relat
 func (r *HasMany[T]) ForeignKeys() []*ForeignKey {
 	return []*ForeignKey{}
 }
+
+func (r *HasMany[T]) cascadeDelete(ctx context.Context, tx database.DB, parent *Builder) error {
+	if !r.cascade {
+		return nil
+	}
+	return r.children(ctx, parent).Delete(tx)
+}
+
+func (r *HasMany[T]) cascadeRestore(ctx context.Context, tx database.DB, parent *Builder) error {
+	children := r.children(ctx, parent)
+	if !r.cascade || !children.SoftDeletes() {
+		return nil
+	}
+	return children.Restore(tx)
+}
+
+func (r *HasMany[T]) cascadeForceDelete(ctx context.Context, tx database.DB, parent *Builder) error {
+	if !r.cascade {
+		return nil
+	}
+	return r.children(ctx, parent).ForceDelete(tx)
+}
+
+// children returns a query for the related models of the parent query.
+func (r *HasMany[T]) children(ctx context.Context, parent *Builder) *ModelBuilder[T] {
+	return From[T]().
+		WithContext(ctx).
+		Where(r.relatedKey, "in", parent.Select(parent.GetTable()+"."+r.parentKey))
+}
@@ -0,0 +1,241 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/cache"
+	"github.com/abibby/salusa/internal/helpers"
+)
+
+func init() {
+	gob.Register(map[string]any{})
+	gob.Register(time.Time{})
+}
+
+// Remember caches the result of the query in cache.Default for ttl. Cached
+// results are flushed when a model from the query's table is saved or the table
+// is updated or deleted from with a builder. Changes made to other tables, e.g.
+// joined tables, or with raw sql do not flush the cache.
+//
+// Queries run in a transaction skip the cache once the transaction has
+// written with a builder or model.Save, they may see changes that are not
+// committed yet. Transactions that were not started by a database.Update or
+// database.Read always skip the cache.
+func (b *Builder) Remember(ttl time.Duration) *Builder {
+	b = b.Clone()
+	b.cacheTTL = ttl
+	return b
+}
+
+func (b *Builder) loadCached(tx database.DB, v any, q string, bindings []any) error {
+	ctx := b.Context()
+	if database.HasWritten(tx) {
+		return load(ctx, tx, v, q, bindings)
+	}
+	store := cache.Default()
+
+	key, err := cacheKey(database.ConnectionName(tx), q, bindings)
+	if err != nil {
+		return err
+	}
+
+	cached, ok, err := store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("cache get: %w", err)
+	}
+	if ok {
+		err = decodeCached(cached, reflect.ValueOf(v).Elem())
+		if err == nil {
+			return nil
+		}
+		// fall through and replace entries that can't be decoded
+	}
+
+	err = load(ctx, tx, v, q, bindings)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := encodeCached(reflect.ValueOf(v).Elem())
+	if err != nil {
+		// the result can't be cached but the query still succeeded
+		return nil
+	}
+	err = store.Set(ctx, key, encoded, b.cacheTTL, []string{cache.TableTag(b.GetTable())})
+	if err != nil {
+		return fmt.Errorf("cache set: %w", err)
+	}
+	return nil
+}
+
+func cacheKey(connection, q string, bindings []any) (string, error) {
+	values := make([]any, len(bindings))
+	for i, binding := range bindings {
+		v, err := driver.DefaultParameterConverter.ConvertValue(binding)
+		if err != nil {
+			return "", err
+		}
+		values[i] = v
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%#v", connection, q, values)))
+	return "query:" + hex.EncodeToString(sum[:]), nil
+}
+
+// encodeCached encodes rv as a list of rows. Structs are stored as a map of
+// columns to the values that would be written to the database so that they
+// can be scanned back into a new struct.
+func encodeCached(rv reflect.Value) ([]byte, error) {
+	rows := []any{}
+	if rv.Kind() == reflect.Slice {
+		for i := 0; i < rv.Len(); i++ {
+			row, err := encodeRow(rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+	} else {
+		row, err := encodeRow(rv)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	buf := &bytes.Buffer{}
+	err := gob.NewEncoder(buf).Encode(rows)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeRow(rv reflect.Value) (any, error) {
+	if !isModelStruct(rv.Type()) {
+		return driver.DefaultParameterConverter.ConvertValue(rv.Interface())
+	}
+	row := map[string]any{}
+	err := helpers.EachField(rv, func(sf reflect.StructField, fv reflect.Value) error {
+		name, ok := cachedFieldName(sf)
+		if !ok {
+			return nil
+		}
+		v, err := driver.DefaultParameterConverter.ConvertValue(fv.Addr().Interface())
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		row[name] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+func decodeCached(b []byte, rv reflect.Value) error {
+	rows := []any{}
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&rows)
+	if err != nil {
+		return err
+	}
+
+	if rv.Kind() == reflect.Slice {
+		result := reflect.MakeSlice(rv.Type(), len(rows), len(rows))
+		for i, row := range rows {
+			elem := helpers.Create(rv.Type().Elem())
+			err = decodeRow(row, elem)
+			if err != nil {
+				return err
+			}
+			result.Index(i).Set(elem)
+		}
+		rv.Set(result)
+		return nil
+	}
+
+	if len(rows) != 1 {
+		return sql.ErrNoRows
+	}
+	return decodeRow(rows[0], rv)
+}
+
+func decodeRow(row any, rv reflect.Value) error {
+	if !isModelStruct(rv.Type()) {
+		return setCachedValue(rv, row)
+	}
+	columns, ok := row.(map[string]any)
+	if !ok {
+		return fmt.Errorf("expected a row for %s, found %T", rv.Type(), row)
+	}
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		rv.Set(reflect.New(rv.Type().Elem()))
+	}
+	return helpers.EachField(rv, func(sf reflect.StructField, fv reflect.Value) error {
+		name, ok := cachedFieldName(sf)
+		if !ok {
+			return nil
+		}
+		return setCachedValue(fv, columns[name])
+	})
+}
+
+func setCachedValue(fv reflect.Value, v any) error {
+	if scanner, ok := fv.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(v)
+	}
+	if v == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+	if fv.Kind() == reflect.Pointer {
+		p := reflect.New(fv.Type().Elem())
+		err := setCachedValue(p.Elem(), v)
+		if err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.Type().ConvertibleTo(fv.Type()) {
+		return fmt.Errorf("cannot convert cached %T to %s", v, fv.Type())
+	}
+	fv.Set(rv.Convert(fv.Type()))
+	return nil
+}
+
+var (
+	scannerType      = reflect.TypeFor[sql.Scanner]()
+	timeType         = reflect.TypeFor[time.Time]()
+	relationshipType = reflect.TypeFor[Relationship]()
+)
+
+func isModelStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct &&
+		t != timeType &&
+		!reflect.PointerTo(t).Implements(scannerType)
+}
+
+func cachedFieldName(sf reflect.StructField) (string, bool) {
+	if !sf.IsExported() || sf.Type.Implements(relationshipType) {
+		return "", false
+	}
+	name := helpers.FieldName(sf)
+	if name == "-" {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,212 @@
+package builder_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/cache"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/internal/test"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemember(t *testing.T) {
+	setup := func(t *testing.T, tx database.DB) *test.Foo {
+		cache.SetStore(cache.NewMemoryStore())
+		foo := &test.Foo{Name: "a"}
+		MustSave(tx, foo)
+		return foo
+	}
+
+	test.RunNoTx(t, "caches results", func(t *testing.T, tx *sqlx.DB) {
+		foo := setup(t, tx)
+
+		cached, err := builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "a", cached.Name)
+
+		_, err = tx.Exec(`UPDATE "foos" SET "name" = 'b'`)
+		assert.NoError(t, err)
+
+		cached, err = builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, foo.ID, cached.ID)
+		assert.Equal(t, "a", cached.Name)
+		assert.False(t, model.IsDirty(cached))
+
+		count, err := builder.From[*test.Foo]().Remember(time.Minute).Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		uncached, err := builder.From[*test.Foo]().Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "b", uncached.Name)
+	})
+
+	test.RunNoTx(t, "expires", func(t *testing.T, tx *sqlx.DB) {
+		foo := setup(t, tx)
+
+		_, err := builder.From[*test.Foo]().Remember(time.Millisecond).Find(tx, foo.ID)
+		assert.NoError(t, err)
+
+		_, err = tx.Exec(`UPDATE "foos" SET "name" = 'b'`)
+		assert.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+
+		cached, err := builder.From[*test.Foo]().Remember(time.Millisecond).Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "b", cached.Name)
+	})
+
+	test.RunNoTx(t, "save flushes", func(t *testing.T, tx *sqlx.DB) {
+		foo := setup(t, tx)
+
+		_, err := builder.From[*test.Foo]().Remember(time.Minute).Get(tx)
+		assert.NoError(t, err)
+
+		MustSave(tx, &test.Foo{Name: "b"})
+
+		foos, err := builder.From[*test.Foo]().Remember(time.Minute).Get(tx)
+		assert.NoError(t, err)
+		assert.Len(t, foos, 2)
+
+		foo.Name = "c"
+		MustSave(tx, foo)
+
+		cached, err := builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "c", cached.Name)
+	})
+
+	test.RunNoTx(t, "update and delete flush", func(t *testing.T, tx *sqlx.DB) {
+		foo := setup(t, tx)
+
+		_, err := builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+		assert.NoError(t, err)
+
+		err = builder.From[*test.Foo]().Update(tx, builder.Updates{"name": "b"})
+		assert.NoError(t, err)
+
+		cached, err := builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "b", cached.Name)
+
+		err = builder.From[*test.Foo]().Delete(tx)
+		assert.NoError(t, err)
+
+		cached, err = builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, cached)
+	})
+
+	test.RunNoTx(t, "nullable columns", func(t *testing.T, tx *sqlx.DB) {
+		cache.SetStore(cache.NewMemoryStore())
+		MustSave(tx, &test.FooSoftDelete{Name: "a"})
+		MustSave(tx, &test.FooSoftDelete{Name: "b"})
+		err := builder.From[*test.FooSoftDelete]().Where("name", "=", "b").Delete(tx)
+		assert.NoError(t, err)
+
+		for range 2 {
+			foos, err := builder.From[*test.FooSoftDelete]().WithTrashed().Remember(time.Minute).OrderBy("id").Get(tx)
+			assert.NoError(t, err)
+			if assert.Len(t, foos, 2) {
+				assert.Nil(t, foos[0].DeletedAt)
+				assert.NotNil(t, foos[1].DeletedAt)
+			}
+		}
+	})
+
+	test.Run(t, "transactions skip the cache", func(t *testing.T, tx *sqlx.Tx) {
+		foo := setup(t, tx)
+
+		_, err := builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+		assert.NoError(t, err)
+
+		_, err = tx.Exec(`UPDATE "foos" SET "name" = 'b'`)
+		assert.NoError(t, err)
+
+		cached, err := builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "b", cached.Name)
+	})
+
+	test.RunNoTx(t, "request transactions", func(t *testing.T, db *sqlx.DB) {
+		foo := setup(t, db)
+		ctx := context.Background()
+
+		type findRequest struct {
+			Read database.Read
+		}
+		find := func(r *findRequest) (*test.Foo, error) {
+			return database.Value(r.Read, func(tx *sqlx.Tx) (*test.Foo, error) {
+				return builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+			})
+		}
+		r := &findRequest{Read: database.NewRead(ctx, nil, db)}
+
+		cached, err := find(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "a", cached.Name)
+
+		_, err = db.Exec(`UPDATE "foos" SET "name" = 'b'`)
+		assert.NoError(t, err)
+
+		cached, err = find(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "a", cached.Name)
+
+		err = database.NewUpdate(ctx, nil, db)(func(tx *sqlx.Tx) error {
+			cached, err := builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, "a", cached.Name)
+
+			foo.Name = "c"
+			MustSave(tx, foo)
+
+			uncached, err := builder.From[*test.Foo]().Remember(time.Minute).Find(tx, foo.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, "c", uncached.Name)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		cached, err = find(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "c", cached.Name)
+	})
+
+	test.RunNoTx(t, "flushes again after commit", func(t *testing.T, db *sqlx.DB) {
+		store := &countingStore{MemoryStore: cache.NewMemoryStore()}
+		cache.SetStore(store)
+		update := database.NewUpdate(context.Background(), nil, db)
+
+		err := update(func(tx *sqlx.Tx) error {
+			return model.Save(tx, &test.Foo{Name: "a"})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, store.flushes)
+
+		store.flushes = 0
+		err = update(func(tx *sqlx.Tx) error {
+			MustSave(tx, &test.Foo{Name: "b"})
+			return errors.New("rollback")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, store.flushes)
+	})
+}
+
+type countingStore struct {
+	*cache.MemoryStore
+	flushes int
+}
+
+func (s *countingStore) Flush(ctx context.Context, tags ...string) error {
+	s.flushes++
+	return s.MemoryStore.Flush(ctx, tags...)
+}
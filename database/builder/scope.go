@@ -1,6 +1,8 @@
 package builder
 
 import (
+	"reflect"
+
 	"github.com/abibby/salusa/database"
 	"github.com/abibby/salusa/extra/sets"
 )
@@ -63,8 +65,7 @@ func (s *scopes) WithoutScope(scope *Scope) *scopes {
 }
 
 func (s *scopes) allScopes() []*Scope {
-	if scoper, ok := s.parent.(Scoper); ok {
-		allGlobalScopes := scoper.Scopes()
+	if allGlobalScopes := globalScopes(s.parent); len(allGlobalScopes) > 0 {
 		combinedScopes := make([]*Scope, len(s.scopes), len(s.scopes)+len(allGlobalScopes))
 		copy(combinedScopes, s.scopes)
 
@@ -80,6 +81,37 @@ func (s *scopes) allScopes() []*Scope {
 	return s.scopes
 }
 
+// globalScopes returns the scopes of a model. Models that embed more than one
+// struct with scopes, like SoftDelete and Auditable, don't get a promoted
+// Scopes method, unless they implement Scopes themselves they get the scopes of
+// all their embedded structs.
+func globalScopes(m any) []*Scope {
+	if scoper, ok := m.(Scoper); ok {
+		return scoper.Scopes()
+	}
+	v := reflect.Indirect(reflect.ValueOf(m))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	var scopes []*Scope
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		if !t.Field(i).Anonymous || !t.Field(i).IsExported() {
+			continue
+		}
+		f := v.Field(i)
+		if f.Kind() == reflect.Pointer {
+			if f.IsNil() {
+				continue
+			}
+		} else if f.CanAddr() {
+			f = f.Addr()
+		}
+		scopes = append(scopes, globalScopes(f.Interface())...)
+	}
+	return scopes
+}
+
 // WithoutGlobalScope removes a global scope from the query.
 func (b *scopes) WithoutGlobalScope(scope *Scope) *scopes {
 	b.withoutGlobalScopes.Add(scope.Name)
@@ -92,3 +124,9 @@ func (b *Builder) ActiveScopes() []*Scope {
 func (b *ModelBuilder[T]) ActiveScopes() []*Scope {
 	return b.builder.ActiveScopes()
 }
+
+// GetModel returns the model the query was created for or nil if it was
+// created with NewBuilder.
+func (b *Builder) GetModel() any {
+	return b.scopes.parent
+}
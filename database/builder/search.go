@@ -0,0 +1,54 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/abibby/salusa/database/dialects"
+	"github.com/abibby/salusa/internal/helpers"
+)
+
+var ErrNotSearchable = errors.New("model does not implement Searchable")
+
+// Searchable is implemented by models that can be queried with Search.
+type Searchable interface {
+	// SearchColumns returns the columns included in the full text index.
+	SearchColumns() []string
+}
+
+// Search limits the query to rows whose searchable columns match term using
+// the dialects full text search.
+func (b *Builder) Search(term string) *Builder {
+	b = b.Clone()
+	b.wheres.addWhere(&where{
+		Value: &searchCondition{
+			model: b.GetModel(),
+			table: b.GetTable(),
+			term:  term,
+		},
+	})
+	return b
+}
+
+type searchCondition struct {
+	model any
+	table string
+	term  string
+}
+
+func (s *searchCondition) SQLString(d dialects.Dialect) (string, []any, error) {
+	m, ok := s.model.(Searchable)
+	if !ok {
+		return "", nil, fmt.Errorf("%T: %w", s.model, ErrNotSearchable)
+	}
+	searcher, ok := d.(dialects.Searcher)
+	if !ok {
+		return "", nil, dialects.ErrSearchNotSupported
+	}
+	pKeys := helpers.PrimaryKey(m)
+	if len(pKeys) != 1 {
+		return "", nil, fmt.Errorf("search requires a single primary key, %T has %d", m, len(pKeys))
+	}
+	sql, bindings := searcher.SearchCondition(s.table, pKeys[0], m.SearchColumns(), s.term)
+	return helpers.Raw(sql, bindings...).SQLString(d)
+}
@@ -0,0 +1,73 @@
+package builder_test
+
+import (
+	"testing"
+
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/dialects"
+	"github.com/abibby/salusa/database/dialects/mysql"
+	"github.com/abibby/salusa/database/dialects/postgres"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/internal/test"
+)
+
+type Article struct {
+	model.BaseModel
+	ID    int    `db:"id,primary,autoincrement"`
+	Title string `db:"title"`
+	Body  string `db:"body"`
+}
+
+func (*Article) Table() string {
+	return "articles"
+}
+
+func (*Article) SearchColumns() []string {
+	return []string{"title", "body"}
+}
+
+type unsupportedDialect struct {
+	dialects.Dialect
+}
+
+func TestSearch(t *testing.T) {
+	test.QueryTest(t, []test.Case{
+		{
+			Name:             "sqlite",
+			Builder:          builder.From[*Article]().Search(`go "fast`),
+			ExpectedSQL:      `SELECT "articles".* FROM "articles" WHERE "articles"."id" IN (SELECT "search_key" FROM "articles_search" WHERE "articles_search" MATCH ?)`,
+			ExpectedBindings: []any{`"go" """fast"`},
+		},
+		{
+			Name:             "sqlite empty term",
+			Builder:          builder.From[*Article]().Search("  "),
+			ExpectedSQL:      `SELECT "articles".* FROM "articles" WHERE 0 = 1`,
+			ExpectedBindings: []any{},
+		},
+		{
+			Name:             "postgres",
+			Builder:          builder.From[*Article]().Search("go fast"),
+			ExpectedSQL:      `SELECT "articles".* FROM "articles" WHERE to_tsvector('english', coalesce("articles"."title"::text, '') || ' ' || coalesce("articles"."body"::text, '')) @@ plainto_tsquery('english', $1)`,
+			ExpectedBindings: []any{"go fast"},
+			Dialect:          &postgres.Posgtgres{},
+		},
+		{
+			Name:             "mysql",
+			Builder:          builder.From[*Article]().Search("go fast"),
+			ExpectedSQL:      "SELECT `articles`.* FROM `articles` WHERE MATCH (`articles`.`title`, `articles`.`body`) AGAINST (? IN NATURAL LANGUAGE MODE)",
+			ExpectedBindings: []any{"go fast"},
+			Dialect:          &mysql.MySQL{},
+		},
+		{
+			Name:          "not searchable",
+			Builder:       NewTestBuilder().Search("go"),
+			ExpectedError: builder.ErrNotSearchable,
+		},
+		{
+			Name:          "unsupported dialect",
+			Builder:       builder.From[*Article]().Search("go"),
+			ExpectedError: dialects.ErrSearchNotSupported,
+			Dialect:       unsupportedDialect{dialects.New()},
+		},
+	})
+}
@@ -0,0 +1,157 @@
+package builder
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/abibby/salusa/database"
+)
+
+// SoftDeleteScope hides rows with a deleted_at and turns deletes into an
+// update of deleted_at. Deleting also deletes any HasMany relationships tagged
+// with cascade:"delete", Restore and ForceDelete cascade to them the same way.
+var SoftDeleteScope = &Scope{
+	Name: "soft-deletes",
+	Query: func(b *Builder) *Builder {
+		return b.Where(b.GetTable()+".deleted_at", "=", nil)
+	},
+	Delete: func(next func(q *Builder, tx database.DB) error) func(q *Builder, tx database.DB) error {
+		return func(q *Builder, tx database.DB) error {
+			err := cascadeDelete(q, tx)
+			if err != nil {
+				return err
+			}
+			return q.Update(tx, Updates{
+				"deleted_at": time.Now(),
+			})
+		}
+	},
+}
+
+var onlyTrashedScope = &Scope{
+	Name: "only-trashed",
+	Query: func(b *Builder) *Builder {
+		return b.Where(b.GetTable()+".deleted_at", "!=", nil)
+	},
+}
+
+// WithTrashed includes soft deleted rows in the query.
+func (s *scopes) WithTrashed() *scopes {
+	return s.WithoutGlobalScope(SoftDeleteScope)
+}
+
+// OnlyTrashed limits the query to soft deleted rows.
+func (s *scopes) OnlyTrashed() *scopes {
+	return s.WithoutGlobalScope(SoftDeleteScope).WithScope(onlyTrashedScope)
+}
+
+// Restore clears deleted_at on the soft deleted rows matching the query and
+// their soft deleted cascade:"delete" HasMany relationships.
+func (b *ModelBuilder[T]) Restore(tx database.DB) error {
+	return b.builder.Restore(tx)
+}
+
+// Restore clears deleted_at on the soft deleted rows matching the query and
+// their soft deleted cascade:"delete" HasMany relationships.
+func (b *Builder) Restore(tx database.DB) error {
+	q := b.OnlyTrashed()
+	err := cascade(q, func(c cascader, parent *Builder) error {
+		return c.cascadeRestore(q.ctx, tx, parent)
+	})
+	if err != nil {
+		return err
+	}
+	return q.Update(tx, Updates{
+		"deleted_at": nil,
+	})
+}
+
+// ForceDelete permanently deletes the rows matching the query, including
+// soft deleted rows, and their cascade:"delete" HasMany relationships.
+func (b *ModelBuilder[T]) ForceDelete(tx database.DB) error {
+	return b.builder.ForceDelete(tx)
+}
+
+// ForceDelete permanently deletes the rows matching the query, including
+// soft deleted rows, and their cascade:"delete" HasMany relationships.
+func (b *Builder) ForceDelete(tx database.DB) error {
+	q := b.WithTrashed()
+	err := cascade(q, func(c cascader, parent *Builder) error {
+		return c.cascadeForceDelete(q.ctx, tx, parent)
+	})
+	if err != nil {
+		return err
+	}
+	return q.Delete(tx)
+}
+
+// SoftDeletes reports if deletes from the query are soft deletes.
+func (b *Builder) SoftDeletes() bool {
+	return slices.ContainsFunc(b.ActiveScopes(), isSoftDeleteScope)
+}
+
+// SoftDeletes reports if deletes from the query are soft deletes.
+func (b *ModelBuilder[T]) SoftDeletes() bool {
+	return b.builder.SoftDeletes()
+}
+
+func isSoftDeleteScope(s *Scope) bool {
+	return s.Name == SoftDeleteScope.Name
+}
+
+type cascader interface {
+	cascadeDelete(ctx context.Context, tx database.DB, parent *Builder) error
+	cascadeRestore(ctx context.Context, tx database.DB, parent *Builder) error
+	cascadeForceDelete(ctx context.Context, tx database.DB, parent *Builder) error
+}
+
+func cascadeDelete(q *Builder, tx database.DB) error {
+	return cascade(q, func(c cascader, parent *Builder) error {
+		return c.cascadeDelete(q.ctx, tx, parent)
+	})
+}
+
+// cascade calls cb with the relationships of the query's model that cascade.
+func cascade(q *Builder, cb func(c cascader, parent *Builder) error) error {
+	parent := q.GetModel()
+	if parent == nil {
+		return nil
+	}
+	for _, r := range relationships(reflect.ValueOf(parent)) {
+		c, ok := r.(cascader)
+		if !ok {
+			continue
+		}
+		err := cb(c, q)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func relationships(rv reflect.Value) []Relationship {
+	rv = reflect.Indirect(rv)
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	result := []Relationship{}
+	t := rv.Type()
+	for i := 0; i < rv.NumField(); i++ {
+		ft := t.Field(i)
+		if ft.Anonymous {
+			result = append(result, relationships(rv.Field(i))...)
+			continue
+		}
+		if !ft.IsExported() {
+			continue
+		}
+		if r, ok := rv.Field(i).Interface().(Relationship); ok {
+			result = append(result, r)
+		}
+	}
+	return result
+}
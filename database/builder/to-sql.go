@@ -9,10 +9,7 @@ func (b *ModelBuilder[T]) SQLString(d dialects.Dialect) (string, []any, error) {
 	return b.builder.SQLString(d)
 }
 func (b *Builder) SQLString(d dialects.Dialect) (string, []any, error) {
-	b = b.Clone()
-	for _, scope := range b.scopes.allScopes() {
-		b = scope.Query(b)
-	}
+	b = b.withScopes()
 	return helpers.Result().
 		Add(b.selects).
 		Add(b.from).
@@ -24,3 +21,14 @@ func (b *Builder) SQLString(d dialects.Dialect) (string, []any, error) {
 		Add(b.limit).
 		SQLString(d)
 }
+
+// withScopes returns a copy of b with the query scopes applied.
+func (b *Builder) withScopes() *Builder {
+	b = b.Clone()
+	for _, scope := range b.scopes.allScopes() {
+		if scope.Query != nil {
+			b = scope.Query(b)
+		}
+	}
+	return b
+}
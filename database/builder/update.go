@@ -2,10 +2,12 @@ package builder
 
 import (
 	"errors"
+	"fmt"
 	"slices"
 	"strings"
 
 	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/cache"
 	"github.com/abibby/salusa/database/dialects"
 	"github.com/abibby/salusa/internal/helpers"
 )
@@ -55,16 +57,26 @@ func (d *Updater) SQLString(dialect dialects.Dialect) (string, []any, error) {
 		helpers.Join(sets, ", "),
 	}
 
-	if len(d.builder.wheres.list) > 0 {
-		parts = append(parts, d.builder.wheres)
+	wheres := d.builder.withScopes().wheres
+	if len(wheres.list) > 0 {
+		parts = append(parts, wheres)
 	}
 
 	return helpers.Join(parts, " ").SQLString(dialect)
 }
 
+// Update sets the columns in updates on the rows matching the query. Global
+// scopes apply to updates the same way they do to selects, so rows hidden by a
+// scope, like soft deleted rows, are only updated after WithTrashed or
+// WithoutGlobalScope.
 func (b *ModelBuilder[T]) Update(tx database.DB, updates Updates) error {
 	return b.builder.Update(tx, updates)
 }
+
+// Update sets the columns in updates on the rows matching the query. Global
+// scopes apply to updates the same way they do to selects, so rows hidden by a
+// scope, like soft deleted rows, are only updated after WithTrashed or
+// WithoutGlobalScope.
 func (b *Builder) Update(tx database.DB, updates Updates) error {
 	if len(updates) == 0 {
 		return nil
@@ -78,6 +90,10 @@ func (b *Builder) Update(tx database.DB, updates Updates) error {
 	if err != nil {
 		return err
 	}
+	err = cache.FlushTableAfterWrite(b.ctx, tx, b.GetTable())
+	if err != nil {
+		return fmt.Errorf("cache flush: %w", err)
+	}
 
 	return nil
 }
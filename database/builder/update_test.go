@@ -56,4 +56,25 @@ func TestUpdate(t *testing.T) {
 		assert.Equal(t, 1, foos[0].ID)
 		assert.Equal(t, "new test1", foos[0].Name)
 	})
+
+	test.Run(t, "soft deleted rows need WithTrashed", func(t *testing.T, tx *sqlx.Tx) {
+		foo := &test.FooSoftDelete{Name: "a"}
+		MustSave(tx, foo)
+		err := builder.From[*test.FooSoftDelete]().Where("id", "=", foo.ID).Delete(tx)
+		assert.NoError(t, err)
+
+		err = builder.From[*test.FooSoftDelete]().Where("id", "=", foo.ID).Update(tx, builder.Updates{"name": "b"})
+		assert.NoError(t, err)
+
+		trashed, err := builder.From[*test.FooSoftDelete]().WithTrashed().Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "a", trashed.Name)
+
+		err = builder.From[*test.FooSoftDelete]().WithTrashed().Where("id", "=", foo.ID).Update(tx, builder.Updates{"name": "b"})
+		assert.NoError(t, err)
+
+		trashed, err = builder.From[*test.FooSoftDelete]().WithTrashed().Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "b", trashed.Name)
+	})
 }
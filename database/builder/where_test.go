@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/dialects/postgres"
 	"github.com/abibby/salusa/internal/test"
 )
 
@@ -119,5 +120,15 @@ func TestWhere(t *testing.T) {
 			ExpectedSQL:      `SELECT "foos".* FROM "foos" WHERE function(a) = ?`,
 			ExpectedBindings: []any{"b"},
 		},
+		{
+			Name: "numbered bindings",
+			Builder: NewTestBuilder().
+				Where("a", "=", "b").
+				WhereExists(builder.From[*test.Bar]().Where("c", "=", "d")).
+				WhereRaw("e = ? AND f = '?'", "g"),
+			ExpectedSQL:      `SELECT "foos".* FROM "foos" WHERE "a" = $1 AND EXISTS (SELECT "bars".* FROM "bars" WHERE "c" = $2) AND e = $3 AND f = '?'`,
+			ExpectedBindings: []any{"b", "d", "g"},
+			Dialect:          &postgres.Posgtgres{},
+		},
 	})
 }
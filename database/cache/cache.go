@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/abibby/salusa/database"
+)
+
+// Store is a cache of query results. Entries are tagged with the tables they
+// were read from so they can be flushed when a table changes.
+type Store interface {
+	// Get returns the value stored at key, the bool is false if there is no
+	// value or it has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value at key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error
+	// Flush removes every entry with any of the tags.
+	Flush(ctx context.Context, tags ...string) error
+}
+
+var (
+	storeMtx     sync.RWMutex
+	defaultStore Store = NewMemoryStore()
+)
+
+// SetStore sets the store used by ModelBuilder.Remember. The default is an in
+// memory store, applications with multiple instances should use a shared
+// store so changes made by one instance are flushed from all of them.
+func SetStore(s Store) {
+	storeMtx.Lock()
+	defer storeMtx.Unlock()
+	defaultStore = s
+}
+
+// Default returns the store set with SetStore.
+func Default() Store {
+	storeMtx.RLock()
+	defer storeMtx.RUnlock()
+	return defaultStore
+}
+
+// TableTag returns the tag of entries read from table.
+func TableTag(table string) string {
+	return "table:" + table
+}
+
+// FlushTable removes every entry read from table from the default store.
+func FlushTable(ctx context.Context, table string) error {
+	return Default().Flush(ctx, TableTag(table))
+}
+
+// FlushTableAfterWrite flushes table after it was written to with tx. The
+// table is flushed immediately and again once tx commits, so results cached
+// from other connections before the commit are removed as well. The write is
+// recorded with database.MarkWritten so tx stops using the cache.
+func FlushTableAfterWrite(ctx context.Context, tx database.DB, table string) error {
+	database.MarkWritten(tx)
+	err := FlushTable(ctx, table)
+	if err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	return database.AfterCommit(tx, func() error {
+		return FlushTable(ctx, table)
+	})
+}
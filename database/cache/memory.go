@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+	tags    []string
+}
+
+// MemoryStore is a Store that keeps entries in memory.
+type MemoryStore struct {
+	mtx     sync.Mutex
+	entries map[string]*memoryEntry
+	tags    map[string]map[string]struct{}
+	sets    int
+}
+
+// sweepInterval is the number of calls to Set between removing expired
+// entries that have not been read.
+const sweepInterval = 1000
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: map[string]*memoryEntry{},
+		tags:    map[string]map[string]struct{}{},
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(e.expires) {
+		s.remove(key)
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.sets++
+	if s.sets%sweepInterval == 0 {
+		s.sweep()
+	}
+
+	s.remove(key)
+	s.entries[key] = &memoryEntry{
+		value:   value,
+		expires: time.Now().Add(ttl),
+		tags:    tags,
+	}
+	for _, tag := range tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = map[string]struct{}{}
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Flush(ctx context.Context, tags ...string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			s.remove(key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) remove(key string) {
+	e, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	delete(s.entries, key)
+	for _, tag := range e.tags {
+		delete(s.tags[tag], key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
+
+func (s *MemoryStore) sweep() {
+	now := time.Now()
+	for key, e := range s.entries {
+		if now.After(e.expires) {
+			s.remove(key)
+		}
+	}
+}
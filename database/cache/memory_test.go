@@ -0,0 +1,59 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/abibby/salusa/database/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("get", func(t *testing.T) {
+		s := cache.NewMemoryStore()
+		assert.NoError(t, s.Set(ctx, "a", []byte("1"), time.Minute, nil))
+
+		v, ok, err := s.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), v)
+
+		_, ok, err = s.Get(ctx, "b")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		s := cache.NewMemoryStore()
+		assert.NoError(t, s.Set(ctx, "a", []byte("1"), time.Millisecond, nil))
+		time.Sleep(2 * time.Millisecond)
+
+		_, ok, err := s.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		s := cache.NewMemoryStore()
+		assert.NoError(t, s.Set(ctx, "a", []byte("1"), time.Minute, []string{"x"}))
+		assert.NoError(t, s.Set(ctx, "b", []byte("2"), time.Minute, []string{"x", "y"}))
+		assert.NoError(t, s.Set(ctx, "c", []byte("3"), time.Minute, []string{"z"}))
+
+		assert.NoError(t, s.Flush(ctx, "y"))
+
+		_, ok, _ := s.Get(ctx, "a")
+		assert.True(t, ok)
+		_, ok, _ = s.Get(ctx, "b")
+		assert.False(t, ok)
+
+		assert.NoError(t, s.Flush(ctx, "x"))
+
+		_, ok, _ = s.Get(ctx, "a")
+		assert.False(t, ok)
+		_, ok, _ = s.Get(ctx, "c")
+		assert.True(t, ok)
+	})
+}
@@ -9,3 +9,33 @@ type Config interface {
 	DataSourceName() string
 	SetDialect()
 }
+
+// DBConnectionsConfiger is implemented by configs that have named connections
+// in addition to the default connection from DBConfig.
+type DBConnectionsConfiger interface {
+	DBConnections() map[string]Config
+}
+
+// Replicated is implemented by configs that have read replicas.
+type Replicated interface {
+	ReadReplicas() []Config
+}
+
+// ReplicaConfig is a primary connection config with read replicas.
+type ReplicaConfig struct {
+	Config
+	Replicas []Config
+}
+
+var _ Replicated = (*ReplicaConfig)(nil)
+
+func WithReplicas(primary Config, replicas ...Config) *ReplicaConfig {
+	return &ReplicaConfig{
+		Config:   primary,
+		Replicas: replicas,
+	}
+}
+
+func (c *ReplicaConfig) ReadReplicas() []Config {
+	return c.Replicas
+}
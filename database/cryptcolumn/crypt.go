@@ -0,0 +1,81 @@
+package cryptcolumn
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// Encrypt encrypts plaintext with AES-GCM using the current key. The result is
+// prefixed with the id of the key so it can be decrypted after the key is
+// rotated.
+func Encrypt(plaintext []byte) (string, error) {
+	k, err := currentKey()
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(k)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(k.id))
+	return k.id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value returned from Encrypt.
+func Decrypt(ciphertext string) ([]byte, error) {
+	id, encoded, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		return nil, ErrInvalidCiphertext
+	}
+	k, err := getKey(id)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(k)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, []byte(k.id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+	return plaintext, nil
+}
+
+// KeyID returns the id of the key the ciphertext was encrypted with.
+func KeyID(ciphertext string) string {
+	id, _, _ := strings.Cut(ciphertext, ":")
+	return id
+}
+
+func newGCM(k *key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
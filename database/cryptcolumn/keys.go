@@ -0,0 +1,88 @@
+package cryptcolumn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	ErrMissingKey = errors.New("no encryption key set")
+	ErrUnknownKey = errors.New("unknown encryption key")
+)
+
+type key struct {
+	id  string
+	key []byte
+}
+
+var (
+	keysMtx  sync.RWMutex
+	current  *key
+	previous []*key
+)
+
+// SetKey sets the key used to encrypt new values. The key is also used to
+// decrypt values encrypted with it. auth.SetAppKey sets this to the app key.
+func SetKey(appKey []byte) {
+	k := deriveKey(appKey)
+
+	keysMtx.Lock()
+	defer keysMtx.Unlock()
+	current = k
+}
+
+// SetPreviousKeys sets keys that are no longer used for encryption but are
+// still needed to decrypt existing values. Values encrypted with a previous key
+// can be re-encrypted with the current key with Encrypted.Rotate.
+func SetPreviousKeys(appKeys ...[]byte) {
+	ks := make([]*key, len(appKeys))
+	for i, appKey := range appKeys {
+		ks[i] = deriveKey(appKey)
+	}
+
+	keysMtx.Lock()
+	defer keysMtx.Unlock()
+	previous = ks
+}
+
+func deriveKey(appKey []byte) *key {
+	b := make([]byte, 32)
+	_, err := io.ReadFull(hkdf.New(sha256.New, appKey, nil, []byte("salusa encrypted column")), b)
+	if err != nil {
+		panic(fmt.Errorf("could not derive encryption key: %w", err))
+	}
+	sum := sha256.Sum256(b)
+	return &key{
+		id:  hex.EncodeToString(sum[:4]),
+		key: b,
+	}
+}
+
+func currentKey() (*key, error) {
+	keysMtx.RLock()
+	defer keysMtx.RUnlock()
+	if current == nil {
+		return nil, ErrMissingKey
+	}
+	return current, nil
+}
+
+func getKey(id string) (*key, error) {
+	keysMtx.RLock()
+	defer keysMtx.RUnlock()
+	if current != nil && current.id == id {
+		return current, nil
+	}
+	for _, k := range previous {
+		if k.id == id {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", id, ErrUnknownKey)
+}
@@ -0,0 +1,190 @@
+package cryptcolumn
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/abibby/salusa/database/dialects"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Encrypted stores a value encrypted in the database. The value is encoded as
+// json and encrypted with the current key when it is saved and decrypted when
+// it is loaded. A zero Encrypted is stored as NULL.
+//
+// Encrypted values are marshaled to json as null so models can be returned
+// from handlers without leaking them, use Revealed to include the value.
+type Encrypted[T any] struct {
+	value T
+	// cache holds the last ciphertext so unchanged values are not
+	// re-encrypted, which would make them look dirty on every save.
+	cache *encryptedCache
+}
+
+type encryptedCache struct {
+	plaintext  []byte
+	ciphertext string
+}
+
+var _ dialects.DataTyper = Encrypted[any]{}
+
+func NewEncrypted[T any](v T) Encrypted[T] {
+	e := Encrypted[T]{}
+	e.Set(v)
+	return e
+}
+
+// Get returns the decrypted value.
+func (e Encrypted[T]) Get() T {
+	return e.value
+}
+
+// Set sets the value to be encrypted on the next save.
+func (e *Encrypted[T]) Set(v T) {
+	e.value = v
+	if e.cache == nil {
+		e.cache = &encryptedCache{}
+	}
+}
+
+// NeedsRotation returns true if the value was encrypted with a previous key.
+func (e Encrypted[T]) NeedsRotation() bool {
+	if e.cache == nil || e.cache.ciphertext == "" {
+		return false
+	}
+	k, err := currentKey()
+	if err != nil {
+		return false
+	}
+	return KeyID(e.cache.ciphertext) != k.id
+}
+
+// Rotate forces the value to be re-encrypted with the current key on the next
+// save.
+func (e *Encrypted[T]) Rotate() {
+	e.cache = &encryptedCache{}
+}
+
+func (e *Encrypted[T]) Scan(src any) error {
+	var ciphertext string
+	switch src := src.(type) {
+	case []byte:
+		ciphertext = string(src)
+	case string:
+		ciphertext = src
+	case nil:
+		var zero T
+		e.value = zero
+		e.cache = nil
+		return nil
+	default:
+		return fmt.Errorf("invalid type %s", reflect.TypeOf(src))
+	}
+
+	plaintext, err := Decrypt(ciphertext)
+	if err != nil {
+		return err
+	}
+	var v T
+	err = json.Unmarshal(plaintext, &v)
+	if err != nil {
+		return err
+	}
+	e.value = v
+	e.cache = &encryptedCache{
+		plaintext:  plaintext,
+		ciphertext: ciphertext,
+	}
+	return nil
+}
+
+func (e Encrypted[T]) Value() (driver.Value, error) {
+	if e.cache == nil {
+		return nil, nil
+	}
+	plaintext, err := json.Marshal(e.value)
+	if err != nil {
+		return nil, err
+	}
+	if e.cache.ciphertext != "" && bytes.Equal(plaintext, e.cache.plaintext) {
+		return e.cache.ciphertext, nil
+	}
+
+	ciphertext, err := Encrypt(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	e.cache.plaintext = plaintext
+	e.cache.ciphertext = ciphertext
+	return ciphertext, nil
+}
+
+func (e Encrypted[T]) DataType() dialects.DataType {
+	return dialects.DataTypeText
+}
+
+func (e Encrypted[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(nil)
+}
+
+func (e *Encrypted[T]) UnmarshalJSON(b []byte) error {
+	var v T
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	e.Set(v)
+	return nil
+}
+
+// Revealed is an Encrypted that includes the decrypted value when it is
+// marshaled to json.
+type Revealed[T any] struct {
+	Encrypted[T]
+}
+
+func NewRevealed[T any](v T) Revealed[T] {
+	return Revealed[T]{Encrypted: NewEncrypted(v)}
+}
+
+// Reveal returns e as a Revealed that shares its value.
+func (e Encrypted[T]) Reveal() Revealed[T] {
+	return Revealed[T]{Encrypted: e}
+}
+
+func (e Revealed[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.value)
+}
+
+// Hashed stores a bcrypt hash of a value. The original value can not be
+// retrieved but can be checked with Check.
+type Hashed string
+
+var _ dialects.DataTyper = Hashed("")
+
+func NewHashed(plaintext string) (Hashed, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return Hashed(hash), nil
+}
+
+// Check returns true if plaintext matches the hashed value.
+func (h Hashed) Check(plaintext string) bool {
+	if h == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(h), []byte(plaintext)) == nil
+}
+
+func (h Hashed) DataType() dialects.DataType {
+	return dialects.DataTypeString
+}
+
+func (h Hashed) MarshalJSON() ([]byte, error) {
+	return json.Marshal(nil)
+}
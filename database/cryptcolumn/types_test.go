@@ -0,0 +1,182 @@
+package cryptcolumn_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/cryptcolumn"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/internal/test"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type SecretFoo struct {
+	model.BaseModel
+	ID     int                                   `db:"id,primary,autoincrement"`
+	Secret cryptcolumn.Encrypted[string]         `db:"secret,nullable"`
+	Data   cryptcolumn.Encrypted[map[string]int] `db:"data,nullable"`
+	Public cryptcolumn.Revealed[string]          `db:"public,nullable"`
+}
+
+func (*SecretFoo) Table() string {
+	return "secret_foos"
+}
+
+func TestEncrypt(t *testing.T) {
+	cryptcolumn.SetKey([]byte("key"))
+	cryptcolumn.SetPreviousKeys()
+
+	ciphertext, err := cryptcolumn.Encrypt([]byte("secret"))
+	assert.NoError(t, err)
+	assert.NotContains(t, ciphertext, "secret")
+
+	plaintext, err := cryptcolumn.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(plaintext))
+
+	_, err = cryptcolumn.Decrypt(ciphertext[:len(ciphertext)-2])
+	assert.ErrorIs(t, err, cryptcolumn.ErrInvalidCiphertext)
+
+	cryptcolumn.SetKey([]byte("new key"))
+	_, err = cryptcolumn.Decrypt(ciphertext)
+	assert.ErrorIs(t, err, cryptcolumn.ErrUnknownKey)
+
+	cryptcolumn.SetPreviousKeys([]byte("key"))
+	plaintext, err = cryptcolumn.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(plaintext))
+}
+
+func TestEncrypted(t *testing.T) {
+	test.Run(t, "round trip", func(t *testing.T, tx *sqlx.Tx) {
+		createSecretFoos(t, tx)
+
+		foo := &SecretFoo{
+			Secret: cryptcolumn.NewEncrypted("secret"),
+			Data:   cryptcolumn.NewEncrypted(map[string]int{"a": 1}),
+		}
+		assert.NoError(t, model.Save(tx, foo))
+
+		var raw string
+		assert.NoError(t, tx.Get(&raw, "select secret from secret_foos"))
+		assert.NotContains(t, raw, "secret")
+
+		loaded, err := builder.From[*SecretFoo]().Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", loaded.Secret.Get())
+		assert.Equal(t, map[string]int{"a": 1}, loaded.Data.Get())
+		assert.False(t, model.IsDirty(loaded))
+		assert.False(t, model.IsDirty(foo))
+
+		loaded.Secret.Set("changed")
+		assert.Equal(t, []string{"secret"}, keys(model.GetDirty(loaded)))
+	})
+
+	test.Run(t, "null", func(t *testing.T, tx *sqlx.Tx) {
+		createSecretFoos(t, tx)
+
+		foo := &SecretFoo{}
+		assert.NoError(t, model.Save(tx, foo))
+
+		var raw *string
+		assert.NoError(t, tx.Get(&raw, "select secret from secret_foos"))
+		assert.Nil(t, raw)
+
+		loaded, err := builder.From[*SecretFoo]().Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "", loaded.Secret.Get())
+	})
+
+	test.Run(t, "rotate", func(t *testing.T, tx *sqlx.Tx) {
+		createSecretFoos(t, tx)
+
+		foo := &SecretFoo{Secret: cryptcolumn.NewEncrypted("secret")}
+		assert.NoError(t, model.Save(tx, foo))
+
+		cryptcolumn.SetKey([]byte("rotated key"))
+		cryptcolumn.SetPreviousKeys([]byte("key"))
+		defer cryptcolumn.SetKey([]byte("key"))
+
+		loaded, err := builder.From[*SecretFoo]().Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.True(t, loaded.Secret.NeedsRotation())
+
+		loaded.Secret.Rotate()
+		assert.NoError(t, model.Save(tx, loaded))
+
+		cryptcolumn.SetPreviousKeys()
+		loaded, err = builder.From[*SecretFoo]().Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.False(t, loaded.Secret.NeedsRotation())
+		assert.Equal(t, "secret", loaded.Secret.Get())
+	})
+}
+
+func TestEncryptedJSON(t *testing.T) {
+	test.Run(t, "redacted", func(t *testing.T, tx *sqlx.Tx) {
+		createSecretFoos(t, tx)
+
+		foo := &SecretFoo{
+			Secret: cryptcolumn.NewEncrypted("secret"),
+			Public: cryptcolumn.NewRevealed("public"),
+		}
+		assert.NoError(t, model.Save(tx, foo))
+
+		var raw string
+		assert.NoError(t, tx.Get(&raw, "select public from secret_foos"))
+		assert.NotContains(t, raw, "public")
+
+		loaded, err := builder.From[*SecretFoo]().Find(tx, foo.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "public", loaded.Public.Get())
+
+		b, err := json.Marshal(loaded)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `"Secret":null`)
+		assert.Contains(t, string(b), `"Public":"public"`)
+	})
+
+	t.Run("reveal", func(t *testing.T) {
+		secret := cryptcolumn.NewEncrypted("secret")
+
+		b, err := json.Marshal(secret)
+		assert.NoError(t, err)
+		assert.Equal(t, "null", string(b))
+
+		b, err = json.Marshal(secret.Reveal())
+		assert.NoError(t, err)
+		assert.Equal(t, `"secret"`, string(b))
+
+		unmarshaled := cryptcolumn.Encrypted[string]{}
+		assert.NoError(t, json.Unmarshal([]byte(`"changed"`), &unmarshaled))
+		assert.Equal(t, "changed", unmarshaled.Get())
+	})
+}
+
+func TestHashed(t *testing.T) {
+	h, err := cryptcolumn.NewHashed("password")
+	assert.NoError(t, err)
+	assert.NotEqual(t, cryptcolumn.Hashed("password"), h)
+	assert.True(t, h.Check("password"))
+	assert.False(t, h.Check("wrong"))
+	assert.False(t, cryptcolumn.Hashed("").Check(""))
+}
+
+func createSecretFoos(t *testing.T, tx *sqlx.Tx) {
+	cryptcolumn.SetKey([]byte("key"))
+	cryptcolumn.SetPreviousKeys()
+	err := migrate.RunModelCreate(context.Background(), tx, &SecretFoo{})
+	assert.NoError(t, err)
+}
+
+func keys(m map[string]any) []string {
+	result := []string{}
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
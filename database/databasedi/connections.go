@@ -0,0 +1,133 @@
+package databasedi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/router"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrUnknownConnection = errors.New("unknown database connection")
+
+// Connections opens database connections by name the first time they are
+// used. The connection with the empty name is the default connection and is
+// the only one migrations are run on.
+//
+// Only the default connection sets the dialect, all named connections must use
+// the same dialect.
+type Connections struct {
+	mtx        sync.Mutex
+	configs    map[string]database.Config
+	routers    map[string]*database.Router
+	migrations *migrate.Migrations
+	log        *slog.Logger
+}
+
+func NewConnections(cfg database.Config, named map[string]database.Config, migrations *migrate.Migrations, log *slog.Logger) *Connections {
+	configs := make(map[string]database.Config, len(named)+1)
+	for name, c := range named {
+		configs[name] = c
+	}
+	configs[""] = cfg
+	if log == nil {
+		log = slog.Default()
+	}
+	return &Connections{
+		configs:    configs,
+		routers:    map[string]*database.Router{},
+		migrations: migrations,
+		log:        log,
+	}
+}
+
+// Router returns the router for the named connection, opening it if needed.
+func (c *Connections) Router(ctx context.Context, name string) (*database.Router, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if r, ok := c.routers[name]; ok {
+		return r, nil
+	}
+
+	cfg, ok := c.configs[name]
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", name, ErrUnknownConnection)
+	}
+
+	if name == "" {
+		cfg.SetDialect()
+	}
+	primary, err := sqlx.Open(cfg.DriverName(), cfg.DataSourceName())
+	if err != nil {
+		return nil, fmt.Errorf("databasedi: open database %q: %w", name, err)
+	}
+
+	replicas := []*sqlx.DB{}
+	if replicated, ok := cfg.(database.Replicated); ok {
+		for i, replicaCfg := range replicated.ReadReplicas() {
+			replica, err := sqlx.Open(replicaCfg.DriverName(), replicaCfg.DataSourceName())
+			if err != nil {
+				return nil, errors.Join(
+					fmt.Errorf("databasedi: open database %q replica %d: %w", name, i, err),
+					database.NewRouter(primary, replicas...).Close(),
+				)
+			}
+			replicas = append(replicas, replica)
+		}
+	}
+	r := database.NewRouter(primary, replicas...).WithName(name)
+
+	if name == "" && c.migrations != nil {
+		err = c.migrations.Up(ctx, primary)
+		if err != nil {
+			return nil, errors.Join(
+				fmt.Errorf("databasedi: migrate database: %w", err),
+				r.Close(),
+			)
+		}
+	}
+	c.log.Info("database ready", "connection", name, "replicas", len(replicas))
+
+	c.routers[name] = r
+	return r, nil
+}
+
+// Close closes all open connections.
+func (c *Connections) Close() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	errs := []error{}
+	for name, r := range c.routers {
+		errs = append(errs, r.Close())
+		delete(c.routers, name)
+	}
+	return errors.Join(errs...)
+}
+
+// QueryTrackingMiddleware counts the queries in each request so a
+// database.QueryLog can detect N+1 queries.
+func QueryTrackingMiddleware() router.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r.WithContext(database.WithQueryTracking(r.Context())))
+		})
+	}
+}
+
+// StickyReadsMiddleware sends reads to the primary database for the rest of a
+// request once the request has written to it.
+func StickyReadsMiddleware() router.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r.WithContext(database.WithStickyReads(r.Context())))
+		})
+	}
+}
@@ -18,37 +18,64 @@ type dbDeps struct {
 	Log *slog.Logger        `inject:""`
 }
 
+// RegisterFromConfig registers the connections from the config. The default
+// connection is resolved with an empty inject tag and named connections from
+// database.DBConnectionsConfiger with their name as the tag, e.g.
+// `inject:"analytics"`. *sqlx.DB resolves to the primary of the connection and
+// database.DB resolves to a database.Router that sends reads to the replicas.
+// If the config implements database.QueryLogConfiger every query is logged.
 func RegisterFromConfig(migrations *migrate.Migrations) func(ctx context.Context) error {
 	return func(ctx context.Context) error {
-		di.RegisterLazySingletonWith(ctx, func(deps *dbDeps) (*sqlx.DB, error) {
+		di.RegisterLazySingletonWith(ctx, func(deps *dbDeps) (*Connections, error) {
 			var cfgAny any = deps.Cfg
 			cfger, ok := cfgAny.(database.DBConfiger)
 			if !ok {
 				return nil, fmt.Errorf("config not instance of dialects.DBConfiger")
 			}
-			dbcfg := cfger.DBConfig()
-
-			dbcfg.SetDialect()
-			db, err := sqlx.Open(dbcfg.DriverName(), dbcfg.DataSourceName())
-			if err != nil {
-				return nil, fmt.Errorf("databasedi.RegisterFromConfig: open database: %w", err)
+			cfg := cfger.DBConfig()
+			var named map[string]database.Config
+			if connectionsCfger, ok := cfgAny.(database.DBConnectionsConfiger); ok {
+				named = connectionsCfger.DBConnections()
 			}
-
-			if migrations != nil {
-				err = migrations.Up(ctx, db)
-				if err != nil {
-					return nil, fmt.Errorf("databasedi.RegisterFromConfig: migrate database: %w", err)
+			if queryLogCfger, ok := cfgAny.(database.QueryLogConfiger); ok {
+				if queryLog := queryLogCfger.QueryLogConfig(); queryLog != nil {
+					cfg = queryLog.Config(cfg)
+					logged := make(map[string]database.Config, len(named))
+					for name, c := range named {
+						logged[name] = queryLog.Config(c)
+					}
+					named = logged
 				}
 			}
-			deps.Log.Info("database ready")
-
-			return db, nil
+			return NewConnections(cfg, named, migrations, deps.Log), nil
+		})
+		di.RegisterWith(ctx, func(ctx context.Context, tag string, c *Connections) (*database.Router, error) {
+			return c.Router(ctx, tag)
+		})
+		di.RegisterWith(ctx, func(ctx context.Context, tag string, c *Connections) (*sqlx.DB, error) {
+			r, err := c.Router(ctx, tag)
+			if err != nil {
+				return nil, err
+			}
+			return r.Primary(), nil
+		})
+		di.RegisterWith(ctx, func(ctx context.Context, tag string, c *Connections) (database.DB, error) {
+			return c.Router(ctx, tag)
+		})
+		di.RegisterWith(ctx, func(ctx context.Context, tag string, c *Connections) (database.Read, error) {
+			r, err := c.Router(ctx, tag)
+			if err != nil {
+				return nil, err
+			}
+			return database.NewRead(ctx, nil, r), nil
+		})
+		di.RegisterWith(ctx, func(ctx context.Context, tag string, c *Connections) (database.Update, error) {
+			r, err := c.Router(ctx, tag)
+			if err != nil {
+				return nil, err
+			}
+			return database.NewUpdate(ctx, nil, r), nil
 		})
-
-		err := RegisterTransactions(nil)(ctx)
-		if err != nil {
-			return err
-		}
 		return nil
 	}
 }
@@ -58,6 +85,9 @@ func Register(db *sqlx.DB) func(ctx context.Context) error {
 		di.RegisterSingleton(ctx, func() *sqlx.DB {
 			return db
 		})
+		di.Register(ctx, func(ctx context.Context, tag string) (database.DB, error) {
+			return db, nil
+		})
 		err := RegisterTransactions(nil)(ctx)
 		if err != nil {
 			return err
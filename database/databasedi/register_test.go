@@ -1,12 +1,15 @@
 package databasedi_test
 
 import (
+	"io"
+	"log/slog"
 	"testing"
 
 	"github.com/abibby/salusa/database"
 	"github.com/abibby/salusa/database/databasedi"
 	"github.com/abibby/salusa/database/dialects/sqlite"
 	"github.com/abibby/salusa/di"
+	"github.com/abibby/salusa/salusaconfig"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 )
@@ -66,3 +69,58 @@ func TestRegister(t *testing.T) {
 		assert.Equal(t, 1, run)
 	})
 }
+
+type connectionsConfig struct{}
+
+func (c *connectionsConfig) GetHTTPPort() int   { return 0 }
+func (c *connectionsConfig) GetBaseURL() string { return "" }
+func (c *connectionsConfig) DBConfig() database.Config {
+	return sqlite.NewConfig(":memory:")
+}
+func (c *connectionsConfig) DBConnections() map[string]database.Config {
+	return map[string]database.Config{
+		"analytics": database.WithReplicas(sqlite.NewConfig(":memory:"), sqlite.NewConfig(":memory:")),
+	}
+}
+
+func TestRegisterFromConfig(t *testing.T) {
+	ctx := di.TestDependencyProviderContext()
+	di.RegisterSingleton(ctx, func() salusaconfig.Config {
+		return &connectionsConfig{}
+	})
+	di.RegisterSingleton(ctx, func() *slog.Logger {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	})
+	err := databasedi.RegisterFromConfig(nil)(ctx)
+	assert.NoError(t, err)
+
+	defer func() {
+		c, err := di.Resolve[*databasedi.Connections](ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, c.Close())
+	}()
+
+	type deps struct {
+		Default   *sqlx.DB         `inject:""`
+		Analytics *sqlx.DB         `inject:"analytics"`
+		Router    *database.Router `inject:"analytics"`
+		DB        database.DB      `inject:"analytics"`
+	}
+	d := &deps{}
+	err = di.Fill(ctx, d)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotSame(t, d.Default, d.Analytics)
+	assert.Same(t, d.Analytics, d.Router.Primary())
+	assert.Same(t, d.Router, d.DB)
+
+	again, err := di.Resolve[*sqlx.DB](ctx)
+	assert.NoError(t, err)
+	assert.Same(t, d.Default, again)
+
+	err = di.Fill(ctx, &struct {
+		DB *sqlx.DB `inject:"missing"`
+	}{})
+	assert.ErrorIs(t, err, databasedi.ErrUnknownConnection)
+}
@@ -1,14 +1,39 @@
 package dbtest
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
 	"github.com/abibby/salusa/database"
 	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/internal/helpers"
+	"github.com/abibby/salusa/internal/relationship"
 )
 
-type Factory[T model.Model] func(tx database.DB) T
+// Factory builds models for tests and seeders. Factories are immutable, every
+// method returns a new factory.
+type Factory[T model.Model] struct {
+	build    func(tx database.DB) T
+	states   []func(T)
+	parents  []model.Model
+	children []child
+}
+
+type child struct {
+	count   int
+	factory childFactory
+}
+
+// childFactory is implemented by every Factory so Has can accept factories for
+// any model.
+type childFactory interface {
+	createFor(ctx context.Context, tx database.DB, parent model.Model, count int) error
+}
 
 func NewFactory[T model.Model](cb func(tx database.DB) T) Factory[T] {
-	return Factory[T](cb)
+	return Factory[T]{build: cb}
 }
 
 type CountFactory[T model.Model] struct {
@@ -16,44 +41,193 @@ type CountFactory[T model.Model] struct {
 	count   int
 }
 
-// func name[T any]() string {
-// 	var m T
-// 	return reflect.TypeOf(m).String()
-// }
-
 func (f Factory[T]) Count(count int) *CountFactory[T] {
 	return &CountFactory[T]{
 		factory: f,
 		count:   count,
 	}
 }
+
+// State modifies every model the factory builds.
 func (f Factory[T]) State(s func(T)) Factory[T] {
-	return func(tx database.DB) T {
-		model := f(tx)
-		s(model)
-		return model
+	f.states = append(cloneSlice(f.states), s)
+	return f
+}
+
+// Sequence modifies every model the factory builds with the number of models
+// built before it, starting at 0.
+func (f Factory[T]) Sequence(s func(m T, i int)) Factory[T] {
+	counter := &atomic.Int64{}
+	return f.State(func(m T) {
+		s(m, int(counter.Add(1)-1))
+	})
+}
+
+// For sets the BelongsTo relationship on the built models that relates to
+// parent.
+func (f Factory[T]) For(parent model.Model) Factory[T] {
+	f.parents = append(cloneSlice(f.parents), parent)
+	return f
+}
+
+// Has creates count models from children for every model created by this
+// factory. The child models must have a BelongsTo relationship to this model.
+func (f Factory[T]) Has(count int, children childFactory) Factory[T] {
+	f.children = append(cloneSlice(f.children), child{
+		count:   count,
+		factory: children,
+	})
+	return f
+}
+
+// Make builds a model without saving it.
+func (f Factory[T]) Make(tx database.DB) T {
+	m, err := f.make(tx)
+	if err != nil {
+		panic(err)
 	}
+	return m
 }
 
 func (f Factory[T]) Create(tx database.DB) T {
-	m := f(tx)
-	model.MustSave(tx, m)
-	// err := relationship.InitializeRelationships(m)
-	// if err != nil {
-	// 	panic(err)
-	// }
+	m, err := f.CreateContext(context.Background(), tx)
+	if err != nil {
+		panic(err)
+	}
 	return m
 }
 
+// CreateContext creates and saves a model, returning any error instead of
+// panicking.
+func (f Factory[T]) CreateContext(ctx context.Context, tx database.DB) (T, error) {
+	var zero T
+	m, err := f.make(tx)
+	if err != nil {
+		return zero, err
+	}
+	err = model.SaveContext(ctx, tx, m)
+	if err != nil {
+		return zero, err
+	}
+	err = f.createChildren(ctx, tx, m)
+	if err != nil {
+		return zero, err
+	}
+	return m, nil
+}
+
+func (f Factory[T]) make(tx database.DB) (T, error) {
+	var zero T
+	m := f.build(tx)
+	err := relationship.InitializeRelationships(m)
+	if err != nil {
+		return zero, err
+	}
+	for _, s := range f.states {
+		s(m)
+	}
+	for _, parent := range f.parents {
+		err = associate(m, parent)
+		if err != nil {
+			return zero, err
+		}
+	}
+	return m, nil
+}
+
+func (f Factory[T]) createChildren(ctx context.Context, tx database.DB, m T) error {
+	for _, c := range f.children {
+		err := c.factory.createFor(ctx, tx, m, c.count)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f Factory[T]) createFor(ctx context.Context, tx database.DB, parent model.Model, count int) error {
+	_, err := f.For(parent).Count(count).CreateContext(ctx, tx)
+	return err
+}
+
+// Make builds count models without saving them.
+func (f *CountFactory[T]) Make(tx database.DB) []T {
+	models, err := f.make(tx)
+	if err != nil {
+		panic(err)
+	}
+	return models
+}
+
 func (f *CountFactory[T]) Create(tx database.DB) []T {
+	models, err := f.CreateContext(context.Background(), tx)
+	if err != nil {
+		panic(err)
+	}
+	return models
+}
+
+// CreateContext creates and saves count models in a single insert, returning
+// any error instead of panicking.
+func (f *CountFactory[T]) CreateContext(ctx context.Context, tx database.DB) ([]T, error) {
+	models, err := f.make(tx)
+	if err != nil {
+		return nil, err
+	}
+	err = model.InsertManyContext(ctx, tx, models)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range models {
+		err = f.factory.createChildren(ctx, tx, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return models, nil
+}
+
+func (f *CountFactory[T]) make(tx database.DB) ([]T, error) {
 	models := make([]T, f.count)
-	for i := 0; i < f.count; i++ {
-		m := f.factory(tx)
-		err := model.Save(tx, m)
+	for i := range models {
+		m, err := f.factory.make(tx)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		models[i] = m
 	}
-	return models
+	return models, nil
+}
+
+// associate calls Associate on the first relationship of m that accepts
+// parent, e.g. a builder.BelongsTo.
+func associate(m, parent any) error {
+	parentType := reflect.TypeOf(parent)
+	found := false
+	err := helpers.EachField(reflect.ValueOf(m), func(sf reflect.StructField, fv reflect.Value) error {
+		if found || !sf.IsExported() {
+			return nil
+		}
+		method := fv.MethodByName("Associate")
+		if !method.IsValid() || method.Type().NumIn() != 1 || method.Type().In(0) != parentType {
+			return nil
+		}
+		found = true
+		out := method.Call([]reflect.Value{reflect.ValueOf(parent)})
+		if err, ok := out[0].Interface().(error); ok && err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%s has no relationship to %s", reflect.TypeOf(m), parentType)
+	}
+	return nil
+}
+
+func cloneSlice[T any](s []T) []T {
+	return append([]T(nil), s...)
 }
@@ -1,6 +1,8 @@
 package dbtest_test
 
 import (
+	"context"
+	"fmt"
 	"testing"
 
 	"github.com/abibby/salusa/database"
@@ -40,4 +42,65 @@ func TestFactory(t *testing.T) {
 			Create(tx)
 		assert.Equal(t, "bar", f.Name)
 	})
+	test.Run(t, "sequence", func(t *testing.T, tx *sqlx.Tx) {
+		foos := fooFactory.
+			Sequence(func(f *test.Foo, i int) {
+				f.Name = fmt.Sprintf("foo %d", i)
+			}).
+			Count(3).
+			Create(tx)
+		if assert.Len(t, foos, 3) {
+			assert.Equal(t, "foo 0", foos[0].Name)
+			assert.Equal(t, "foo 1", foos[1].Name)
+			assert.Equal(t, "foo 2", foos[2].Name)
+		}
+	})
+	test.Run(t, "make", func(t *testing.T, tx *sqlx.Tx) {
+		f := fooFactory.Make(tx)
+		assert.False(t, f.InDatabase())
+
+		foos := fooFactory.Count(2).Make(tx)
+		assert.Len(t, foos, 2)
+
+		count, err := builder.From[*test.Foo]().Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+	test.Run(t, "bulk create", func(t *testing.T, tx *sqlx.Tx) {
+		foos := fooFactory.Count(3).Create(tx)
+		for _, f := range foos {
+			assert.True(t, f.InDatabase())
+
+			dbF, err := builder.From[*test.Foo]().Find(tx, f.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, f.ID, dbF.ID)
+		}
+	})
+	test.Run(t, "for", func(t *testing.T, tx *sqlx.Tx) {
+		foo := fooFactory.Create(tx)
+		bar := barFactory.For(foo).Create(tx)
+		assert.Equal(t, foo.ID, bar.FooID)
+
+		related, ok := bar.Foo.Value()
+		assert.True(t, ok)
+		assert.Same(t, foo, related)
+	})
+	test.Run(t, "has", func(t *testing.T, tx *sqlx.Tx) {
+		foos := fooFactory.Has(2, barFactory).Count(2).Create(tx)
+
+		for _, foo := range foos {
+			bars, err := foo.Bars.Query().Get(tx)
+			assert.NoError(t, err)
+			assert.Len(t, bars, 2)
+		}
+	})
+	test.Run(t, "for without relationship", func(t *testing.T, tx *sqlx.Tx) {
+		bar := barFactory.Create(tx)
+		_, err := fooFactory.For(bar).CreateContext(context.Background(), tx)
+		assert.Error(t, err)
+	})
 }
+
+var barFactory = dbtest.NewFactory(func(tx database.DB) *test.Bar {
+	return &test.Bar{}
+})
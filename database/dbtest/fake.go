@@ -0,0 +1,100 @@
+package dbtest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	firstNames = []string{"Ada", "Alan", "Barbara", "Charles", "Dennis", "Edsger", "Frances", "Grace", "Hedy", "Ken", "Linus", "Margaret", "Niklaus", "Radia", "Rob", "Tim"}
+	lastNames  = []string{"Allen", "Babbage", "Dijkstra", "Hamilton", "Hopper", "Kernighan", "Lamarr", "Liskov", "Lovelace", "Perlman", "Pike", "Ritchie", "Thompson", "Torvalds", "Turing", "Wirth"}
+	words      = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliett", "kilo", "lima", "mike", "november", "oscar", "papa"}
+)
+
+// Faker generates fake data for factories. Values that are commonly unique,
+// like emails, include a counter so they don't collide.
+type Faker struct {
+	mtx     sync.Mutex
+	rand    *rand.Rand
+	counter int
+}
+
+// Fake is a Faker with a random seed.
+var Fake = NewFaker(rand.Uint64())
+
+// NewFaker creates a Faker that generates the same values for the same seed.
+func NewFaker(seed uint64) *Faker {
+	return &Faker{
+		rand: rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+func (f *Faker) FirstName() string {
+	return pick(f, firstNames)
+}
+
+func (f *Faker) LastName() string {
+	return pick(f, lastNames)
+}
+
+func (f *Faker) Name() string {
+	return f.FirstName() + " " + f.LastName()
+}
+
+// Email returns a unique email address.
+func (f *Faker) Email() string {
+	f.mtx.Lock()
+	f.counter++
+	n := f.counter
+	f.mtx.Unlock()
+	return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(f.FirstName()), strings.ToLower(f.LastName()), n)
+}
+
+func (f *Faker) Word() string {
+	return pick(f, words)
+}
+
+func (f *Faker) Sentence(wordCount int) string {
+	s := make([]string, wordCount)
+	for i := range s {
+		s[i] = f.Word()
+	}
+	sentence := strings.Join(s, " ")
+	if sentence == "" {
+		return ""
+	}
+	return strings.ToUpper(sentence[:1]) + sentence[1:] + "."
+}
+
+// Int returns a number in [min, max].
+func (f *Faker) Int(min, max int) int {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return min + f.rand.IntN(max-min+1)
+}
+
+func (f *Faker) Bool() bool {
+	return f.Int(0, 1) == 1
+}
+
+// Time returns a time in [from, to).
+func (f *Faker) Time(from, to time.Time) time.Time {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return from.Add(time.Duration(f.rand.Int64N(int64(to.Sub(from)))))
+}
+
+// Date returns a time at midnight UTC within the last year.
+func (f *Faker) Date() time.Time {
+	now := time.Now().UTC()
+	return f.Time(now.AddDate(-1, 0, 0), now).Truncate(24 * time.Hour)
+}
+
+func pick(f *Faker, values []string) string {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return values[f.rand.IntN(len(values))]
+}
@@ -0,0 +1,32 @@
+package dbtest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaker(t *testing.T) {
+	a := dbtest.NewFaker(1)
+	b := dbtest.NewFaker(1)
+	assert.Equal(t, a.Name(), b.Name())
+
+	f := dbtest.NewFaker(1)
+	assert.NotEqual(t, f.Email(), f.Email())
+	assert.Regexp(t, `^[a-z]+\.[a-z]+\d+@example\.com$`, f.Email())
+	assert.Regexp(t, `^[A-Z][a-z]+( [a-z]+){2}\.$`, f.Sentence(3))
+
+	for range 100 {
+		n := f.Int(1, 3)
+		assert.GreaterOrEqual(t, n, 1)
+		assert.LessOrEqual(t, n, 3)
+	}
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	tm := f.Time(from, to)
+	assert.False(t, tm.Before(from))
+	assert.True(t, tm.Before(to))
+}
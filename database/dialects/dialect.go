@@ -74,6 +74,27 @@ type Dialect interface {
 	AutoIncrement() string
 	Escape(v any) string
 	Binding() string
+
+	// DropForeignKey returns the ALTER TABLE clause used to remove a foreign
+	// key constraint.
+	DropForeignKey() string
+	// DropCheck returns the ALTER TABLE clause used to remove a check
+	// constraint.
+	DropCheck() string
+	// AlterConstraints reports whether constraints can be added to or removed
+	// from an existing table. Dialects that return false have their tables
+	// rebuilt instead.
+	AlterConstraints() bool
+	// AlterColumns reports whether the definition of an existing column can be
+	// changed. Dialects that return false have their tables rebuilt instead.
+	AlterColumns() bool
+	// InsertReturning reports whether generated ids should be read from a
+	// RETURNING clause instead of the drivers LastInsertId.
+	InsertReturning() bool
+	// ContiguousInsertIDs reports whether the rows of a multi row insert
+	// always get consecutive ids, so every id can be worked out from the
+	// drivers LastInsertId of the last row.
+	ContiguousInsertIDs() bool
 }
 
 type unsetDialect struct{}
@@ -102,6 +123,30 @@ func (*unsetDialect) Binding() string {
 	return "?"
 }
 
+func (*unsetDialect) DropForeignKey() string {
+	return "DROP CONSTRAINT"
+}
+
+func (*unsetDialect) DropCheck() string {
+	return "DROP CONSTRAINT"
+}
+
+func (*unsetDialect) AlterConstraints() bool {
+	return true
+}
+
+func (*unsetDialect) AlterColumns() bool {
+	return true
+}
+
+func (*unsetDialect) InsertReturning() bool {
+	return false
+}
+
+func (*unsetDialect) ContiguousInsertIDs() bool {
+	return false
+}
+
 func SetDefaultDialect(dialectFactory func() Dialect) {
 	defaultDialect = dialectFactory
 }
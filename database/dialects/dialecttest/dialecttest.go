@@ -0,0 +1,195 @@
+// Package dialecttest contains the conformance tests every dialect must pass.
+package dialecttest
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/dialects"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type Parent struct {
+	model.BaseModel
+	ID       int                      `db:"id,primary,autoincrement"`
+	Name     string                   `db:"name"`
+	Active   bool                     `db:"active"`
+	Children *builder.HasMany[*Child] `db:"-" foreign:"parent_id"`
+}
+
+func (*Parent) Table() string {
+	return "dialecttest_parents"
+}
+
+type Child struct {
+	model.BaseModel
+	ID       int                         `db:"id,primary,autoincrement"`
+	ParentID int                         `db:"parent_id"`
+	Name     string                      `db:"name"`
+	Parent   *builder.BelongsTo[*Parent] `db:"-" foreign:"parent_id"`
+}
+
+func (*Child) Table() string {
+	return "dialecttest_children"
+}
+
+var (
+	placeholderRegexp = regexp.MustCompile(`\?|\$(\d+)`)
+	stringRegexp      = regexp.MustCompile(`'[^']*'`)
+)
+
+// query has bindings in the select, nested subqueries, raw sql and the limit so
+// the order they are rendered in can be checked.
+func query() *builder.ModelBuilder[*Child] {
+	return builder.From[*Child]().
+		Where("name", "!=", "unused").
+		WhereExists(
+			builder.From[*Parent]().
+				WhereColumn("dialecttest_parents.id", "=", "dialecttest_children.parent_id").
+				Where("active", "=", true).
+				WhereIn("name", []any{"parent 1", "parent 2"}),
+		).
+		WhereRaw("dialecttest_children.name != ? AND dialecttest_children.name != '?'", "also unused").
+		OrderBy("id").
+		Limit(10)
+}
+
+// Render checks that queries rendered with d have one placeholder for each
+// binding and that numbered placeholders are in order.
+func Render(t *testing.T, d dialects.Dialect) {
+	t.Run("bindings", func(t *testing.T) {
+		sql, bindings, err := query().SQLString(d)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		placeholders := placeholderRegexp.FindAllStringSubmatch(stringRegexp.ReplaceAllString(sql, "''"), -1)
+		assert.Len(t, placeholders, len(bindings), sql)
+		for i, p := range placeholders {
+			if p[1] == "" {
+				continue
+			}
+			assert.Equal(t, strconv.Itoa(i+1), p[1], sql)
+		}
+	})
+}
+
+// Run runs the conformance tests against the database described by cfg. The
+// dialect from cfg is set as the default dialect.
+func Run(t *testing.T, cfg database.Config) {
+	cfg.SetDialect()
+	Render(t, dialects.New())
+
+	ctx := context.Background()
+	db, err := sqlx.Open(cfg.DriverName(), cfg.DataSourceName())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	// in memory sqlite databases are per connection
+	db.SetMaxOpenConns(1)
+
+	drop := func() {
+		assert.NoError(t, schema.DropIfExists("dialecttest_children").Run(ctx, db))
+		assert.NoError(t, schema.DropIfExists("dialecttest_parents").Run(ctx, db))
+	}
+	drop()
+	defer drop()
+
+	err = schema.Create("dialecttest_parents", func(table *schema.Blueprint) {
+		table.Int("id").Primary().AutoIncrement()
+		table.String("name")
+		table.Bool("active").Default(false)
+		table.Check("dialecttest_parents_name", "name != ''")
+	}).Run(ctx, db)
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = schema.Create("dialecttest_children", func(table *schema.Blueprint) {
+		table.Int("id").Primary().AutoIncrement()
+		table.Int("parent_id")
+		table.String("name")
+		table.ForeignKey("parent_id", "dialecttest_parents", "id").CascadeOnDelete()
+	}).Run(ctx, db)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("insert", func(t *testing.T) {
+		for i := 1; i <= 2; i++ {
+			p := &Parent{Name: "parent " + strconv.Itoa(i), Active: i == 1}
+			err := model.SaveContext(ctx, db, p)
+			assert.NoError(t, err)
+			assert.Equal(t, i, p.ID)
+
+			c := &Child{ParentID: p.ID, Name: "child " + strconv.Itoa(i)}
+			err = model.SaveContext(ctx, db, c)
+			assert.NoError(t, err)
+			assert.Equal(t, i, c.ID)
+		}
+	})
+
+	t.Run("select", func(t *testing.T) {
+		children, err := query().Get(db)
+		if !assert.NoError(t, err) {
+			return
+		}
+		if assert.Len(t, children, 1) {
+			assert.Equal(t, "child 1", children[0].Name)
+		}
+
+		count, err := query().Count(db)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("with", func(t *testing.T) {
+		parents, err := builder.From[*Parent]().With("Children").OrderBy("id").Get(db)
+		if !assert.NoError(t, err) || !assert.Len(t, parents, 2) {
+			return
+		}
+		children, ok := parents[0].Children.Value()
+		assert.True(t, ok)
+		assert.Len(t, children, 1)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		p, err := builder.From[*Parent]().Find(db, 2)
+		if !assert.NoError(t, err) {
+			return
+		}
+		p.Active = true
+		err = model.SaveContext(ctx, db, p)
+		assert.NoError(t, err)
+
+		err = builder.From[*Child]().
+			Where("parent_id", "=", p.ID).
+			Update(db, builder.Updates{"name": "child 3"})
+		assert.NoError(t, err)
+
+		count, err := query().Count(db)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("check constraint", func(t *testing.T) {
+		err := model.SaveContext(ctx, db, &Parent{Name: ""})
+		assert.Error(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		err := builder.From[*Child]().Where("id", "=", 1).Delete(db)
+		assert.NoError(t, err)
+
+		count, err := builder.From[*Child]().Count(db)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
@@ -88,6 +88,33 @@ func (s *MySQL) Escape(v any) string {
 func (*MySQL) Binding() string {
 	return "?"
 }
+
+func (*MySQL) DropForeignKey() string {
+	return "DROP FOREIGN KEY"
+}
+
+func (*MySQL) DropCheck() string {
+	return "DROP CHECK"
+}
+
+func (*MySQL) AlterConstraints() bool {
+	return true
+}
+
+func (*MySQL) AlterColumns() bool {
+	return true
+}
+
+func (*MySQL) InsertReturning() bool {
+	return false
+}
+
+// ContiguousInsertIDs is false because ids can be interleaved with other
+// inserts in innodb_autoinc_lock_mode 2 and step by auto_increment_increment.
+func (*MySQL) ContiguousInsertIDs() bool {
+	return false
+}
+
 func UseMySql() {
 	dialects.SetDefaultDialect(func() dialects.Dialect {
 		return &MySQL{}
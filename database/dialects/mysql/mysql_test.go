@@ -0,0 +1,25 @@
+package mysql_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/abibby/salusa/database/dialects/dialecttest"
+	"github.com/abibby/salusa/database/dialects/mysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// TestConformance runs against the database in SALUSA_TEST_MYSQL_DSN, without
+// it only the rendering tests are run.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("SALUSA_TEST_MYSQL_DSN")
+	if dsn == "" {
+		dialecttest.Render(t, &mysql.MySQL{})
+		return
+	}
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialecttest.Run(t, mysql.NewMySQLConfig(cfg))
+}
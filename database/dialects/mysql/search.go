@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"strings"
+
+	"github.com/abibby/salusa/database/dialects"
+)
+
+var _ dialects.Searcher = (*MySQL)(nil)
+
+func (d *MySQL) SearchCondition(table, key string, columns []string, term string) (string, []any) {
+	cols := make([]string, len(columns))
+	for i, c := range columns {
+		cols[i] = d.Identifier(table + "." + c)
+	}
+	return "MATCH (" + strings.Join(cols, ", ") + ") AGAINST (? IN NATURAL LANGUAGE MODE)", []any{term}
+}
+
+func (d *MySQL) CreateSearchIndex(table, key string, columns []string) []string {
+	cols := make([]string, len(columns))
+	for i, c := range columns {
+		cols[i] = d.Identifier(c)
+	}
+	return []string{
+		"CREATE FULLTEXT INDEX " + d.Identifier(dialects.SearchTable(table)) + " ON " + d.Identifier(table) + " (" + strings.Join(cols, ", ") + ")",
+	}
+}
+
+func (d *MySQL) DropSearchIndex(table string) []string {
+	return []string{
+		"DROP INDEX " + d.Identifier(dialects.SearchTable(table)) + " ON " + d.Identifier(table),
+	}
+}
@@ -54,11 +54,11 @@ func (*Posgtgres) DataType(t dialects.DataType) string {
 }
 
 func (*Posgtgres) CurrentTime() string {
-	return "CURRENT_TIMESTAMP()"
+	return "CURRENT_TIMESTAMP"
 }
 
 func (*Posgtgres) AutoIncrement() string {
-	return ""
+	return "GENERATED BY DEFAULT AS IDENTITY"
 }
 
 func (s *Posgtgres) Escape(v any) string {
@@ -89,6 +89,30 @@ func (p *Posgtgres) Binding() string {
 	return fmt.Sprintf("$%d", p.bindingNumber)
 }
 
+func (*Posgtgres) DropForeignKey() string {
+	return "DROP CONSTRAINT"
+}
+
+func (*Posgtgres) DropCheck() string {
+	return "DROP CONSTRAINT"
+}
+
+func (*Posgtgres) AlterConstraints() bool {
+	return true
+}
+
+func (*Posgtgres) AlterColumns() bool {
+	return true
+}
+
+func (*Posgtgres) InsertReturning() bool {
+	return true
+}
+
+func (*Posgtgres) ContiguousInsertIDs() bool {
+	return false
+}
+
 func UsePostgres() {
 	dialects.SetDefaultDialect(func() dialects.Dialect {
 		return &Posgtgres{}
@@ -0,0 +1,20 @@
+package postgres_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/abibby/salusa/database/dialects/dialecttest"
+	"github.com/abibby/salusa/database/dialects/postgres"
+)
+
+// TestConformance runs against the database in SALUSA_TEST_POSTGRES_DSN,
+// without it only the rendering tests are run.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("SALUSA_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		dialecttest.Render(t, &postgres.Posgtgres{})
+		return
+	}
+	dialecttest.Run(t, postgres.NewPostgresConfig(dsn))
+}
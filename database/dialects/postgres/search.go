@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"strings"
+
+	"github.com/abibby/salusa/database/dialects"
+)
+
+var _ dialects.Searcher = (*Posgtgres)(nil)
+
+// SearchConfig is the text search configuration used to build and query the
+// full text index.
+var SearchConfig = "english"
+
+func (d *Posgtgres) searchVector(table string, columns []string) string {
+	cols := make([]string, len(columns))
+	for i, c := range columns {
+		if table != "" {
+			c = table + "." + c
+		}
+		cols[i] = "coalesce(" + d.Identifier(c) + "::text, '')"
+	}
+	return "to_tsvector('" + SearchConfig + "', " + strings.Join(cols, " || ' ' || ") + ")"
+}
+
+func (d *Posgtgres) SearchCondition(table, key string, columns []string, term string) (string, []any) {
+	return d.searchVector(table, columns) + " @@ plainto_tsquery('" + SearchConfig + "', ?)", []any{term}
+}
+
+func (d *Posgtgres) CreateSearchIndex(table, key string, columns []string) []string {
+	return []string{
+		"CREATE INDEX IF NOT EXISTS " + d.Identifier(dialects.SearchTable(table)) + " ON " + d.Identifier(table) + " USING GIN (" + d.searchVector("", columns) + ")",
+	}
+}
+
+func (d *Posgtgres) DropSearchIndex(table string) []string {
+	return []string{
+		"DROP INDEX IF EXISTS " + d.Identifier(dialects.SearchTable(table)),
+	}
+}
@@ -0,0 +1,35 @@
+package dialects
+
+import "errors"
+
+var ErrSearchNotSupported = errors.New("full text search is not supported by the dialect")
+
+// Searcher is implemented by dialects that support full text search. The sql
+// returned uses ? for bindings.
+type Searcher interface {
+	// SearchCondition returns a condition that matches the rows of table where
+	// columns match term. key is the tables primary key.
+	SearchCondition(table, key string, columns []string, term string) (string, []any)
+	// CreateSearchIndex returns the statements that create the full text index
+	// over columns.
+	CreateSearchIndex(table, key string, columns []string) []string
+	// DropSearchIndex returns the statements that remove the full text index.
+	DropSearchIndex(table string) []string
+}
+
+// SearchSyncer is implemented by dialects that store the full text index in a
+// separate table that must be updated when rows change.
+type SearchSyncer interface {
+	// InsertSearch returns a statement that adds a row to the index. The
+	// bindings are the key followed by the columns. An existing row for the
+	// key must be removed with DeleteSearch first.
+	InsertSearch(table string, columns []string) string
+	// DeleteSearch returns the start of a statement that removes rows from
+	// the index, it must be followed by a subquery selecting their keys.
+	DeleteSearch(table string) string
+}
+
+// SearchTable returns the name of the index for table.
+func SearchTable(table string) string {
+	return table + "_search"
+}
@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/abibby/salusa/database/dialects"
+)
+
+var _ dialects.Searcher = (*SQLite)(nil)
+var _ dialects.SearchSyncer = (*SQLite)(nil)
+
+// searchKey is the UNINDEXED column of the FTS5 table that holds the key of
+// the row, the rowid can't be used because keys don't have to be integers.
+const searchKey = "search_key"
+
+// SearchCondition matches against an FTS5 virtual table. Each word in term is
+// quoted so it is matched literally instead of as FTS5 query syntax. A term
+// without any words matches nothing.
+func (d *SQLite) SearchCondition(table, key string, columns []string, term string) (string, []any) {
+	words := strings.Fields(term)
+	if len(words) == 0 {
+		return "0 = 1", nil
+	}
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	searchTable := d.Identifier(dialects.SearchTable(table))
+	return d.Identifier(table+"."+key) + " IN (SELECT " + d.Identifier(searchKey) + " FROM " + searchTable + " WHERE " + searchTable + " MATCH ?)", []any{strings.Join(words, " ")}
+}
+
+func (d *SQLite) CreateSearchIndex(table, key string, columns []string) []string {
+	searchTable := d.Identifier(dialects.SearchTable(table))
+	cols := d.identifiers(columns)
+	return []string{
+		"CREATE VIRTUAL TABLE IF NOT EXISTS " + searchTable + " USING fts5(" + d.Identifier(searchKey) + " UNINDEXED, " + cols + ")",
+		"INSERT INTO " + searchTable + " (" + d.Identifier(searchKey) + ", " + cols + ") SELECT " + d.Identifier(key) + ", " + cols + " FROM " + d.Identifier(table),
+	}
+}
+
+func (d *SQLite) DropSearchIndex(table string) []string {
+	return []string{
+		"DROP TABLE IF EXISTS " + d.Identifier(dialects.SearchTable(table)),
+	}
+}
+
+func (d *SQLite) InsertSearch(table string, columns []string) string {
+	return "INSERT INTO " + d.Identifier(dialects.SearchTable(table)) +
+		" (" + d.Identifier(searchKey) + ", " + d.identifiers(columns) + ") VALUES (?" + strings.Repeat(", ?", len(columns)) + ")"
+}
+
+func (d *SQLite) DeleteSearch(table string) string {
+	return "DELETE FROM " + d.Identifier(dialects.SearchTable(table)) + " WHERE " + d.Identifier(searchKey) + " IN "
+}
+
+func (d *SQLite) identifiers(columns []string) string {
+	ids := make([]string, len(columns))
+	for i, c := range columns {
+		ids[i] = d.Identifier(c)
+	}
+	return strings.Join(ids, ", ")
+}
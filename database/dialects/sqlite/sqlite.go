@@ -77,6 +77,32 @@ func (*SQLite) Binding() string {
 	return "?"
 }
 
+func (*SQLite) DropForeignKey() string {
+	return "DROP CONSTRAINT"
+}
+
+func (*SQLite) DropCheck() string {
+	return "DROP CONSTRAINT"
+}
+
+func (*SQLite) AlterConstraints() bool {
+	return false
+}
+
+func (*SQLite) AlterColumns() bool {
+	return false
+}
+
+func (*SQLite) InsertReturning() bool {
+	return false
+}
+
+// ContiguousInsertIDs is true because SQLite holds the write lock for the
+// whole insert and gives each row the next rowid.
+func (*SQLite) ContiguousInsertIDs() bool {
+	return true
+}
+
 func UseSQLite() {
 	dialects.SetDefaultDialect(func() dialects.Dialect {
 		return &SQLite{}
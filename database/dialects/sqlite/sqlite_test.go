@@ -0,0 +1,12 @@
+package sqlite_test
+
+import (
+	"testing"
+
+	"github.com/abibby/salusa/database/dialects/dialecttest"
+	"github.com/abibby/salusa/database/dialects/sqlite"
+)
+
+func TestConformance(t *testing.T) {
+	dialecttest.Run(t, sqlite.NewConfig(":memory:"))
+}
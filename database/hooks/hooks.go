@@ -14,6 +14,12 @@ type AfterSaver interface {
 	AfterSave(ctx context.Context, tx database.DB) error
 }
 
+// ModelAfterSaver is implemented by structs embedded in models whose hooks need
+// the model they are embedded in.
+type ModelAfterSaver interface {
+	AfterSaveModel(ctx context.Context, tx database.DB, model any) error
+}
+
 type AfterLoader interface {
 	AfterLoad(ctx context.Context, tx database.DB) error
 }
@@ -31,14 +37,31 @@ func BeforeSave(ctx context.Context, tx database.DB, model interface{}) error {
 }
 
 func AfterSave(ctx context.Context, tx database.DB, model interface{}) error {
+	if reflect.Indirect(reflect.ValueOf(model)).Kind() == reflect.Slice {
+		return eachField(reflect.ValueOf(model), func(i interface{}) error {
+			return AfterSave(ctx, tx, i)
+		})
+	}
+	return afterSave(ctx, tx, model, model)
+}
+
+func afterSave(ctx context.Context, tx database.DB, root, model interface{}) error {
 	if model, ok := model.(AfterSaver); ok {
 		err := model.AfterSave(ctx, tx)
 		if err != nil {
 			return err
 		}
 	}
+	// methods on embedded structs are promoted to the root so only call them
+	// on the embedded struct
+	if model, ok := model.(ModelAfterSaver); ok && model != root {
+		err := model.AfterSaveModel(ctx, tx, root)
+		if err != nil {
+			return err
+		}
+	}
 	return eachField(reflect.ValueOf(model), func(i interface{}) error {
-		return AfterSave(ctx, tx, i)
+		return afterSave(ctx, tx, root, i)
 	})
 }
 
@@ -2,6 +2,7 @@ package migrate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -43,7 +44,11 @@ func (m *Migrations) Add(migration *Migration) {
 }
 
 func (m *Migrations) isTableCreated(table string) bool {
-	for _, m := range m.migrations {
+	return isTableCreated(m.migrations, table)
+}
+
+func isTableCreated(migrations []*Migration, table string) bool {
+	for _, m := range migrations {
 		blueprinter, ok := m.Up.(schema.Blueprinter)
 		if !ok {
 			continue
@@ -75,9 +80,13 @@ func (m *Migrations) GenerateMigration(migrationName, packageName string, model
 }
 
 func (m *Migrations) Blueprint(tableName string) *schema.Blueprint {
+	return blueprint(m.migrations, tableName)
+}
+
+func blueprint(migrations []*Migration, tableName string) *schema.Blueprint {
 	result := &schema.Blueprint{}
 
-	for _, migration := range m.migrations {
+	for _, migration := range migrations {
 		blueprinter, ok := migration.Up.(schema.Blueprinter)
 		if !ok {
 			continue
@@ -88,7 +97,7 @@ func (m *Migrations) Blueprint(tableName string) *schema.Blueprint {
 		}
 
 		if blueprinter.Type() == schema.BlueprintTypeCreate {
-			result = blueprint
+			result = blueprint.Clone()
 		} else {
 			result.Merge(blueprint)
 		}
@@ -96,7 +105,34 @@ func (m *Migrations) Blueprint(tableName string) *schema.Blueprint {
 	return result
 }
 
-func (m *Migrations) Up(ctx context.Context, db database.DB) error {
+// Up runs the migrations that haven't been run. On SQLite the migrations run
+// on a single connection with foreign keys disabled so tables can be rebuilt,
+// the foreign keys are checked after each migration instead.
+func (m *Migrations) Up(ctx context.Context, db database.DB) (err error) {
+	checkForeignKeys := false
+	if sqlDB, ok := db.(*sqlx.DB); ok && !dialects.New().AlterColumns() {
+		var conn *sqlx.Conn
+		conn, err = sqlDB.Connx(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		checkForeignKeys, err = schema.ForeignKeysEnabled(ctx, conn)
+		if err != nil {
+			return err
+		}
+		var restore func() error
+		restore, err = schema.DisableForeignKeys(ctx, conn)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, restore())
+		}()
+		db = conn
+	}
+
 	sql, bindings, err := schema.Create(m.table, func(b *schema.Blueprint) {
 		b.String("name")
 		b.Bool("run")
@@ -130,12 +166,21 @@ func (m *Migrations) Up(ctx context.Context, db database.DB) error {
 	if err != nil {
 		logger = slog.Default()
 	}
-	for _, migration := range m.migrations {
+	for i, migration := range m.migrations {
 		err = update(func(tx *sqlx.Tx) error {
 			if runMigrations.Has(migration.Name) {
 				return nil
 			}
 
+			// Without a create migration the table is read from the database
+			// if it needs to be rebuilt.
+			if up, ok := migration.Up.(*schema.UpdateTableBuilder); ok {
+				table := up.GetBlueprint().TableName()
+				if isTableCreated(m.migrations[:i], table) {
+					up.Current(blueprint(m.migrations[:i], table))
+				}
+			}
+
 			logger.Info("starting migration", "name", migration.Name)
 
 			m := &DBMigration{
@@ -151,6 +196,12 @@ func (m *Migrations) Up(ctx context.Context, db database.DB) error {
 			if err != nil {
 				return fmt.Errorf("failed to prepare migration %s: %w", migration.Name, err)
 			}
+			if checkForeignKeys {
+				err = schema.CheckForeignKeys(ctx, tx)
+				if err != nil {
+					return fmt.Errorf("migration %s: %w", migration.Name, err)
+				}
+			}
 
 			m.Run = true
 			err = model.SaveContext(ctx, tx, m)
@@ -3,9 +3,11 @@ package migrate_test
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/dialects/sqlite"
 	"github.com/abibby/salusa/database/migrate"
 	"github.com/abibby/salusa/database/schema"
 	"github.com/abibby/salusa/internal/test"
@@ -61,4 +63,73 @@ func TestMigrations(t *testing.T) {
 		err = m.Up(context.Background(), tx)
 		assert.NoError(t, err)
 	})
+	test.RunNoTx(t, "rebuild table without create migration", func(t *testing.T, tx *sqlx.DB) {
+		m := migrate.New()
+		m.Add(&migrate.Migration{
+			Name: "1",
+			Up: schema.Run(func(ctx context.Context, tx database.DB) error {
+				_, err := tx.ExecContext(ctx, `CREATE TABLE "foo" ("id" INTEGER NOT NULL, "name" TEXT NOT NULL); INSERT INTO "foo" VALUES (1, 'a')`)
+				return err
+			}),
+		})
+		m.Add(&migrate.Migration{
+			Name: "2",
+			Up: schema.Table("foo", func(b *schema.Blueprint) {
+				b.String("name").Nullable().Change()
+			}),
+		})
+
+		err := m.Up(context.Background(), tx)
+		assert.NoError(t, err)
+
+		names := []string{}
+		err = tx.Select(&names, `SELECT name FROM foo`)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a"}, names)
+	})
+}
+
+func TestMigrationsRebuildWithForeignKeys(t *testing.T) {
+	sqlite.UseSQLite()
+	db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite")+"?_foreign_keys=on")
+	assert.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	m := migrate.New()
+	m.Add(&migrate.Migration{
+		Name: "1",
+		Up: schema.Create("parents", func(b *schema.Blueprint) {
+			b.Int("id").Primary()
+			b.String("name")
+		}),
+	})
+	m.Add(&migrate.Migration{
+		Name: "2",
+		Up: schema.Create("children", func(b *schema.Blueprint) {
+			b.Int("id").Primary()
+			b.Int("parent_id")
+			b.ForeignKey("parent_id", "parents", "id").CascadeOnDelete()
+		}),
+	})
+	assert.NoError(t, m.Up(ctx, db))
+
+	_, err = db.Exec("INSERT INTO parents (id, name) VALUES (1, 'a'); INSERT INTO children (id, parent_id) VALUES (1, 1)")
+	assert.NoError(t, err)
+
+	m.Add(&migrate.Migration{
+		Name: "3",
+		Up: schema.Table("parents", func(b *schema.Blueprint) {
+			b.String("name").Nullable().Change()
+		}),
+	})
+	assert.NoError(t, m.Up(ctx, db))
+
+	count := 0
+	assert.NoError(t, db.Get(&count, "SELECT count(*) FROM children"))
+	assert.Equal(t, 1, count)
+
+	enabled, err := schema.ForeignKeysEnabled(ctx, db)
+	assert.NoError(t, err)
+	assert.True(t, enabled)
 }
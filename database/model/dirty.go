@@ -0,0 +1,158 @@
+package model
+
+import (
+	"database/sql/driver"
+	"reflect"
+)
+
+type originalHolder interface {
+	baseModel() *BaseModel
+}
+
+func (m *BaseModel) baseModel() *BaseModel {
+	return m
+}
+
+// SyncOriginal sets the original values of v, or every model in v if it is a
+// slice, to their current values. It is called after models are loaded and
+// saved.
+func SyncOriginal(v any) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Kind() != reflect.Struct {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Slice {
+		for i := 0; i < rv.Len(); i++ {
+			SyncOriginal(rv.Index(i).Interface())
+		}
+		return
+	}
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return
+	}
+
+	h, ok := v.(originalHolder)
+	if !ok {
+		return
+	}
+	columns, values := columnsAndValues(rv.Elem())
+	original := make(map[string]any, len(columns))
+	for i, column := range columns {
+		original[column] = comparableValue(values[i])
+	}
+	h.baseModel().original = original
+}
+
+// GetOriginal returns the value column had when v was last loaded or saved.
+func GetOriginal(v Model, column string) (any, bool) {
+	original := getOriginal(v)
+	if original == nil {
+		return nil, false
+	}
+	value, ok := original[column]
+	return value, ok
+}
+
+// GetDirty returns the columns of v that have changed since it was last loaded
+// or saved and their new values. Models that have not been loaded or saved
+// return every column.
+func GetDirty(v Model) map[string]any {
+	columns, values := columnsAndValues(reflect.ValueOf(v).Elem())
+	return dirty(getOriginal(v), columns, values)
+}
+
+// IsDirty reports if any of the columns of v have changed since it was last
+// loaded or saved. If no columns are given all columns are checked.
+func IsDirty(v Model, columns ...string) bool {
+	changed := GetDirty(v)
+	if len(columns) == 0 {
+		return len(changed) > 0
+	}
+	for _, column := range columns {
+		if _, ok := changed[column]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func getOriginal(v Model) map[string]any {
+	h, ok := v.(originalHolder)
+	if !ok {
+		return nil
+	}
+	return h.baseModel().original
+}
+
+func dirty(original map[string]any, columns []string, values []any) map[string]any {
+	changed := map[string]any{}
+	for i, column := range columns {
+		if original != nil {
+			if o, ok := original[column]; ok && reflect.DeepEqual(o, comparableValue(values[i])) {
+				continue
+			}
+		}
+		changed[column] = values[i]
+	}
+	return changed
+}
+
+// comparableValue returns the value that would be written to the database so
+// values that share memory with the model aren't changed along with it.
+func comparableValue(v any) any {
+	if valuer, ok := v.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil
+		}
+		value, err := valuer.Value()
+		if err == nil {
+			v = value
+		}
+	}
+	switch v := v.(type) {
+	case []byte:
+		return append([]byte(nil), v...)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		return comparableValue(rv.Elem().Interface())
+	}
+	return v
+}
+
+// dirtyColumns filters columns and values to the ones that have changed. If
+// there is no original every column is returned.
+func dirtyColumns(original map[string]any, columns []string, values []any) ([]string, []any) {
+	if original == nil {
+		return columns, values
+	}
+	changed := dirty(original, columns, values)
+	newColumns := make([]string, 0, len(changed))
+	newValues := make([]any, 0, len(changed))
+	for i, column := range columns {
+		if _, ok := changed[column]; ok {
+			newColumns = append(newColumns, column)
+			newValues = append(newValues, values[i])
+		}
+	}
+	return newColumns, newValues
+}
+
+// HasOriginal reports if v has original values. During AfterSave hooks it is
+// false for models that were just inserted.
+func HasOriginal(v Model) bool {
+	return getOriginal(v) != nil
+}
+
+// GetAttributes returns the current value of every column of v.
+func GetAttributes(v Model) map[string]any {
+	columns, values := columnsAndValues(reflect.ValueOf(v).Elem())
+	attributes := make(map[string]any, len(columns))
+	for i, column := range columns {
+		attributes[column] = values[i]
+	}
+	return attributes
+}
@@ -0,0 +1,233 @@
+package mixins
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/clog"
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/hooks"
+	"github.com/abibby/salusa/database/jsoncolumn"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/abibby/salusa/internal/helpers"
+)
+
+type AuditEvent string
+
+const (
+	AuditCreated = AuditEvent("created")
+	AuditUpdated = AuditEvent("updated")
+	AuditDeleted = AuditEvent("deleted")
+)
+
+// Audit is a change to an auditable model.
+type Audit struct {
+	model.BaseModel
+	ID            int                         `json:"id"             db:"id,primary,autoincrement"`
+	AuditableType string                      `json:"auditable_type" db:"auditable_type"`
+	AuditableID   string                      `json:"auditable_id"   db:"auditable_id"`
+	Event         AuditEvent                  `json:"event"          db:"event"`
+	OldValues     jsoncolumn.Map[string, any] `json:"old_values"     db:"old_values"`
+	NewValues     jsoncolumn.Map[string, any] `json:"new_values"     db:"new_values"`
+	UserID        *string                     `json:"user_id"        db:"user_id"`
+	RequestID     *string                     `json:"request_id"     db:"request_id"`
+	CreatedAt     time.Time                   `json:"created_at"     db:"created_at"`
+}
+
+func (*Audit) Table() string {
+	return "audits"
+}
+
+// AuditExcluder is implemented by auditable models with columns that should
+// not be recorded, e.g. passwords.
+type AuditExcluder interface {
+	AuditExclude() []string
+}
+
+// Auditable records inserts, updates and deletes of a model in the audits
+// table along with the subject of the current user's claims and the request
+// id.
+//
+// Deletes, including soft and force deletes, are recorded by AuditableScope.
+// The deleted rows are loaded to record their values, so deleting many rows at
+// once loads all of them.
+type Auditable struct{}
+
+var _ hooks.ModelAfterSaver = (*Auditable)(nil)
+
+func (a *Auditable) Scopes() []*builder.Scope {
+	return []*builder.Scope{
+		AuditableScope,
+	}
+}
+
+// AfterSaveModel implements hooks.ModelAfterSaver.
+func (a *Auditable) AfterSaveModel(ctx context.Context, tx database.DB, m any) error {
+	v, ok := m.(model.Model)
+	if !ok {
+		return nil
+	}
+	changes := model.GetDirty(v)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	audit := newAudit(ctx, v)
+	audit.NewValues = auditValues(v, changes)
+	if !model.HasOriginal(v) {
+		audit.Event = AuditCreated
+	} else {
+		audit.Event = AuditUpdated
+		old := make(map[string]any, len(changes))
+		for column := range changes {
+			old[column], _ = model.GetOriginal(v, column)
+		}
+		audit.OldValues = auditValues(v, old)
+	}
+	err := model.SaveContext(ctx, tx, audit)
+	if err != nil {
+		return fmt.Errorf("failed to save audit: %w", err)
+	}
+	return nil
+}
+
+var AuditableScope = &builder.Scope{
+	Name: "auditable",
+	Delete: func(next func(q *builder.Builder, tx database.DB) error) func(q *builder.Builder, tx database.DB) error {
+		return func(q *builder.Builder, tx database.DB) error {
+			ctx := q.Context()
+			v, ok := q.GetModel().(model.Model)
+			if !ok {
+				return next(q, tx)
+			}
+
+			deleted := reflect.New(reflect.SliceOf(reflect.TypeOf(v)))
+			err := q.Load(tx, deleted.Interface())
+			if err != nil {
+				return fmt.Errorf("failed to load deleted models: %w", err)
+			}
+
+			err = next(q, tx)
+			if err != nil {
+				return err
+			}
+
+			audits := make([]*Audit, deleted.Elem().Len())
+			for i := range audits {
+				d := deleted.Elem().Index(i).Interface().(model.Model)
+				audits[i] = newAudit(ctx, d)
+				audits[i].Event = AuditDeleted
+				audits[i].OldValues = auditValues(d, model.GetAttributes(d))
+			}
+			err = model.InsertManyContext(ctx, tx, audits)
+			if err != nil {
+				return fmt.Errorf("failed to save audits: %w", err)
+			}
+			return nil
+		}
+	},
+}
+
+// AuditHistory returns a query for the audits of m, oldest first.
+func AuditHistory(m model.Model) *builder.ModelBuilder[*Audit] {
+	return builder.From[*Audit]().
+		Where("auditable_type", "=", database.GetTable(m)).
+		Where("auditable_id", "=", auditableID(m)).
+		OrderBy("id")
+}
+
+// AuditMigration creates the audits table.
+func AuditMigration(name string) *migrate.Migration {
+	return &migrate.Migration{
+		Name: name,
+		Up: schema.Create("audits", func(table *schema.Blueprint) {
+			table.Int("id").Primary().AutoIncrement()
+			table.String("auditable_type")
+			table.String("auditable_id")
+			table.String("event")
+			table.JSON("old_values").Nullable()
+			table.JSON("new_values").Nullable()
+			table.String("user_id").Nullable()
+			table.String("request_id").Nullable()
+			table.DateTime("created_at")
+			table.Index("audits-auditable_type-auditable_id").AddColumn("auditable_type").AddColumn("auditable_id")
+		}),
+		Down: schema.DropIfExists("audits"),
+	}
+}
+
+func newAudit(ctx context.Context, v model.Model) *Audit {
+	audit := &Audit{
+		AuditableType: database.GetTable(v),
+		AuditableID:   auditableID(v),
+		CreatedAt:     time.Now(),
+	}
+	if claims, ok := auth.GetClaimsCtx(ctx); ok && claims.Subject != "" {
+		audit.UserID = &claims.Subject
+	}
+	if requestID, ok := clog.Value(ctx, clog.RequestIDKey); ok {
+		id := requestID.String()
+		audit.RequestID = &id
+	}
+	return audit
+}
+
+func auditValues(v model.Model, values map[string]any) jsoncolumn.Map[string, any] {
+	excluded := []string{}
+	if excluder, ok := v.(AuditExcluder); ok {
+		excluded = excluder.AuditExclude()
+	}
+	result := make(jsoncolumn.Map[string, any], len(values))
+	for column, value := range values {
+		if contains(excluded, column) {
+			continue
+		}
+		result[column] = auditValue(value)
+	}
+	return result
+}
+
+func auditableID(v model.Model) string {
+	pKeys := helpers.PrimaryKey(v)
+	ids := make([]string, 0, len(pKeys))
+	for _, pKey := range pKeys {
+		value, ok := helpers.GetValue(v, pKey)
+		if !ok {
+			continue
+		}
+		ids = append(ids, fmt.Sprint(auditValue(value)))
+	}
+	return strings.Join(ids, ",")
+}
+
+// auditValue returns the value that is written to the database for v.
+func auditValue(v any) any {
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err == nil {
+			v = value
+		}
+	}
+	if b, ok := v.([]byte); ok {
+		return hex.EncodeToString(b)
+	}
+	return v
+}
+
+func contains(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
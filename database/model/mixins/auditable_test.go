@@ -0,0 +1,151 @@
+package mixins_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/clog"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/model/mixins"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/abibby/salusa/internal/test"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type AuditedFoo struct {
+	model.BaseModel
+	mixins.Auditable
+	ID       int    `db:"id,primary,autoincrement"`
+	Name     string `db:"name"`
+	Password string `db:"password"`
+}
+
+func (*AuditedFoo) Table() string {
+	return "audited_foos"
+}
+
+func (*AuditedFoo) AuditExclude() []string {
+	return []string{"password"}
+}
+
+type AuditedSoftFoo struct {
+	model.BaseModel
+	mixins.SoftDelete
+	mixins.Auditable
+	ID   int    `db:"id,primary,autoincrement"`
+	Name string `db:"name"`
+}
+
+func (*AuditedSoftFoo) Table() string {
+	return "audited_soft_foos"
+}
+
+func createAuditTables(t *testing.T, ctx context.Context, tx *sqlx.Tx) {
+	err := schema.Create("audited_foos", func(table *schema.Blueprint) {
+		table.Int("id").Primary().AutoIncrement()
+		table.String("name")
+		table.String("password")
+	}).Run(ctx, tx)
+	assert.NoError(t, err)
+	err = mixins.AuditMigration("audits").Up.Run(ctx, tx)
+	assert.NoError(t, err)
+}
+
+func TestAuditable(t *testing.T) {
+	test.Run(t, "records changes", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		ctx = auth.WithClaims(ctx, auth.NewClaims().WithSubject("user-1"))
+		ctx = clog.With(ctx, slog.String(clog.RequestIDKey, "request-1"))
+		createAuditTables(t, ctx, tx)
+
+		foo := &AuditedFoo{Name: "old", Password: "secret"}
+		err := model.SaveContext(ctx, tx, foo)
+		assert.NoError(t, err)
+
+		foo.Name = "new"
+		err = model.SaveContext(ctx, tx, foo)
+		assert.NoError(t, err)
+
+		err = builder.From[*AuditedFoo]().WithContext(ctx).Where("id", "=", foo.ID).Delete(tx)
+		assert.NoError(t, err)
+
+		audits, err := mixins.AuditHistory(foo).Get(tx)
+		assert.NoError(t, err)
+		if !assert.Len(t, audits, 3) {
+			return
+		}
+
+		assert.Equal(t, mixins.AuditCreated, audits[0].Event)
+		assert.Equal(t, "audited_foos", audits[0].AuditableType)
+		assert.Equal(t, "1", audits[0].AuditableID)
+		assert.Empty(t, audits[0].OldValues)
+		assert.Equal(t, "old", audits[0].NewValues["name"])
+		assert.NotContains(t, audits[0].NewValues, "password")
+		assert.Equal(t, "user-1", *audits[0].UserID)
+		assert.Equal(t, "request-1", *audits[0].RequestID)
+
+		assert.Equal(t, mixins.AuditUpdated, audits[1].Event)
+		assert.Equal(t, map[string]any{"name": "old"}, map[string]any(audits[1].OldValues))
+		assert.Equal(t, map[string]any{"name": "new"}, map[string]any(audits[1].NewValues))
+
+		assert.Equal(t, mixins.AuditDeleted, audits[2].Event)
+		assert.Equal(t, "new", audits[2].OldValues["name"])
+		assert.Empty(t, audits[2].NewValues)
+	})
+
+	test.Run(t, "no user", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		createAuditTables(t, ctx, tx)
+
+		foo := &AuditedFoo{Name: "name"}
+		err := model.SaveContext(ctx, tx, foo)
+		assert.NoError(t, err)
+
+		audits, err := mixins.AuditHistory(foo).Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, audits, 1) {
+			assert.Nil(t, audits[0].UserID)
+			assert.Nil(t, audits[0].RequestID)
+		}
+	})
+
+	test.Run(t, "soft deletes", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		createAuditTables(t, ctx, tx)
+		err := migrate.RunModelCreate(ctx, tx, &AuditedSoftFoo{})
+		assert.NoError(t, err)
+
+		foo := &AuditedSoftFoo{Name: "name"}
+		err = model.SaveContext(ctx, tx, foo)
+		assert.NoError(t, err)
+
+		err = builder.From[*AuditedSoftFoo]().Where("id", "=", foo.ID).Delete(tx)
+		assert.NoError(t, err)
+
+		count, err := builder.From[*AuditedSoftFoo]().Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		err = builder.From[*AuditedSoftFoo]().Where("id", "=", foo.ID).ForceDelete(tx)
+		assert.NoError(t, err)
+
+		count, err = builder.From[*AuditedSoftFoo]().WithTrashed().Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		audits, err := mixins.AuditHistory(foo).Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, audits, 3) {
+			assert.Equal(t, mixins.AuditCreated, audits[0].Event)
+			assert.Equal(t, mixins.AuditDeleted, audits[1].Event)
+			assert.Nil(t, audits[1].OldValues["deleted_at"])
+			assert.Equal(t, mixins.AuditDeleted, audits[2].Event)
+			assert.NotNil(t, audits[2].OldValues["deleted_at"])
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package mixins
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/dialects"
+	"github.com/abibby/salusa/database/hooks"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/internal/helpers"
+)
+
+// Searchable keeps the full text index of a model that implements
+// builder.Searchable up to date. It is only needed for dialects that store the
+// index in a separate table, like SQLite, on other dialects it does nothing.
+// The index is created with schema.CreateSearchIndex.
+//
+// Deletes are synced by SearchableScope. Soft deleted rows stay in the index
+// so they can be restored, they are removed when they are force deleted.
+type Searchable struct{}
+
+var _ hooks.ModelAfterSaver = (*Searchable)(nil)
+
+func (s *Searchable) Scopes() []*builder.Scope {
+	return []*builder.Scope{
+		SearchableScope,
+	}
+}
+
+// AfterSaveModel implements hooks.ModelAfterSaver.
+func (s *Searchable) AfterSaveModel(ctx context.Context, tx database.DB, m any) error {
+	syncer, ok := dialects.New().(dialects.SearchSyncer)
+	if !ok {
+		return nil
+	}
+	v, ok := m.(model.Model)
+	if !ok {
+		return nil
+	}
+	searchable, ok := m.(builder.Searchable)
+	if !ok {
+		return nil
+	}
+	columns := searchable.SearchColumns()
+	if model.HasOriginal(v) && !model.IsDirty(v, columns...) {
+		return nil
+	}
+
+	pKeys := helpers.PrimaryKey(v)
+	if len(pKeys) != 1 {
+		return fmt.Errorf("search requires a single primary key, %T has %d", v, len(pKeys))
+	}
+	table := database.GetTable(v)
+	attributes := model.GetAttributes(v)
+	key := attributes[pKeys[0]]
+
+	err := execSearch(ctx, tx, syncer.DeleteSearch(table)+"(?)", []any{key})
+	if err != nil {
+		return err
+	}
+
+	bindings := make([]any, 0, len(columns)+1)
+	bindings = append(bindings, key)
+	for _, column := range columns {
+		bindings = append(bindings, attributes[column])
+	}
+	return execSearch(ctx, tx, syncer.InsertSearch(table, columns), bindings)
+}
+
+func execSearch(ctx context.Context, tx database.DB, query string, bindings []any) error {
+	sql, bindings, err := helpers.Raw(query, bindings...).SQLString(dialects.New())
+	if err != nil {
+		return err
+	}
+	_, err = database.Exec(ctx, tx, sql, bindings)
+	if err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+	return nil
+}
+
+var SearchableScope = &builder.Scope{
+	Name: "searchable",
+	Delete: func(next func(q *builder.Builder, tx database.DB) error) func(q *builder.Builder, tx database.DB) error {
+		return func(q *builder.Builder, tx database.DB) error {
+			syncer, ok := dialects.New().(dialects.SearchSyncer)
+			if !ok || q.SoftDeletes() {
+				return next(q, tx)
+			}
+			m := q.GetModel()
+			if _, ok := m.(builder.Searchable); !ok {
+				return next(q, tx)
+			}
+			pKeys := helpers.PrimaryKey(m)
+			if len(pKeys) != 1 {
+				return next(q, tx)
+			}
+
+			table := q.GetTable()
+			sql, bindings, err := helpers.Concat(
+				helpers.Raw(syncer.DeleteSearch(table)),
+				helpers.Group(q.Clone().Select(table+"."+pKeys[0])),
+			).SQLString(dialects.New())
+			if err != nil {
+				return err
+			}
+			_, err = database.Exec(q.Context(), tx, sql, bindings)
+			if err != nil {
+				return fmt.Errorf("failed to update search index: %w", err)
+			}
+			return next(q, tx)
+		}
+	},
+}
@@ -0,0 +1,195 @@
+//go:build sqlite_fts5
+
+package mixins_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/migrate"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/model/mixins"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/abibby/salusa/internal/test"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type SearchFoo struct {
+	model.BaseModel
+	mixins.Searchable
+	ID    int    `db:"id,primary,autoincrement"`
+	Title string `db:"title"`
+	Body  string `db:"body"`
+}
+
+func (*SearchFoo) Table() string {
+	return "search_foos"
+}
+
+func (*SearchFoo) SearchColumns() []string {
+	return []string{"title", "body"}
+}
+
+type SearchUUID struct {
+	model.BaseModel
+	mixins.Searchable
+	ID    string `db:"id,primary"`
+	Title string `db:"title"`
+}
+
+func (*SearchUUID) Table() string {
+	return "search_uuids"
+}
+
+func (*SearchUUID) SearchColumns() []string {
+	return []string{"title"}
+}
+
+type SearchSoftFoo struct {
+	model.BaseModel
+	mixins.SoftDelete
+	mixins.Searchable
+	ID    int    `db:"id,primary,autoincrement"`
+	Title string `db:"title"`
+}
+
+func (*SearchSoftFoo) Table() string {
+	return "search_soft_foos"
+}
+
+func (*SearchSoftFoo) SearchColumns() []string {
+	return []string{"title"}
+}
+
+func createSearchFoos(t *testing.T, tx *sqlx.Tx) []*SearchFoo {
+	ctx := context.Background()
+	err := migrate.RunModelCreate(ctx, tx, &SearchFoo{})
+	assert.NoError(t, err)
+
+	// rows from before the index existed
+	_, err = tx.Exec(`INSERT INTO "search_foos" ("title", "body") VALUES ('existing', 'indexed when the index is created')`)
+	assert.NoError(t, err)
+
+	err = schema.CreateSearchIndex("search_foos", "id", "title", "body").Run(ctx, tx)
+	assert.NoError(t, err)
+
+	foos := []*SearchFoo{
+		{ID: 1},
+		{Title: "go", Body: "a fast language"},
+		{Title: "rust", Body: "a safe language"},
+	}
+	for _, f := range foos[1:] {
+		assert.NoError(t, model.Save(tx, f))
+	}
+	return foos
+}
+
+func TestSearchable(t *testing.T) {
+	test.Run(t, "search", func(t *testing.T, tx *sqlx.Tx) {
+		foos := createSearchFoos(t, tx)
+
+		result, err := builder.From[*SearchFoo]().Search("language").OrderBy("id").Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, result, 2) {
+			assert.Equal(t, foos[1].ID, result[0].ID)
+			assert.Equal(t, foos[2].ID, result[1].ID)
+		}
+
+		result, err = builder.From[*SearchFoo]().Search("indexed").Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, result, 1) {
+			assert.Equal(t, foos[0].ID, result[0].ID)
+		}
+	})
+
+	test.Run(t, "update", func(t *testing.T, tx *sqlx.Tx) {
+		foos := createSearchFoos(t, tx)
+
+		foos[1].Body = "a garbage collected language"
+		assert.NoError(t, model.Save(tx, foos[1]))
+
+		count, err := builder.From[*SearchFoo]().Search("fast").Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		count, err = builder.From[*SearchFoo]().Search("garbage").Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	test.Run(t, "delete", func(t *testing.T, tx *sqlx.Tx) {
+		foos := createSearchFoos(t, tx)
+
+		err := builder.From[*SearchFoo]().Where("id", "=", foos[1].ID).Delete(tx)
+		assert.NoError(t, err)
+
+		count := 0
+		err = tx.Get(&count, `SELECT count(*) FROM "search_foos_search"`)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	test.Run(t, "soft delete", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		err := migrate.RunModelCreate(ctx, tx, &SearchSoftFoo{})
+		assert.NoError(t, err)
+		err = schema.CreateSearchIndex("search_soft_foos", "id", "title").Run(ctx, tx)
+		assert.NoError(t, err)
+
+		foo := &SearchSoftFoo{Title: "go"}
+		assert.NoError(t, model.Save(tx, foo))
+
+		err = builder.From[*SearchSoftFoo]().Where("id", "=", foo.ID).Delete(tx)
+		assert.NoError(t, err)
+
+		count, err := builder.From[*SearchSoftFoo]().Search("go").Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		count, err = builder.From[*SearchSoftFoo]().WithTrashed().Search("go").Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		err = builder.From[*SearchSoftFoo]().Where("id", "=", foo.ID).ForceDelete(tx)
+		assert.NoError(t, err)
+
+		err = tx.Get(&count, `SELECT count(*) FROM "search_soft_foos_search"`)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	test.Run(t, "string keys", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		err := migrate.RunModelCreate(ctx, tx, &SearchUUID{})
+		assert.NoError(t, err)
+		err = schema.CreateSearchIndex("search_uuids", "id", "title").Run(ctx, tx)
+		assert.NoError(t, err)
+
+		foo := &SearchUUID{ID: "b5c1d8e4-go", Title: "go"}
+		assert.NoError(t, model.Save(tx, foo))
+		assert.NoError(t, model.Save(tx, &SearchUUID{ID: "a0f3c2d1-rust", Title: "rust"}))
+
+		foo.Title = "golang"
+		assert.NoError(t, model.Save(tx, foo))
+
+		result, err := builder.From[*SearchUUID]().Search("golang").Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, result, 1) {
+			assert.Equal(t, foo.ID, result[0].ID)
+		}
+
+		count, err := builder.From[*SearchUUID]().Search("go").Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	test.Run(t, "empty term", func(t *testing.T, tx *sqlx.Tx) {
+		createSearchFoos(t, tx)
+
+		count, err := builder.From[*SearchFoo]().Search(" ").Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
@@ -3,7 +3,6 @@ package mixins
 import (
 	"time"
 
-	"github.com/abibby/salusa/database"
 	"github.com/abibby/salusa/database/builder"
 )
 
@@ -17,16 +16,9 @@ func (f *SoftDelete) Scopes() []*builder.Scope {
 	}
 }
 
-var SoftDeleteScope = &builder.Scope{
-	Name: "soft-deletes",
-	Query: func(b *builder.Builder) *builder.Builder {
-		return b.Where(b.GetTable()+".deleted_at", "=", nil)
-	},
-	Delete: func(next func(q *builder.Builder, tx database.DB) error) func(q *builder.Builder, tx database.DB) error {
-		return func(q *builder.Builder, tx database.DB) error {
-			return q.Update(tx, builder.Updates{
-				"deleted_at": time.Now(),
-			})
-		}
-	},
+// Trashed returns true if the model has been soft deleted.
+func (f *SoftDelete) Trashed() bool {
+	return f.DeletedAt != nil
 }
+
+var SoftDeleteScope = builder.SoftDeleteScope
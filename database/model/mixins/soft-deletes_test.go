@@ -1,9 +1,11 @@
 package mixins_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/migrate"
 	"github.com/abibby/salusa/database/model"
 	"github.com/abibby/salusa/database/model/mixins"
 	"github.com/abibby/salusa/internal/test"
@@ -30,5 +32,151 @@ func TestSoftDeletes(t *testing.T) {
 		assert.Equal(t, foo.ID, foos[0].ID)
 		assert.NotNil(t, foos[0].DeletedAt)
 	})
-	//content
+
+	test.Run(t, "with_trashed", func(t *testing.T, tx *sqlx.Tx) {
+		a, b := createSoftDeleted(t, tx)
+
+		foos, err := builder.From[*test.FooSoftDelete]().WithTrashed().OrderBy("id").Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, foos, 2) {
+			assert.Equal(t, a.ID, foos[0].ID)
+			assert.Equal(t, b.ID, foos[1].ID)
+		}
+	})
+
+	test.Run(t, "only_trashed", func(t *testing.T, tx *sqlx.Tx) {
+		_, b := createSoftDeleted(t, tx)
+
+		foos, err := builder.From[*test.FooSoftDelete]().OnlyTrashed().Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, foos, 1) {
+			assert.Equal(t, b.ID, foos[0].ID)
+			assert.True(t, foos[0].Trashed())
+		}
+	})
+
+	test.Run(t, "restore", func(t *testing.T, tx *sqlx.Tx) {
+		_, b := createSoftDeleted(t, tx)
+
+		err := builder.From[*test.FooSoftDelete]().Where("id", "=", b.ID).Restore(tx)
+		assert.NoError(t, err)
+
+		foos, err := builder.From[*test.FooSoftDelete]().Get(tx)
+		assert.NoError(t, err)
+		assert.Len(t, foos, 2)
+	})
+
+	test.Run(t, "force_delete", func(t *testing.T, tx *sqlx.Tx) {
+		createSoftDeleted(t, tx)
+
+		err := builder.From[*test.FooSoftDelete]().ForceDelete(tx)
+		assert.NoError(t, err)
+
+		count, err := builder.From[*test.FooSoftDelete]().WithTrashed().Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	test.Run(t, "cascade", func(t *testing.T, tx *sqlx.Tx) {
+		parents := createSoftParents(t, tx)
+
+		err := builder.From[*SoftParent]().Where("id", "=", parents[0].ID).Delete(tx)
+		assert.NoError(t, err)
+
+		children, err := builder.From[*SoftChild]().Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, children, 2) {
+			assert.Equal(t, parents[1].ID, children[0].SoftParentID)
+			assert.Equal(t, parents[1].ID, children[1].SoftParentID)
+		}
+
+		count, err := builder.From[*SoftChild]().OnlyTrashed().Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	test.Run(t, "cascade restore", func(t *testing.T, tx *sqlx.Tx) {
+		parents := createSoftParents(t, tx)
+
+		err := builder.From[*SoftParent]().Delete(tx)
+		assert.NoError(t, err)
+
+		err = builder.From[*SoftParent]().Where("id", "=", parents[0].ID).Restore(tx)
+		assert.NoError(t, err)
+
+		children, err := builder.From[*SoftChild]().Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, children, 2) {
+			assert.Equal(t, parents[0].ID, children[0].SoftParentID)
+			assert.Equal(t, parents[0].ID, children[1].SoftParentID)
+		}
+
+		count, err := builder.From[*SoftChild]().OnlyTrashed().Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	test.Run(t, "cascade force delete", func(t *testing.T, tx *sqlx.Tx) {
+		parents := createSoftParents(t, tx)
+
+		err := builder.From[*SoftParent]().Where("id", "=", parents[0].ID).Delete(tx)
+		assert.NoError(t, err)
+
+		err = builder.From[*SoftParent]().Where("id", "=", parents[0].ID).ForceDelete(tx)
+		assert.NoError(t, err)
+
+		children, err := builder.From[*SoftChild]().WithTrashed().Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, children, 2) {
+			assert.Equal(t, parents[1].ID, children[0].SoftParentID)
+			assert.Equal(t, parents[1].ID, children[1].SoftParentID)
+		}
+	})
+}
+
+func createSoftParents(t *testing.T, tx *sqlx.Tx) []*SoftParent {
+	err := migrate.RunModelCreate(context.Background(), tx, &SoftParent{}, &SoftChild{})
+	assert.NoError(t, err)
+
+	parents := []*SoftParent{{}, {}}
+	for _, p := range parents {
+		assert.NoError(t, model.Save(tx, p))
+		for range 2 {
+			assert.NoError(t, model.Save(tx, &SoftChild{SoftParentID: p.ID}))
+		}
+	}
+	return parents
+}
+
+type SoftParent struct {
+	model.BaseModel
+	mixins.SoftDelete
+	ID       int                          `db:"id,primary,autoincrement"`
+	Children *builder.HasMany[*SoftChild] `db:"-" cascade:"delete"`
+}
+
+func (*SoftParent) Table() string {
+	return "soft_parents"
+}
+
+type SoftChild struct {
+	model.BaseModel
+	mixins.SoftDelete
+	ID           int `db:"id,primary,autoincrement"`
+	SoftParentID int `db:"soft_parent_id"`
+}
+
+func (*SoftChild) Table() string {
+	return "soft_children"
+}
+
+func createSoftDeleted(t *testing.T, tx *sqlx.Tx) (*test.FooSoftDelete, *test.FooSoftDelete) {
+	a := &test.FooSoftDelete{}
+	b := &test.FooSoftDelete{}
+	assert.NoError(t, model.Save(tx, a))
+	assert.NoError(t, model.Save(tx, b))
+
+	err := builder.From[*test.FooSoftDelete]().Where("id", "=", b.ID).Delete(tx)
+	assert.NoError(t, err)
+	return a, b
 }
@@ -0,0 +1,59 @@
+package mixins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/hooks"
+	"github.com/abibby/salusa/tenant"
+)
+
+var (
+	ErrMissingTenant  = errors.New("no tenant in context")
+	ErrTenantMismatch = errors.New("model belongs to a different tenant")
+)
+
+// TenantOwned constrains every query, update and delete of a model to the
+// tenant in the query's context. Queries without a tenant in their context
+// match no rows, use WithoutGlobalScope(TenantScope) to query every tenant.
+type TenantOwned struct {
+	TenantID string `json:"tenant_id" db:"tenant_id"`
+}
+
+var _ hooks.BeforeSaver = (*TenantOwned)(nil)
+
+func (t *TenantOwned) Scopes() []*builder.Scope {
+	return []*builder.Scope{
+		TenantScope,
+	}
+}
+
+// BeforeSave implements hooks.BeforeSaver.
+func (t *TenantOwned) BeforeSave(ctx context.Context, tx database.DB) error {
+	current, ok := tenant.Get(ctx)
+	if t.TenantID == "" {
+		if !ok {
+			return ErrMissingTenant
+		}
+		t.TenantID = current
+		return nil
+	}
+	if ok && t.TenantID != current {
+		return fmt.Errorf("%s: %w", t.TenantID, ErrTenantMismatch)
+	}
+	return nil
+}
+
+var TenantScope = &builder.Scope{
+	Name: "tenant",
+	Query: func(b *builder.Builder) *builder.Builder {
+		current, ok := tenant.Get(b.Context())
+		if !ok {
+			return b.WhereRaw("1 = 0")
+		}
+		return b.Where(b.GetTable()+".tenant_id", "=", current)
+	},
+}
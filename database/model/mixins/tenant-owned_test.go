@@ -0,0 +1,106 @@
+package mixins_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/database/model/mixins"
+	"github.com/abibby/salusa/database/schema"
+	"github.com/abibby/salusa/internal/test"
+	"github.com/abibby/salusa/tenant"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type TenantFoo struct {
+	model.BaseModel
+	mixins.TenantOwned
+	ID   int    `db:"id,primary,autoincrement"`
+	Name string `db:"name"`
+}
+
+func (*TenantFoo) Table() string {
+	return "tenant_foos"
+}
+
+func createTenantFoos(t *testing.T, tx *sqlx.Tx) (context.Context, context.Context) {
+	ctx := context.Background()
+	err := schema.Create("tenant_foos", func(table *schema.Blueprint) {
+		table.Int("id").Primary().AutoIncrement()
+		table.String("tenant_id")
+		table.String("name")
+	}).Run(ctx, tx)
+	assert.NoError(t, err)
+
+	a := tenant.WithTenant(ctx, "a")
+	b := tenant.WithTenant(ctx, "b")
+	for _, ctx := range []context.Context{a, a, b} {
+		err = model.SaveContext(ctx, tx, &TenantFoo{Name: "foo"})
+		assert.NoError(t, err)
+	}
+	return a, b
+}
+
+func TestTenantOwned(t *testing.T) {
+	test.Run(t, "fills tenant", func(t *testing.T, tx *sqlx.Tx) {
+		createTenantFoos(t, tx)
+
+		foos, err := builder.From[*TenantFoo]().WithoutGlobalScope(mixins.TenantScope).OrderBy("id").Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, foos, 3) {
+			assert.Equal(t, "a", foos[0].TenantID)
+			assert.Equal(t, "a", foos[1].TenantID)
+			assert.Equal(t, "b", foos[2].TenantID)
+		}
+	})
+
+	test.Run(t, "scopes queries", func(t *testing.T, tx *sqlx.Tx) {
+		a, b := createTenantFoos(t, tx)
+
+		count, err := builder.From[*TenantFoo]().WithContext(a).Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		count, err = builder.From[*TenantFoo]().WithContext(b).Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		count, err = builder.From[*TenantFoo]().Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	test.Run(t, "scopes updates and deletes", func(t *testing.T, tx *sqlx.Tx) {
+		a, b := createTenantFoos(t, tx)
+
+		err := builder.From[*TenantFoo]().WithContext(b).Update(tx, builder.Updates{"name": "updated"})
+		assert.NoError(t, err)
+		err = builder.From[*TenantFoo]().WithContext(b).Delete(tx)
+		assert.NoError(t, err)
+
+		foos, err := builder.From[*TenantFoo]().WithContext(a).Get(tx)
+		assert.NoError(t, err)
+		if assert.Len(t, foos, 2) {
+			assert.Equal(t, "foo", foos[0].Name)
+			assert.Equal(t, "foo", foos[1].Name)
+		}
+
+		count, err := builder.From[*TenantFoo]().WithContext(b).Count(tx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	test.Run(t, "save errors", func(t *testing.T, tx *sqlx.Tx) {
+		_, b := createTenantFoos(t, tx)
+
+		err := model.SaveContext(context.Background(), tx, &TenantFoo{Name: "foo"})
+		assert.ErrorIs(t, err, mixins.ErrMissingTenant)
+
+		foo := &TenantFoo{Name: "foo"}
+		foo.TenantID = "a"
+		err = model.SaveContext(b, tx, foo)
+		assert.ErrorIs(t, err, mixins.ErrTenantMismatch)
+	})
+}
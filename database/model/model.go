@@ -17,6 +17,7 @@ type Model interface {
 type BaseModel struct {
 	inDatabase bool
 	ctx        context.Context
+	original   map[string]any
 }
 
 var _ Model = &BaseModel{}
@@ -6,6 +6,7 @@ import (
 	"reflect"
 
 	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/cache"
 	"github.com/abibby/salusa/database/dialects"
 	"github.com/abibby/salusa/database/hooks"
 	"github.com/abibby/salusa/internal/helpers"
@@ -65,8 +66,16 @@ func MustSaveContext(ctx context.Context, tx database.DB, v Model) {
 		panic(err)
 	}
 }
+
+// SaveContext inserts v if it is not in the database. Otherwise it updates
+// the columns that have changed since it was loaded, if nothing has changed no
+// hooks are run and no query is sent. During the AfterSave hooks GetDirty and
+// GetOriginal report the values from before the save.
 func SaveContext(ctx context.Context, tx database.DB, v Model) error {
 	inDB := v.InDatabase()
+	if inDB && getOriginal(v) != nil && !IsDirty(v) {
+		return nil
+	}
 	err := hooks.BeforeSave(ctx, tx, v)
 	if err != nil {
 		return fmt.Errorf("before save hooks: %w", err)
@@ -75,9 +84,12 @@ func SaveContext(ctx context.Context, tx database.DB, v Model) error {
 	d := dialects.New()
 	columns, values := columnsAndValues(reflect.ValueOf(v).Elem())
 	if inDB {
-		err = update(ctx, tx, d, v, columns, values)
-		if err != nil {
-			return fmt.Errorf("update: %w", err)
+		columns, values = dirtyColumns(getOriginal(v), columns, values)
+		if len(columns) > 0 {
+			err = update(ctx, tx, d, v, columns, values)
+			if err != nil {
+				return fmt.Errorf("update: %w", err)
+			}
 		}
 	} else {
 		err = insert(ctx, tx, d, v, columns, values)
@@ -86,6 +98,11 @@ func SaveContext(ctx context.Context, tx database.DB, v Model) error {
 		}
 	}
 
+	err = cache.FlushTableAfterWrite(ctx, tx, database.GetTable(v))
+	if err != nil {
+		return fmt.Errorf("cache flush: %w", err)
+	}
+
 	err = relationship.InitializeRelationships(v)
 	if err != nil {
 		return fmt.Errorf("initialize relationships: %w", err)
@@ -95,6 +112,9 @@ func SaveContext(ctx context.Context, tx database.DB, v Model) error {
 	if err != nil {
 		return fmt.Errorf("after save hooks: %w", err)
 	}
+
+	// synced after the hooks so they can see what was saved
+	SyncOriginal(v)
 	return nil
 }
 
@@ -133,11 +153,26 @@ func insert(ctx context.Context, tx database.DB, d dialects.Dialect, v any, colu
 			),
 		)
 
+	if isAuto && d.InsertReturning() {
+		r.AddString("RETURNING").
+			Add(helpers.Identifier(pKey))
+	}
+
 	q, bindings, err := r.SQLString(d)
 	if err != nil {
 		return fmt.Errorf("failed to generate sql: %w", err)
 	}
 
+	if isAuto && d.InsertReturning() {
+		var id int64
+		err = tx.QueryRowxContext(ctx, q, bindings...).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to insert model: %w", err)
+		}
+		rPKey.SetInt(id)
+		return nil
+	}
+
 	result, err := tx.ExecContext(ctx, q, bindings...)
 	if err != nil {
 		return fmt.Errorf("failed to insert model: %w", err)
@@ -240,6 +275,9 @@ func InsertMany[T Model](tx database.DB, models []T) error {
 	return InsertManyContext(context.Background(), tx, models)
 }
 func InsertManyContext[T Model](ctx context.Context, tx database.DB, models []T) error {
+	if len(models) == 0 {
+		return nil
+	}
 	for _, v := range models {
 		err := hooks.BeforeSave(ctx, tx, v)
 		if err != nil {
@@ -258,23 +296,57 @@ func InsertManyContext[T Model](ctx context.Context, tx database.DB, models []T)
 		}
 		values[i] = v
 	}
-	err := insertMany(ctx, tx, d, models[0], columns, values)
+	if insertEach(d, models) {
+		for i, v := range models {
+			err := insert(ctx, tx, d, v, columns, values[i])
+			if err != nil {
+				return fmt.Errorf("insert: %w", err)
+			}
+		}
+	} else {
+		ids, err := insertMany(ctx, tx, d, models[0], columns, values)
+		if err != nil {
+			return fmt.Errorf("insert: %w", err)
+		}
+		for i, id := range ids {
+			rPKey, _, _ := isAutoIncrementing(models[i])
+			rPKey.SetInt(id)
+		}
+	}
+	err := cache.FlushTableAfterWrite(ctx, tx, database.GetTable(models[0]))
 	if err != nil {
-		return fmt.Errorf("insert: %w", err)
+		return fmt.Errorf("cache flush: %w", err)
 	}
 	for _, v := range models {
+		err = relationship.InitializeRelationships(v)
 		if err != nil {
 			return fmt.Errorf("initialize relationships: %w", err)
 		}
-		err := hooks.AfterSave(ctx, tx, v)
+		err = hooks.AfterSave(ctx, tx, v)
 		if err != nil {
-			return fmt.Errorf("before save hooks: %w", err)
+			return fmt.Errorf("after save hooks: %w", err)
 		}
 	}
+	SyncOriginal(models)
 	return nil
 }
 
-func insertMany(ctx context.Context, tx database.DB, d dialects.Dialect, v any, columns []string, values [][]any) error {
+// insertEach reports if models have to be inserted one at a time. That is
+// needed when only some of the models need a generated id, or when the
+// generated ids can't be read back from a multi row insert.
+func insertEach[T Model](d dialects.Dialect, models []T) bool {
+	_, _, isAuto := isAutoIncrementing(models[0])
+	for _, v := range models[1:] {
+		if _, _, auto := isAutoIncrementing(v); auto != isAuto {
+			return true
+		}
+	}
+	return isAuto && !d.InsertReturning() && !d.ContiguousInsertIDs()
+}
+
+// insertMany inserts all values in one query and returns the generated ids in
+// the same order if the primary key is auto incrementing.
+func insertMany(ctx context.Context, tx database.DB, d dialects.Dialect, v any, columns []string, values [][]any) ([]int64, error) {
 	_, pKey, isAuto := isAutoIncrementing(v)
 	pKeyIndex := -1
 	if isAuto {
@@ -320,15 +392,52 @@ func insertMany(ctx context.Context, tx database.DB, d dialects.Dialect, v any,
 			),
 		)
 
+	if isAuto && d.InsertReturning() {
+		r.AddString("RETURNING").
+			Add(helpers.Identifier(pKey))
+	}
+
 	q, bindings, err := r.SQLString(d)
 	if err != nil {
-		return fmt.Errorf("failed to generate sql: %w", err)
+		return nil, fmt.Errorf("failed to generate sql: %w", err)
 	}
 
-	_, err = tx.ExecContext(ctx, q, bindings...)
+	if isAuto && d.InsertReturning() {
+		rows, err := tx.QueryxContext(ctx, q, bindings...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert model: %w", err)
+		}
+		defer rows.Close()
+
+		ids := make([]int64, 0, len(values))
+		for rows.Next() {
+			var id int64
+			err = rows.Scan(&id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to insert model: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		return ids, rows.Err()
+	}
+
+	result, err := tx.ExecContext(ctx, q, bindings...)
 	if err != nil {
-		return fmt.Errorf("failed to insert model: %w", err)
+		return nil, fmt.Errorf("failed to insert model: %w", err)
 	}
 
-	return nil
+	if !isAuto {
+		return nil, nil
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("could not get last insert id: %w", err)
+	}
+	id -= int64(len(values) - 1)
+	ids := make([]int64, len(values))
+	for i := range ids {
+		ids[i] = id + int64(i)
+	}
+	return ids, nil
 }
@@ -221,4 +221,109 @@ func TestInsertManyContext(t *testing.T) {
 		}
 	})
 
+	test.Run(t, "sets ids", func(t *testing.T, tx *sqlx.Tx) {
+		model.MustSave(tx, &test.Foo{Name: "existing"})
+
+		foos := []*test.Foo{{Name: "1"}, {Name: "2"}, {Name: "3"}}
+		err := model.InsertManyContext(context.TODO(), tx, foos)
+		assert.NoError(t, err)
+
+		for _, foo := range foos {
+			dbFoo, err := builder.From[*test.Foo]().Find(tx, foo.ID)
+			assert.NoError(t, err)
+			if assert.NotNil(t, dbFoo) {
+				assert.Equal(t, foo.Name, dbFoo.Name)
+			}
+		}
+	})
+
+	test.Run(t, "explicit ids", func(t *testing.T, tx *sqlx.Tx) {
+		foos := []*test.Foo{{ID: 10, Name: "10"}, {ID: 20, Name: "20"}}
+		err := model.InsertManyContext(context.TODO(), tx, foos)
+		assert.NoError(t, err)
+
+		dbFoo, err := builder.From[*test.Foo]().Find(tx, 20)
+		assert.NoError(t, err)
+		if assert.NotNil(t, dbFoo) {
+			assert.Equal(t, "20", dbFoo.Name)
+		}
+	})
+
+	test.Run(t, "initializes relationships and runs hooks", func(t *testing.T, tx *sqlx.Tx) {
+		foos := []*FooSaveHookTest{{Foo: test.Foo{Name: "1"}}, {Foo: test.Foo{Name: "2"}}}
+		err := model.InsertManyContext(context.TODO(), tx, foos)
+		assert.NoError(t, err)
+
+		for _, foo := range foos {
+			assert.True(t, foo.saved)
+			assert.NotNil(t, foo.Bars)
+			assert.NotNil(t, foo.Bar)
+		}
+	})
+
+	test.Run(t, "mixed ids", func(t *testing.T, tx *sqlx.Tx) {
+		foos := []*test.Foo{{Name: "generated"}, {ID: 20, Name: "20"}}
+		err := model.InsertManyContext(context.TODO(), tx, foos)
+		assert.NoError(t, err)
+
+		assert.NotZero(t, foos[0].ID)
+		assert.Equal(t, 20, foos[1].ID)
+		for _, foo := range foos {
+			dbFoo, err := builder.From[*test.Foo]().Find(tx, foo.ID)
+			assert.NoError(t, err)
+			if assert.NotNil(t, dbFoo) {
+				assert.Equal(t, foo.Name, dbFoo.Name)
+			}
+		}
+	})
+}
+
+func TestSave_dirty(t *testing.T) {
+	test.Run(t, "tracks changes", func(t *testing.T, tx *sqlx.Tx) {
+		model.MustSave(tx, &test.Foo{ID: 1, Name: "old"})
+
+		f, err := builder.From[*test.Foo]().Find(tx, 1)
+		assert.NoError(t, err)
+		assert.False(t, model.IsDirty(f))
+
+		f.Name = "new"
+		assert.True(t, model.IsDirty(f))
+		assert.True(t, model.IsDirty(f, "name"))
+		assert.False(t, model.IsDirty(f, "id"))
+		assert.Equal(t, map[string]any{"name": "new"}, model.GetDirty(f))
+
+		original, ok := model.GetOriginal(f, "name")
+		assert.True(t, ok)
+		assert.Equal(t, "old", original)
+
+		err = model.Save(tx, f)
+		assert.NoError(t, err)
+		assert.False(t, model.IsDirty(f))
+
+		original, _ = model.GetOriginal(f, "name")
+		assert.Equal(t, "new", original)
+	})
+
+	test.Run(t, "new models are dirty", func(t *testing.T, tx *sqlx.Tx) {
+		f := &test.Foo{ID: 1, Name: "new"}
+		assert.True(t, model.IsDirty(f))
+		assert.Equal(t, map[string]any{"id": 1, "name": "new"}, model.GetDirty(f))
+	})
+
+	test.Run(t, "unchanged models are not saved", func(t *testing.T, tx *sqlx.Tx) {
+		model.MustSave(tx, &test.Foo{ID: 1, Name: "old"})
+
+		f, err := builder.From[*test.Foo]().Find(tx, 1)
+		assert.NoError(t, err)
+
+		_, err = tx.Exec("UPDATE foos SET name = 'concurrent' WHERE id = 1")
+		assert.NoError(t, err)
+
+		err = model.Save(tx, f)
+		assert.NoError(t, err)
+
+		f, err = builder.From[*test.Foo]().Find(tx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "concurrent", f.Name)
+	})
 }
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	loggedDriversMtx sync.Mutex
+	loggedDrivers    = map[string]bool{}
+)
+
+type loggedConfig struct {
+	Config
+	driverName string
+	replicas   []Config
+}
+
+var _ Replicated = (*loggedConfig)(nil)
+
+// Config returns a config that opens connections with a driver that logs every
+// query, including queries in transactions. Read replicas are also logged.
+func (l *QueryLog) Config(cfg Config) Config {
+	c := &loggedConfig{
+		Config:     cfg,
+		driverName: l.registerDriver(cfg.DriverName()),
+	}
+	if replicated, ok := cfg.(Replicated); ok {
+		for _, replica := range replicated.ReadReplicas() {
+			c.replicas = append(c.replicas, l.Config(replica))
+		}
+	}
+	return c
+}
+
+func (c *loggedConfig) DriverName() string {
+	return c.driverName
+}
+
+func (c *loggedConfig) ReadReplicas() []Config {
+	return c.replicas
+}
+
+// registerDriver registers a driver that wraps driverName and returns its
+// name. The driver is only registered once for each QueryLog.
+func (l *QueryLog) registerDriver(driverName string) string {
+	name := fmt.Sprintf("salusa-query-log-%p-%s", l, driverName)
+
+	loggedDriversMtx.Lock()
+	defer loggedDriversMtx.Unlock()
+	if loggedDrivers[name] {
+		return name
+	}
+
+	// sql.Open doesn't connect, it is only used to look up the driver
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		// returning the original driver name lets sqlx.Open report the error
+		return driverName
+	}
+	d := db.Driver()
+	_ = db.Close()
+
+	sql.Register(name, &loggedDriver{driver: d, log: l})
+	loggedDrivers[name] = true
+	return name
+}
+
+type loggedDriver struct {
+	driver driver.Driver
+	log    *QueryLog
+}
+
+func (d *loggedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &loggedConn{Conn: c, log: d.log}, nil
+}
+
+// loggedConn logs queries that use the drivers QueryerContext and
+// ExecerContext. Drivers without them fall back to prepared statements which
+// are not logged.
+type loggedConn struct {
+	driver.Conn
+	log *QueryLog
+}
+
+var (
+	_ driver.QueryerContext     = (*loggedConn)(nil)
+	_ driver.ExecerContext      = (*loggedConn)(nil)
+	_ driver.ConnBeginTx        = (*loggedConn)(nil)
+	_ driver.ConnPrepareContext = (*loggedConn)(nil)
+	_ driver.Pinger             = (*loggedConn)(nil)
+	_ driver.SessionResetter    = (*loggedConn)(nil)
+	_ driver.Validator          = (*loggedConn)(nil)
+	_ driver.NamedValueChecker  = (*loggedConn)(nil)
+)
+
+func (c *loggedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.log.record(ctx, query, namedValues(args), start, err)
+	}
+	return rows, err
+}
+
+func (c *loggedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.log.record(ctx, query, namedValues(args), start, err)
+	}
+	return result, err
+}
+
+func (c *loggedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck
+}
+
+func (c *loggedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *loggedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *loggedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *loggedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *loggedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func namedValues(args []driver.NamedValue) []any {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
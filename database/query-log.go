@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abibby/salusa/clog"
+	"github.com/jmoiron/sqlx"
+)
+
+// QueryLogConfiger is implemented by configs that enable query logging.
+type QueryLogConfiger interface {
+	QueryLogConfig() *QueryLog
+}
+
+// QueryLog logs queries to the clog logger in the queries context. Every query
+// is logged at debug level with its bindings and duration.
+type QueryLog struct {
+	// SlowThreshold is the duration after which queries are logged as
+	// warnings. Zero disables slow query warnings.
+	SlowThreshold time.Duration
+	// NPlusOneThreshold is the number of times a query with the same shape can
+	// run in a context created with WithQueryTracking before a warning with
+	// the calling stack is logged. Zero disables N+1 detection, it should only
+	// be enabled in development and tests.
+	NPlusOneThreshold int
+}
+
+type queryTracker struct {
+	mtx    sync.Mutex
+	counts map[string]int
+}
+
+type queryTrackerKey struct{}
+
+// WithQueryTracking returns a context that counts the queries run in it so
+// QueryLog can detect N+1 queries.
+func WithQueryTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryTrackerKey{}, &queryTracker{
+		counts: map[string]int{},
+	})
+}
+
+var inList = regexp.MustCompile(`\((\s*(\?|\$\d+)\s*,?)+\)`)
+
+// queryShape removes the parts of a query that change between N+1 queries,
+// like the number of bindings in an IN list.
+func queryShape(query string) string {
+	return inList.ReplaceAllString(query, "(?)")
+}
+
+// track returns the number of times the shape of query has been run in ctx.
+func track(ctx context.Context, query string) int {
+	tracker, ok := ctx.Value(queryTrackerKey{}).(*queryTracker)
+	if !ok {
+		return 0
+	}
+	shape := queryShape(query)
+
+	tracker.mtx.Lock()
+	defer tracker.mtx.Unlock()
+	tracker.counts[shape]++
+	return tracker.counts[shape]
+}
+
+func (l *QueryLog) record(ctx context.Context, query string, bindings []any, start time.Time, err error) {
+	duration := time.Since(start)
+	logger := clog.Use(ctx)
+
+	attrs := []any{
+		"query", query,
+		"bindings", bindings,
+		"duration", duration,
+	}
+	if err != nil {
+		attrs = append(attrs, "err", err)
+	}
+
+	if l.SlowThreshold > 0 && duration > l.SlowThreshold {
+		logger.WarnContext(ctx, "slow query", attrs...)
+	} else if logger.Enabled(ctx, slog.LevelDebug) {
+		logger.DebugContext(ctx, "query", attrs...)
+	}
+
+	if l.NPlusOneThreshold > 0 && track(ctx, query) == l.NPlusOneThreshold {
+		logger.WarnContext(ctx, "possible N+1 query",
+			"query", query,
+			"count", l.NPlusOneThreshold,
+			"stack", callers(),
+		)
+	}
+}
+
+// callers returns the stack outside of the database packages and drivers.
+func callers() []string {
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	stack := []string{}
+	for {
+		frame, more := frames.Next()
+		if !isDatabaseFrame(frame.Function) {
+			stack = append(stack, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		}
+		if !more || len(stack) >= 10 {
+			break
+		}
+	}
+	return stack
+}
+
+var databasePackages = []string{
+	"database/sql.",
+	"github.com/jmoiron/sqlx.",
+	"github.com/abibby/salusa/database.",
+	"github.com/abibby/salusa/database/builder.",
+	"github.com/abibby/salusa/database/model.",
+}
+
+func isDatabaseFrame(function string) bool {
+	for _, pkg := range databasePackages {
+		if strings.HasPrefix(function, pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoggedDB is a DB that logs every query run on it with a QueryLog. Queries
+// in transactions started from it are not logged, use QueryLog.Config to log
+// every query on a connection.
+type LoggedDB struct {
+	db  DB
+	log *QueryLog
+}
+
+var _ DB = (*LoggedDB)(nil)
+var _ TxBeginner = (*LoggedDB)(nil)
+
+// Wrap returns a DB that logs the queries run on db.
+func (l *QueryLog) Wrap(db DB) *LoggedDB {
+	return &LoggedDB{
+		db:  db,
+		log: l,
+	}
+}
+
+func (d *LoggedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	d.log.record(ctx, query, args, start, err)
+	return rows, err
+}
+func (d *LoggedDB) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	start := time.Now()
+	rows, err := d.db.QueryxContext(ctx, query, args...)
+	d.log.record(ctx, query, args, start, err)
+	return rows, err
+}
+func (d *LoggedDB) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	start := time.Now()
+	row := d.db.QueryRowxContext(ctx, query, args...)
+	d.log.record(ctx, query, args, start, row.Err())
+	return row
+}
+func (d *LoggedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := d.db.ExecContext(ctx, query, args...)
+	d.log.record(ctx, query, args, start, err)
+	return result, err
+}
+
+func (d *LoggedDB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	beginner, ok := d.db.(TxBeginner)
+	if !ok {
+		return nil, fmt.Errorf("%T does not support transactions", d.db)
+	}
+	return beginner.BeginTxx(ctx, opts)
+}
@@ -0,0 +1,129 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/abibby/salusa/clog"
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/dialects/sqlite"
+	"github.com/abibby/salusa/di"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func logContext(t *testing.T) (context.Context, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	ctx := di.TestDependencyProviderContext()
+	err := clog.RegisterWith(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))(ctx)
+	assert.NoError(t, err)
+	return ctx, buf
+}
+
+func logMessages(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	messages := []map[string]any{}
+	d := json.NewDecoder(buf)
+	for d.More() {
+		m := map[string]any{}
+		assert.NoError(t, d.Decode(&m))
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+func openLogged(t *testing.T, ql *database.QueryLog) *sqlx.DB {
+	cfg := ql.Config(sqlite.NewConfig(":memory:"))
+	db := sqlx.MustOpen(cfg.DriverName(), cfg.DataSourceName())
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return db
+}
+
+func TestQueryLog(t *testing.T) {
+	t.Run("logs queries in transactions", func(t *testing.T) {
+		ctx, buf := logContext(t)
+		db := openLogged(t, &database.QueryLog{})
+
+		err := database.NewUpdate(ctx, nil, db)(func(tx *sqlx.Tx) error {
+			_, err := tx.ExecContext(ctx, "SELECT ?", 1)
+			return err
+		})
+		assert.NoError(t, err)
+
+		messages := logMessages(t, buf)
+		if assert.Len(t, messages, 1) {
+			assert.Equal(t, "query", messages[0]["msg"])
+			assert.Equal(t, "SELECT ?", messages[0]["query"])
+			assert.Equal(t, []any{1.0}, messages[0]["bindings"])
+			assert.Contains(t, messages[0], "duration")
+		}
+	})
+
+	t.Run("slow queries", func(t *testing.T) {
+		ctx, buf := logContext(t)
+		db := openLogged(t, &database.QueryLog{SlowThreshold: time.Nanosecond})
+
+		_, err := db.ExecContext(ctx, "SELECT 1")
+		assert.NoError(t, err)
+
+		messages := logMessages(t, buf)
+		if assert.Len(t, messages, 1) {
+			assert.Equal(t, "slow query", messages[0]["msg"])
+			assert.Equal(t, "WARN", messages[0]["level"])
+		}
+	})
+
+	t.Run("n+1", func(t *testing.T) {
+		ctx, buf := logContext(t)
+		ctx = database.WithQueryTracking(ctx)
+		db := openLogged(t, &database.QueryLog{NPlusOneThreshold: 3})
+
+		for i := range 4 {
+			_, err := db.ExecContext(ctx, "SELECT ? WHERE 1 IN (?, ?)", i, i, i)
+			assert.NoError(t, err)
+		}
+		_, err := db.ExecContext(ctx, "SELECT ? WHERE 1 IN (?)", 1, 1)
+		assert.NoError(t, err)
+
+		warnings := []map[string]any{}
+		for _, m := range logMessages(t, buf) {
+			if m["msg"] == "possible N+1 query" {
+				warnings = append(warnings, m)
+			}
+		}
+		if assert.Len(t, warnings, 1) {
+			assert.NotEmpty(t, warnings[0]["stack"])
+			assert.Contains(t, warnings[0]["stack"].([]any)[0], "TestQueryLog")
+		}
+	})
+
+	t.Run("no n+1 without tracking", func(t *testing.T) {
+		ctx, buf := logContext(t)
+		db := openLogged(t, &database.QueryLog{NPlusOneThreshold: 1})
+
+		_, err := db.ExecContext(ctx, "SELECT 1")
+		assert.NoError(t, err)
+
+		for _, m := range logMessages(t, buf) {
+			assert.NotEqual(t, "possible N+1 query", m["msg"])
+		}
+	})
+
+	t.Run("wrap", func(t *testing.T) {
+		ctx, buf := logContext(t)
+		db := (&database.QueryLog{}).Wrap(openNamed(t, "foo"))
+
+		assert.Equal(t, "foo", readName(t, ctx, db))
+
+		messages := logMessages(t, buf)
+		if assert.Len(t, messages, 1) {
+			assert.Equal(t, "SELECT name FROM t LIMIT 1", messages[0]["query"])
+		}
+	})
+}
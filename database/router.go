@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type TxBeginner interface {
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+// Router is a DB that sends SELECT queries and read only transactions to the
+// read replicas and everything else, including locking reads like SELECT ...
+// FOR UPDATE, to the primary. Once a write has been
+// sent in a context created with WithStickyReads, reads in that context are
+// also sent to the primary so they see the write.
+type Router struct {
+	name     string
+	primary  *sqlx.DB
+	replicas []*sqlx.DB
+	next     atomic.Uint32
+}
+
+var _ DB = (*Router)(nil)
+var _ TxBeginner = (*Router)(nil)
+
+func NewRouter(primary *sqlx.DB, replicas ...*sqlx.DB) *Router {
+	return &Router{
+		primary:  primary,
+		replicas: replicas,
+	}
+}
+
+// WithName sets the name of the connection the router is for. The name keeps
+// cached query results from different connections apart.
+func (r *Router) WithName(name string) *Router {
+	r.name = name
+	connectionNames.Store(r.primary, name)
+	for _, replica := range r.replicas {
+		connectionNames.Store(replica, name)
+	}
+	return r
+}
+
+// Name returns the name set with WithName.
+func (r *Router) Name() string {
+	return r.name
+}
+
+var connectionNames sync.Map
+
+// ConnectionName returns the name of the connection db is for. Routers and
+// their databases return the name set with Router.WithName, transactions
+// started by an Update or Read return the name of the database they were
+// started on and anything else returns a name unique to db.
+func ConnectionName(db DB) string {
+	switch db := db.(type) {
+	case *Router:
+		return db.Name()
+	case *sqlx.DB:
+		if name, ok := connectionNames.Load(db); ok {
+			return name.(string)
+		}
+	case *sqlx.Tx:
+		txStatesMtx.Lock()
+		state, ok := txStates[db]
+		txStatesMtx.Unlock()
+		if ok {
+			return state.connection
+		}
+	}
+	if reflect.ValueOf(db).Kind() == reflect.Pointer {
+		return fmt.Sprintf("%T:%p", db, db)
+	}
+	return fmt.Sprintf("%T", db)
+}
+
+// Primary returns the connection writes are sent to.
+func (r *Router) Primary() *sqlx.DB {
+	return r.primary
+}
+
+// Reader returns the connection reads in ctx should be sent to.
+func (r *Router) Reader(ctx context.Context) *sqlx.DB {
+	if len(r.replicas) == 0 || hasWritten(ctx) {
+		return r.primary
+	}
+	i := r.next.Add(1)
+	return r.replicas[int(i)%len(r.replicas)]
+}
+
+func (r *Router) route(ctx context.Context, query string) *sqlx.DB {
+	if isRead(query) {
+		return r.Reader(ctx)
+	}
+	markWritten(ctx)
+	return r.primary
+}
+
+func (r *Router) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.route(ctx, query).QueryContext(ctx, query, args...)
+}
+func (r *Router) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	return r.route(ctx, query).QueryxContext(ctx, query, args...)
+}
+func (r *Router) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	return r.route(ctx, query).QueryRowxContext(ctx, query, args...)
+}
+func (r *Router) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	markWritten(ctx)
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+func (r *Router) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	if opts != nil && opts.ReadOnly {
+		return r.Reader(ctx).BeginTxx(ctx, opts)
+	}
+	markWritten(ctx)
+	return r.primary.BeginTxx(ctx, opts)
+}
+
+// Close closes the primary and all replicas.
+func (r *Router) Close() error {
+	errs := []error{r.primary.Close()}
+	for _, replica := range r.replicas {
+		errs = append(errs, replica.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// lockingRead matches the row locking clauses of postgres and mysql, the rows
+// can only be locked on the primary.
+var lockingRead = regexp.MustCompile(`(?i)\bFOR\s+(NO\s+KEY\s+)?(UPDATE|KEY\s+SHARE|SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b`)
+
+func isRead(query string) bool {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	if len(query) < 6 || !strings.EqualFold(query[:6], "SELECT") {
+		return false
+	}
+	return !lockingRead.MatchString(query)
+}
+
+type stickyKey struct{}
+
+type stickyState struct {
+	written atomic.Bool
+}
+
+// WithStickyReads returns a context where reads made through a Router are sent
+// to the primary after the first write.
+func WithStickyReads(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(stickyKey{}).(*stickyState); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, stickyKey{}, &stickyState{})
+}
+
+func markWritten(ctx context.Context) {
+	if s, ok := ctx.Value(stickyKey{}).(*stickyState); ok {
+		s.written.Store(true)
+	}
+}
+
+func hasWritten(ctx context.Context) bool {
+	s, ok := ctx.Value(stickyKey{}).(*stickyState)
+	return ok && s.written.Load()
+}
@@ -0,0 +1,118 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/dialects/sqlite"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func openNamed(t *testing.T, name string) *sqlx.DB {
+	cfg := sqlite.NewConfig(":memory:")
+	db := sqlx.MustOpen(cfg.DriverName(), cfg.DataSourceName())
+	db.SetMaxOpenConns(1)
+	db.MustExec("CREATE TABLE t (name TEXT)")
+	db.MustExec("INSERT INTO t (name) VALUES (?)", name)
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return db
+}
+
+func readName(t *testing.T, ctx context.Context, db database.DB) string {
+	var name string
+	err := sqlx.GetContext(ctx, db, &name, "SELECT name FROM t LIMIT 1")
+	assert.NoError(t, err)
+	return name
+}
+
+func TestRouter(t *testing.T) {
+	t.Run("reads from replica", func(t *testing.T) {
+		r := database.NewRouter(openNamed(t, "primary"), openNamed(t, "replica"))
+		assert.Equal(t, "replica", readName(t, context.Background(), r))
+	})
+
+	t.Run("reads from primary without replicas", func(t *testing.T) {
+		r := database.NewRouter(openNamed(t, "primary"))
+		assert.Equal(t, "primary", readName(t, context.Background(), r))
+	})
+
+	t.Run("writes to primary", func(t *testing.T) {
+		primary := openNamed(t, "primary")
+		r := database.NewRouter(primary, openNamed(t, "replica"))
+		ctx := context.Background()
+
+		_, err := r.ExecContext(ctx, "UPDATE t SET name = ?", "updated")
+		assert.NoError(t, err)
+
+		assert.Equal(t, "updated", readName(t, ctx, primary))
+		assert.Equal(t, "replica", readName(t, ctx, r))
+	})
+
+	t.Run("sticky reads", func(t *testing.T) {
+		r := database.NewRouter(openNamed(t, "primary"), openNamed(t, "replica"))
+		ctx := database.WithStickyReads(context.Background())
+
+		assert.Equal(t, "replica", readName(t, ctx, r))
+
+		_, err := r.ExecContext(ctx, "UPDATE t SET name = ?", "updated")
+		assert.NoError(t, err)
+
+		assert.Equal(t, "updated", readName(t, ctx, r))
+		assert.Equal(t, "replica", readName(t, context.Background(), r))
+	})
+
+	t.Run("locking reads use primary", func(t *testing.T) {
+		for _, query := range []string{
+			"SELECT name FROM t FOR UPDATE",
+			"SELECT name FROM t for share",
+			"SELECT name FROM t FOR NO KEY UPDATE",
+			"SELECT name FROM t LOCK IN SHARE MODE",
+		} {
+			r := database.NewRouter(openNamed(t, "primary"), openNamed(t, "replica"))
+			ctx := database.WithStickyReads(context.Background())
+
+			// sqlite doesn't support locking reads, the error is ignored and
+			// the sticky read shows which connection the query was sent to.
+			_, _ = r.QueryContext(ctx, query)
+
+			assert.Equal(t, "primary", readName(t, ctx, r), query)
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		r := database.NewRouter(openNamed(t, "primary"), openNamed(t, "replica"))
+		ctx := database.WithStickyReads(context.Background())
+
+		err := database.NewRead(ctx, nil, r)(func(tx *sqlx.Tx) error {
+			assert.Equal(t, "replica", readName(t, ctx, tx))
+			return nil
+		})
+		assert.NoError(t, err)
+
+		err = database.NewUpdate(ctx, nil, r)(func(tx *sqlx.Tx) error {
+			assert.Equal(t, "primary", readName(t, ctx, tx))
+			return nil
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, "primary", readName(t, ctx, r))
+	})
+}
+
+func TestConnectionName(t *testing.T) {
+	primary := openNamed(t, "primary")
+	replica := openNamed(t, "replica")
+	r := database.NewRouter(primary, replica).WithName("reports")
+
+	assert.Equal(t, "reports", database.ConnectionName(r))
+	assert.Equal(t, "reports", database.ConnectionName(primary))
+	assert.Equal(t, "reports", database.ConnectionName(replica))
+
+	a := openNamed(t, "a")
+	b := openNamed(t, "b")
+	assert.NotEqual(t, database.ConnectionName(a), database.ConnectionName(b))
+}
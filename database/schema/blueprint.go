@@ -22,21 +22,43 @@ type Blueprinter interface {
 }
 
 type Blueprint struct {
-	name        string
-	columns     []*ColumnBuilder
-	dropColumns []string
-	indexes     []*IndexBuilder
-	foreignKeys []*ForeignKeyBuilder
-	primaryKeys []string
+	name            string
+	columns         []*ColumnBuilder
+	dropColumns     []string
+	indexes         []*IndexBuilder
+	foreignKeys     []*ForeignKeyBuilder
+	dropForeignKeys []string
+	checks          []*CheckBuilder
+	dropChecks      []string
+	primaryKeys     []string
 }
 
 func NewBlueprint(name string) *Blueprint {
 	return &Blueprint{
-		name:        name,
-		columns:     []*ColumnBuilder{},
-		dropColumns: []string{},
-		indexes:     []*IndexBuilder{},
-		foreignKeys: []*ForeignKeyBuilder{},
+		name:            name,
+		columns:         []*ColumnBuilder{},
+		dropColumns:     []string{},
+		indexes:         []*IndexBuilder{},
+		foreignKeys:     []*ForeignKeyBuilder{},
+		dropForeignKeys: []string{},
+		checks:          []*CheckBuilder{},
+		dropChecks:      []string{},
+	}
+}
+
+// Clone returns a copy of the blueprint that can be modified without changing
+// the original.
+func (b *Blueprint) Clone() *Blueprint {
+	return &Blueprint{
+		name:            b.name,
+		columns:         cloneSlice(b.columns),
+		dropColumns:     cloneSlice(b.dropColumns),
+		indexes:         cloneSlice(b.indexes),
+		foreignKeys:     cloneSlice(b.foreignKeys),
+		dropForeignKeys: cloneSlice(b.dropForeignKeys),
+		checks:          cloneSlice(b.checks),
+		dropChecks:      cloneSlice(b.dropChecks),
+		primaryKeys:     cloneSlice(b.primaryKeys),
 	}
 }
 
@@ -46,6 +68,28 @@ func (b *Blueprint) findColumn(name string) (*ColumnBuilder, bool) {
 	})
 }
 
+// isConstrained returns true if the column is part of a key, index or
+// constraint.
+func (b *Blueprint) isConstrained(column string) bool {
+	if c, ok := b.findColumn(column); ok && (c.primary || c.unique) {
+		return true
+	}
+	if slices.Has(b.primaryKeys, column) {
+		return true
+	}
+	for _, index := range b.indexes {
+		if slices.Has(index.columns, column) {
+			return true
+		}
+	}
+	for _, foreignKey := range b.foreignKeys {
+		if slices.Has(foreignKey.localKeys, column) {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *Blueprint) GetBlueprint() *Blueprint {
 	return t
 }
@@ -142,13 +186,39 @@ func (t *Blueprint) Index(name string) *IndexBuilder {
 	return c
 }
 
-func (t *Blueprint) ForeignKey(localKey, relatedTable, relatedKey string) {
-	f := &ForeignKeyBuilder{
-		localKey:     localKey,
-		relatedTable: relatedTable,
-		relatedKey:   relatedKey,
-	}
+// ForeignKey adds a foreign key constraint from localKey to relatedKey on
+// relatedTable.
+func (t *Blueprint) ForeignKey(localKey, relatedTable, relatedKey string) *ForeignKeyBuilder {
+	return t.Foreign(localKey).References(relatedKey).On(relatedTable)
+}
+
+// Foreign adds a foreign key constraint on one or more columns. The related
+// table and columns are set with On and References.
+func (t *Blueprint) Foreign(columns ...string) *ForeignKeyBuilder {
+	f := newForeignKeyBuilder(columns...)
 	t.foreignKeys = append(t.foreignKeys, f)
+	return f
+}
+
+// DropForeignKey removes the foreign key constraint with the given name.
+func (t *Blueprint) DropForeignKey(name string) {
+	t.dropForeignKeys = append(t.dropForeignKeys, name)
+}
+
+// Check adds a named check constraint. The expression is added to the query
+// without escaping.
+func (t *Blueprint) Check(name, expression string) *CheckBuilder {
+	c := &CheckBuilder{
+		name:       name,
+		expression: expression,
+	}
+	t.checks = append(t.checks, c)
+	return c
+}
+
+// DropCheck removes the check constraint with the given name.
+func (t *Blueprint) DropCheck(name string) {
+	t.dropChecks = append(t.dropChecks, name)
 }
 
 func (t *Blueprint) PrimaryKey(columns ...string) {
@@ -191,8 +261,20 @@ func (b *Blueprint) GoString() string {
 		src += fmt.Sprintf("\ttable.DropColumn(%#v)\n", c)
 	}
 
+	for _, name := range b.dropForeignKeys {
+		src += fmt.Sprintf("\ttable.DropForeignKey(%#v)\n", name)
+	}
+
 	for _, foreignKey := range b.foreignKeys {
-		src += fmt.Sprintf("\ttable.ForeignKey(%#v, %#v, %#v)\n", foreignKey.localKey, foreignKey.relatedTable, foreignKey.relatedKey)
+		src += fmt.Sprintf("\t%s\n", foreignKey.GoString())
+	}
+
+	for _, name := range b.dropChecks {
+		src += fmt.Sprintf("\ttable.DropCheck(%#v)\n", name)
+	}
+
+	for _, check := range b.checks {
+		src += fmt.Sprintf("\t%s\n", check.GoString())
 	}
 
 	if len(b.primaryKeys) > 1 {
@@ -230,7 +312,16 @@ func (t *Blueprint) Merge(newBlueprint *Blueprint) {
 		return !slices.Has(newBlueprint.dropColumns, c.name)
 	})
 
+	t.foreignKeys = slices.Filter(t.foreignKeys, func(f *ForeignKeyBuilder) bool {
+		return !slices.Has(newBlueprint.dropForeignKeys, f.GetName())
+	})
 	t.foreignKeys = append(t.foreignKeys, newBlueprint.foreignKeys...)
+
+	t.checks = slices.Filter(t.checks, func(c *CheckBuilder) bool {
+		return !slices.Has(newBlueprint.dropChecks, c.GetName())
+	})
+	t.checks = append(t.checks, newBlueprint.checks...)
+
 	t.indexes = append(t.indexes, newBlueprint.indexes...)
 	if newBlueprint.primaryKeys != nil {
 		t.primaryKeys = newBlueprint.primaryKeys
@@ -260,17 +351,42 @@ func (t *Blueprint) Update(oldBlueprint, newBlueprint *Blueprint) bool {
 		}
 	}
 
+	for _, oldKey := range oldBlueprint.foreignKeys {
+		_, ok := slices.Find(newBlueprint.foreignKeys, func(newKey *ForeignKeyBuilder) bool {
+			return newKey.Equals(oldKey)
+		})
+		if !ok {
+			t.DropForeignKey(oldKey.GetName())
+			hasChanges = true
+		}
+	}
 	for _, newKey := range newBlueprint.foreignKeys {
 		_, ok := slices.Find(oldBlueprint.foreignKeys, func(oldKey *ForeignKeyBuilder) bool {
-			return newKey.localKey == oldKey.localKey &&
-				newKey.relatedKey == oldKey.relatedKey &&
-				newKey.relatedTable == oldKey.relatedTable
+			return newKey.Equals(oldKey)
 		})
 		if !ok {
 			t.foreignKeys = append(t.foreignKeys, newKey)
 			hasChanges = true
 		}
 	}
+	for _, oldCheck := range oldBlueprint.checks {
+		_, ok := slices.Find(newBlueprint.checks, func(newCheck *CheckBuilder) bool {
+			return newCheck.Equals(oldCheck)
+		})
+		if !ok {
+			t.DropCheck(oldCheck.GetName())
+			hasChanges = true
+		}
+	}
+	for _, newCheck := range newBlueprint.checks {
+		_, ok := slices.Find(oldBlueprint.checks, func(oldCheck *CheckBuilder) bool {
+			return newCheck.Equals(oldCheck)
+		})
+		if !ok {
+			t.checks = append(t.checks, newCheck)
+			hasChanges = true
+		}
+	}
 	for _, newIndex := range newBlueprint.indexes {
 		_, ok := slices.Find(oldBlueprint.indexes, func(oldIndex *IndexBuilder) bool {
 			return newIndex.name == oldIndex.name
@@ -284,3 +400,12 @@ func (t *Blueprint) Update(oldBlueprint, newBlueprint *Blueprint) bool {
 	// TODO: add support for primary keys
 	return hasChanges
 }
+
+func cloneSlice[T any](arr []T) []T {
+	if arr == nil {
+		return nil
+	}
+	l := make([]T, len(arr))
+	copy(l, arr)
+	return l
+}
@@ -0,0 +1,37 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/abibby/salusa/database/dialects"
+	"github.com/abibby/salusa/internal/helpers"
+)
+
+type CheckBuilder struct {
+	name       string
+	expression string
+}
+
+var _ helpers.SQLStringer = &CheckBuilder{}
+
+func (b *CheckBuilder) GetName() string {
+	return b.name
+}
+
+func (b *CheckBuilder) Equals(newB *CheckBuilder) bool {
+	return b.name == newB.name &&
+		b.expression == newB.expression
+}
+
+func (b *CheckBuilder) SQLString(d dialects.Dialect) (string, []any, error) {
+	return helpers.Result().
+		AddString("CONSTRAINT").
+		Add(helpers.Identifier(b.name)).
+		AddString("CHECK").
+		Add(helpers.Group(helpers.Raw(b.expression))).
+		SQLString(d)
+}
+
+func (b *CheckBuilder) GoString() string {
+	return fmt.Sprintf("table.Check(%#v, %#v)", b.name, b.expression)
+}
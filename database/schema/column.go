@@ -20,6 +20,10 @@ type ColumnBuilder struct {
 	unique             bool
 	defaultCurrentTime bool
 	index              bool
+
+	// rawDefault is an SQL expression used as the default value for columns
+	// read from an existing database.
+	rawDefault string
 }
 
 func NewColumn(name string, datatype dialects.DataType) *ColumnBuilder {
@@ -111,6 +115,9 @@ func (b *ColumnBuilder) SQLString(d dialects.Dialect) (string, []any, error) {
 	} else if b.defaultCurrentTime {
 		r.AddString("DEFAULT").
 			AddString(d.CurrentTime())
+	} else if b.rawDefault != "" {
+		r.AddString("DEFAULT").
+			AddString(b.rawDefault)
 	}
 	return r.SQLString(d)
 }
@@ -61,6 +61,9 @@ func (b *CreateTableBuilder) SQLString(d dialects.Dialect) (string, []any, error
 		columns = append(columns, foreignKey)
 		// r.Add(builder.Concat(foreignKey, builder.Raw(";")))
 	}
+	for _, check := range b.blueprint.checks {
+		columns = append(columns, check)
+	}
 	r.Add(helpers.Concat(
 		helpers.Group(
 			helpers.Concat(
@@ -72,6 +72,24 @@ func TestBuilder(t *testing.T) {
 			ExpectedSQL:      "CREATE TABLE \"foo\" (\"id\" INTEGER NOT NULL, CONSTRAINT \"id-bar-foo_id\" FOREIGN KEY (\"id\") REFERENCES \"bar\"(\"foo_id\"));",
 			ExpectedBindings: []any{},
 		},
+		{
+			Name: "foreign key actions",
+			Builder: schema.Create("foo", func(table *schema.Blueprint) {
+				table.Int("id")
+				table.ForeignKey("id", "bar", "foo_id").CascadeOnDelete().CascadeOnUpdate()
+			}),
+			ExpectedSQL:      "CREATE TABLE \"foo\" (\"id\" INTEGER NOT NULL, CONSTRAINT \"id-bar-foo_id\" FOREIGN KEY (\"id\") REFERENCES \"bar\"(\"foo_id\") ON DELETE CASCADE ON UPDATE CASCADE);",
+			ExpectedBindings: []any{},
+		},
+		{
+			Name: "check",
+			Builder: schema.Create("foo", func(table *schema.Blueprint) {
+				table.Int("id")
+				table.Check("positive_id", "id > 0")
+			}),
+			ExpectedSQL:      "CREATE TABLE \"foo\" (\"id\" INTEGER NOT NULL, CONSTRAINT \"positive_id\" CHECK (id > 0));",
+			ExpectedBindings: []any{},
+		},
 		{
 			Name: "null",
 			Builder: schema.Create("foo", func(table *schema.Blueprint) {
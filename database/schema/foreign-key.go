@@ -1,31 +1,185 @@
 package schema
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/abibby/salusa/database/dialects"
 	"github.com/abibby/salusa/internal/helpers"
+	"github.com/abibby/salusa/slices"
+)
+
+// ForeignKeyAction is the referential action taken when a referenced row is
+// updated or deleted.
+type ForeignKeyAction string
+
+const (
+	ActionNoAction   = ForeignKeyAction("NO ACTION")
+	ActionRestrict   = ForeignKeyAction("RESTRICT")
+	ActionCascade    = ForeignKeyAction("CASCADE")
+	ActionSetNull    = ForeignKeyAction("SET NULL")
+	ActionSetDefault = ForeignKeyAction("SET DEFAULT")
 )
 
 type ForeignKeyBuilder struct {
+	name         string
+	localKeys    []string
 	relatedTable string
-	localKey     string
-	relatedKey   string
+	relatedKeys  []string
+	onDelete     ForeignKeyAction
+	onUpdate     ForeignKeyAction
+}
+
+var _ helpers.SQLStringer = &ForeignKeyBuilder{}
+
+func newForeignKeyBuilder(localKeys ...string) *ForeignKeyBuilder {
+	return &ForeignKeyBuilder{
+		localKeys:   localKeys,
+		relatedKeys: []string{},
+	}
+}
+
+// Name sets the name of the constraint. If no name is set one is generated
+// from the columns and related table.
+func (b *ForeignKeyBuilder) Name(name string) *ForeignKeyBuilder {
+	b.name = name
+	return b
+}
+
+// References sets the columns on the related table.
+func (b *ForeignKeyBuilder) References(columns ...string) *ForeignKeyBuilder {
+	b.relatedKeys = columns
+	return b
+}
+
+// On sets the related table.
+func (b *ForeignKeyBuilder) On(table string) *ForeignKeyBuilder {
+	b.relatedTable = table
+	return b
+}
+
+func (b *ForeignKeyBuilder) OnDelete(action ForeignKeyAction) *ForeignKeyBuilder {
+	b.onDelete = action
+	return b
+}
+func (b *ForeignKeyBuilder) OnUpdate(action ForeignKeyAction) *ForeignKeyBuilder {
+	b.onUpdate = action
+	return b
+}
+func (b *ForeignKeyBuilder) CascadeOnDelete() *ForeignKeyBuilder {
+	return b.OnDelete(ActionCascade)
+}
+func (b *ForeignKeyBuilder) NullOnDelete() *ForeignKeyBuilder {
+	return b.OnDelete(ActionSetNull)
+}
+func (b *ForeignKeyBuilder) RestrictOnDelete() *ForeignKeyBuilder {
+	return b.OnDelete(ActionRestrict)
+}
+func (b *ForeignKeyBuilder) CascadeOnUpdate() *ForeignKeyBuilder {
+	return b.OnUpdate(ActionCascade)
+}
+func (b *ForeignKeyBuilder) NullOnUpdate() *ForeignKeyBuilder {
+	return b.OnUpdate(ActionSetNull)
+}
+func (b *ForeignKeyBuilder) RestrictOnUpdate() *ForeignKeyBuilder {
+	return b.OnUpdate(ActionRestrict)
+}
+
+// GetName returns the name of the constraint.
+func (b *ForeignKeyBuilder) GetName() string {
+	if b.name != "" {
+		return b.name
+	}
+	return strings.Join(b.localKeys, "_") + "-" + b.relatedTable + "-" + strings.Join(b.relatedKeys, "_")
 }
 
-func (b *ForeignKeyBuilder) id() string {
-	return b.localKey + "-" + b.relatedTable + "-" + b.relatedKey
+func (b *ForeignKeyBuilder) Equals(newB *ForeignKeyBuilder) bool {
+	return b.GetName() == newB.GetName() &&
+		equalStrings(b.localKeys, newB.localKeys) &&
+		b.relatedTable == newB.relatedTable &&
+		equalStrings(b.relatedKeys, newB.relatedKeys) &&
+		b.onDelete == newB.onDelete &&
+		b.onUpdate == newB.onUpdate
 }
 
 func (b *ForeignKeyBuilder) SQLString(d dialects.Dialect) (string, []any, error) {
 	r := helpers.Result()
 
 	r.AddString("CONSTRAINT").
-		Add(helpers.Identifier(b.id())).
+		Add(helpers.Identifier(b.GetName())).
 		AddString("FOREIGN KEY").
-		Add(helpers.Group(helpers.Identifier(b.localKey))).
+		Add(helpers.Group(helpers.Join(helpers.IdentifierList(b.localKeys), ", "))).
 		AddString("REFERENCES").
 		Add(helpers.Concat(
 			helpers.Identifier(b.relatedTable),
-			helpers.Group(helpers.Identifier(b.relatedKey)),
+			helpers.Group(helpers.Join(helpers.IdentifierList(b.relatedKeys), ", ")),
 		))
+	if b.onDelete != "" {
+		r.AddString("ON DELETE " + string(b.onDelete))
+	}
+	if b.onUpdate != "" {
+		r.AddString("ON UPDATE " + string(b.onUpdate))
+	}
 	return r.SQLString(d)
 }
+
+func (b *ForeignKeyBuilder) GoString() string {
+	src := ""
+	if len(b.localKeys) == 1 && len(b.relatedKeys) == 1 {
+		src = fmt.Sprintf("table.ForeignKey(%#v, %#v, %#v)", b.localKeys[0], b.relatedTable, b.relatedKeys[0])
+	} else {
+		src = fmt.Sprintf(
+			"table.Foreign(%s).References(%s).On(%#v)",
+			goStringList(b.localKeys),
+			goStringList(b.relatedKeys),
+			b.relatedTable,
+		)
+	}
+	if b.name != "" {
+		src += fmt.Sprintf(".Name(%#v)", b.name)
+	}
+	if b.onDelete != "" {
+		src += fmt.Sprintf(".OnDelete(%s)", goStringAction(b.onDelete))
+	}
+	if b.onUpdate != "" {
+		src += fmt.Sprintf(".OnUpdate(%s)", goStringAction(b.onUpdate))
+	}
+	return src
+}
+
+func goStringAction(a ForeignKeyAction) string {
+	switch a {
+	case ActionNoAction:
+		return "schema.ActionNoAction"
+	case ActionRestrict:
+		return "schema.ActionRestrict"
+	case ActionCascade:
+		return "schema.ActionCascade"
+	case ActionSetNull:
+		return "schema.ActionSetNull"
+	case ActionSetDefault:
+		return "schema.ActionSetDefault"
+	}
+	return fmt.Sprintf("schema.ForeignKeyAction(%#v)", string(a))
+}
+
+func goStringList(strs []string) string {
+	return strings.Join(
+		slices.Map(strs, func(s string) string {
+			return fmt.Sprintf("%#v", s)
+		}),
+		", ",
+	)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
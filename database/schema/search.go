@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"context"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/dialects"
+	"github.com/abibby/salusa/internal/helpers"
+)
+
+// CreateSearchIndex creates a full text index over columns using the dialects
+// full text search. On SQLite this creates a separate FTS5 table that is
+// filled with the existing rows. FTS5 is not compiled into
+// github.com/mattn/go-sqlite3 by default, build with the sqlite_fts5 tag to
+// enable it.
+func CreateSearchIndex(table, key string, columns ...string) Runner {
+	return Run(func(ctx context.Context, tx database.DB) error {
+		searcher, ok := dialects.New().(dialects.Searcher)
+		if !ok {
+			return dialects.ErrSearchNotSupported
+		}
+		return runQueries(ctx, tx, searcher.CreateSearchIndex(table, key, columns))
+	})
+}
+
+// DropSearchIndex removes the full text index created by CreateSearchIndex.
+func DropSearchIndex(table string) Runner {
+	return Run(func(ctx context.Context, tx database.DB) error {
+		searcher, ok := dialects.New().(dialects.Searcher)
+		if !ok {
+			return dialects.ErrSearchNotSupported
+		}
+		return runQueries(ctx, tx, searcher.DropSearchIndex(table))
+	})
+}
+
+func runQueries(ctx context.Context, tx database.DB, queries []string) error {
+	for _, q := range queries {
+		err := runQuery(ctx, tx, helpers.Raw(q))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
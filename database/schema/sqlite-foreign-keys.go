@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/abibby/salusa/database"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrForeignKeysEnabled  = errors.New("sqlite foreign keys must be disabled outside of the transaction to rebuild a table")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+)
+
+type foreignKeyViolation struct {
+	Table  string `db:"table"`
+	RowID  *int64 `db:"rowid"`
+	Parent string `db:"parent"`
+	FKID   int    `db:"fkid"`
+}
+
+// ForeignKeysEnabled reports if SQLite is enforcing foreign keys on the
+// connection.
+func ForeignKeysEnabled(ctx context.Context, db database.DB) (bool, error) {
+	enabled := 0
+	err := sqlx.GetContext(ctx, db, &enabled, "PRAGMA foreign_keys")
+	if err != nil {
+		return false, fmt.Errorf("failed to read foreign_keys pragma: %w", err)
+	}
+	return enabled == 1, nil
+}
+
+// DisableForeignKeys turns off SQLite foreign key enforcement on the
+// connection and returns a function that restores the previous setting. It
+// has no effect inside a transaction so db must be a single connection, not a
+// pool or transaction.
+func DisableForeignKeys(ctx context.Context, db database.DB) (func() error, error) {
+	enabled, err := ForeignKeysEnabled(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return func() error { return nil }, nil
+	}
+	_, err = db.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := db.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+		return err
+	}, nil
+}
+
+// CheckForeignKeys returns an ErrForeignKeyViolation if any row references a
+// row that doesn't exist.
+func CheckForeignKeys(ctx context.Context, db database.DB) error {
+	violations := []*foreignKeyViolation{}
+	err := sqlx.SelectContext(ctx, db, &violations, "PRAGMA foreign_key_check")
+	if err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	v := violations[0]
+	return fmt.Errorf("%w: %d row(s), first in %s references a missing row in %s", ErrForeignKeyViolation, len(violations), v.Table, v.Parent)
+}
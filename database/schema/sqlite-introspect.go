@@ -0,0 +1,171 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/dialects"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrTableNotFound = errors.New("table not found")
+
+	sqliteCheckRegexp         = regexp.MustCompile(`(?i)\bCHECK\b`)
+	sqliteAutoIncrementRegexp = regexp.MustCompile(`(?i)\bAUTOINCREMENT\b`)
+)
+
+type sqliteColumn struct {
+	CID     int     `db:"cid"`
+	Name    string  `db:"name"`
+	Type    string  `db:"type"`
+	NotNull bool    `db:"notnull"`
+	Default *string `db:"dflt_value"`
+	PK      int     `db:"pk"`
+}
+
+type sqliteForeignKey struct {
+	ID       int     `db:"id"`
+	Seq      int     `db:"seq"`
+	Table    string  `db:"table"`
+	From     string  `db:"from"`
+	To       *string `db:"to"`
+	OnUpdate string  `db:"on_update"`
+	OnDelete string  `db:"on_delete"`
+	Match    string  `db:"match"`
+}
+
+type sqliteIndex struct {
+	Seq     int    `db:"seq"`
+	Name    string `db:"name"`
+	Unique  bool   `db:"unique"`
+	Origin  string `db:"origin"`
+	Partial bool   `db:"partial"`
+}
+
+type sqliteIndexColumn struct {
+	SeqNo int     `db:"seqno"`
+	CID   int     `db:"cid"`
+	Name  *string `db:"name"`
+}
+
+// sqliteBlueprint reads the schema of an existing SQLite table. Check
+// constraints can't be read back from SQLite so tables that have them return
+// an error.
+func sqliteBlueprint(ctx context.Context, tx database.DB, table string) (*Blueprint, error) {
+	var createSQL string
+	err := sqlx.GetContext(ctx, tx, &createSQL, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", table, ErrTableNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read table %s: %w", table, err)
+	}
+	if sqliteCheckRegexp.MatchString(createSQL) {
+		return nil, fmt.Errorf("table %s has check constraints which can't be read from the database: %w", table, ErrMissingCurrentBlueprint)
+	}
+
+	b := NewBlueprint(table)
+
+	columns := []*sqliteColumn{}
+	err = sqlx.SelectContext(ctx, tx, &columns, "SELECT cid, name, type, \"notnull\", dflt_value, pk FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	primaryColumns := []*sqliteColumn{}
+	for _, c := range columns {
+		column := b.OfType(dialects.DataType(c.Type), c.Name)
+		if !c.NotNull {
+			column.Nullable()
+		}
+		if c.Default != nil {
+			if *c.Default == "CURRENT_TIMESTAMP" {
+				column.DefaultCurrentTime()
+			} else {
+				column.rawDefault = *c.Default
+			}
+		}
+		if c.PK > 0 {
+			primaryColumns = append(primaryColumns, c)
+		}
+	}
+	sort.Slice(primaryColumns, func(i, j int) bool {
+		return primaryColumns[i].PK < primaryColumns[j].PK
+	})
+	if len(primaryColumns) == 1 {
+		column, _ := b.findColumn(primaryColumns[0].Name)
+		column.Primary()
+		if sqliteAutoIncrementRegexp.MatchString(createSQL) {
+			column.AutoIncrement()
+		}
+	} else if len(primaryColumns) > 1 {
+		pKeys := make([]string, len(primaryColumns))
+		for i, c := range primaryColumns {
+			pKeys[i] = c.Name
+		}
+		b.PrimaryKey(pKeys...)
+	}
+
+	foreignKeys := []*sqliteForeignKey{}
+	err = sqlx.SelectContext(ctx, tx, &foreignKeys, "SELECT * FROM pragma_foreign_key_list(?) ORDER BY id, seq", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys of %s: %w", table, err)
+	}
+	var foreignKey *ForeignKeyBuilder
+	lastID := -1
+	for _, fk := range foreignKeys {
+		if fk.ID != lastID {
+			lastID = fk.ID
+			foreignKey = b.Foreign().On(fk.Table)
+			if fk.OnDelete != string(ActionNoAction) {
+				foreignKey.OnDelete(ForeignKeyAction(fk.OnDelete))
+			}
+			if fk.OnUpdate != string(ActionNoAction) {
+				foreignKey.OnUpdate(ForeignKeyAction(fk.OnUpdate))
+			}
+		}
+		foreignKey.localKeys = append(foreignKey.localKeys, fk.From)
+		if fk.To != nil {
+			foreignKey.relatedKeys = append(foreignKey.relatedKeys, *fk.To)
+		}
+	}
+
+	indexes := []*sqliteIndex{}
+	err = sqlx.SelectContext(ctx, tx, &indexes, "SELECT * FROM pragma_index_list(?) ORDER BY seq DESC", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexes of %s: %w", table, err)
+	}
+	for _, index := range indexes {
+		if index.Origin == "pk" {
+			continue
+		}
+		indexColumns := []*sqliteIndexColumn{}
+		err = sqlx.SelectContext(ctx, tx, &indexColumns, "SELECT seqno, cid, name FROM pragma_index_info(?) ORDER BY seqno", index.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read index %s: %w", index.Name, err)
+		}
+		if index.Origin == "u" {
+			if len(indexColumns) == 1 && indexColumns[0].Name != nil {
+				if column, ok := b.findColumn(*indexColumns[0].Name); ok {
+					column.Unique()
+				}
+			}
+			continue
+		}
+		i := b.Index(index.Name)
+		if index.Unique {
+			i.Unique()
+		}
+		for _, c := range indexColumns {
+			if c.Name != nil {
+				i.AddColumn(*c.Name)
+			}
+		}
+	}
+
+	return b, nil
+}
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/abibby/salusa/database"
@@ -9,8 +10,13 @@ import (
 	"github.com/abibby/salusa/internal/helpers"
 )
 
+var (
+	ErrMissingCurrentBlueprint = errors.New("the current table blueprint is required to rebuild the table")
+)
+
 type UpdateTableBuilder struct {
 	blueprint *Blueprint
+	current   *Blueprint
 }
 
 var _ helpers.SQLStringer = &UpdateTableBuilder{}
@@ -31,9 +37,65 @@ func (b *UpdateTableBuilder) Type() BlueprintType {
 	return BlueprintTypeUpdate
 }
 
+// Current sets the blueprint of the table before this update is applied. It is
+// required for dialects that have to rebuild the table to apply the update.
+func (b *UpdateTableBuilder) Current(current *Blueprint) *UpdateTableBuilder {
+	b.current = current
+	return b
+}
+
+func (b *UpdateTableBuilder) needsRebuild(d dialects.Dialect) bool {
+	if !d.AlterConstraints() {
+		if len(b.blueprint.foreignKeys) > 0 ||
+			len(b.blueprint.dropForeignKeys) > 0 ||
+			len(b.blueprint.checks) > 0 ||
+			len(b.blueprint.dropChecks) > 0 {
+			return true
+		}
+	}
+	if !d.AlterColumns() {
+		if len(b.blueprint.primaryKeys) > 0 {
+			return true
+		}
+		for _, column := range b.blueprint.columns {
+			if column.change {
+				return true
+			}
+		}
+		if b.current != nil {
+			for _, column := range b.blueprint.dropColumns {
+				if b.current.isConstrained(column) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 func (b *UpdateTableBuilder) SQLString(d dialects.Dialect) (string, []any, error) {
+	if b.needsRebuild(d) {
+		return b.rebuildSQLString(d)
+	}
+
 	r := helpers.Result()
 	alterTable := helpers.Concat(helpers.Raw("ALTER TABLE "), helpers.Identifier(b.blueprint.name))
+	for _, name := range b.blueprint.dropForeignKeys {
+		r.Add(helpers.Concat(
+			alterTable,
+			helpers.Raw(" "+d.DropForeignKey()+" "),
+			helpers.Identifier(name),
+			helpers.Raw(";"),
+		))
+	}
+	for _, name := range b.blueprint.dropChecks {
+		r.Add(helpers.Concat(
+			alterTable,
+			helpers.Raw(" "+d.DropCheck()+" "),
+			helpers.Identifier(name),
+			helpers.Raw(";"),
+		))
+	}
 	for _, column := range b.blueprint.dropColumns {
 		r.Add(helpers.Concat(
 			alterTable,
@@ -67,6 +129,14 @@ func (b *UpdateTableBuilder) SQLString(d dialects.Dialect) (string, []any, error
 			helpers.Raw(";"),
 		))
 	}
+	for _, check := range b.blueprint.checks {
+		r.Add(helpers.Concat(
+			alterTable,
+			helpers.Raw(" ADD "),
+			check,
+			helpers.Raw(";"),
+		))
+	}
 	for _, index := range b.blueprint.indexes {
 		r.Add(helpers.Concat(index, helpers.Raw(";")))
 	}
@@ -74,6 +144,66 @@ func (b *UpdateTableBuilder) SQLString(d dialects.Dialect) (string, []any, error
 	return r.SQLString(d)
 }
 
+// rebuildSQLString creates a new table with the updated schema, copies the
+// data from the old table, then replaces the old table with the new one.
+// Dropping the old table deletes its rows, so foreign keys must be disabled
+// or ON DELETE actions will run on the tables that reference it.
+func (b *UpdateTableBuilder) rebuildSQLString(d dialects.Dialect) (string, []any, error) {
+	if b.current == nil || len(b.current.columns) == 0 {
+		return "", nil, fmt.Errorf("%s: %w", b.blueprint.name, ErrMissingCurrentBlueprint)
+	}
+
+	name := b.blueprint.name
+	tempName := name + "_rebuild"
+
+	newTable := b.current.Clone()
+	newTable.Merge(b.blueprint)
+
+	copyColumns := []string{}
+	for _, c := range newTable.columns {
+		if _, ok := b.current.findColumn(c.name); ok {
+			copyColumns = append(copyColumns, c.name)
+		}
+	}
+
+	tempTable := newTable.Clone()
+	tempTable.name = tempName
+	tempTable.indexes = []*IndexBuilder{}
+
+	r := helpers.Result().
+		Add(&CreateTableBuilder{blueprint: tempTable})
+
+	if len(copyColumns) > 0 {
+		columns := helpers.Join(helpers.IdentifierList(copyColumns), ", ")
+		r.Add(helpers.Concat(
+			helpers.Raw("INSERT INTO "),
+			helpers.Identifier(tempName),
+			helpers.Raw(" "),
+			helpers.Group(columns),
+			helpers.Raw(" SELECT "),
+			columns,
+			helpers.Raw(" FROM "),
+			helpers.Identifier(name),
+			helpers.Raw(";"),
+		))
+	}
+
+	r.Add(helpers.Concat(helpers.Raw("DROP TABLE "), helpers.Identifier(name), helpers.Raw(";"))).
+		Add(helpers.Concat(
+			helpers.Raw("ALTER TABLE "),
+			helpers.Identifier(tempName),
+			helpers.Raw(" RENAME TO "),
+			helpers.Identifier(name),
+			helpers.Raw(";"),
+		))
+
+	for _, index := range newTable.indexes {
+		r.Add(helpers.Concat(index, helpers.Raw(";")))
+	}
+
+	return r.SQLString(d)
+}
+
 func (b *UpdateTableBuilder) GoString() string {
 	return fmt.Sprintf(
 		"schema.Table(%#v, %#v)",
@@ -82,6 +212,40 @@ func (b *UpdateTableBuilder) GoString() string {
 	)
 }
 
+// Run applies the update to the database. If the table must be rebuilt and no
+// current blueprint has been set the current schema is read from the database.
+//
+// Rebuilding a SQLite table requires foreign keys to be disabled, see
+// DisableForeignKeys, and the foreign keys are checked after the rebuild.
 func (b *UpdateTableBuilder) Run(ctx context.Context, tx database.DB) error {
-	return runQuery(ctx, tx, b)
+	d := dialects.New()
+	if d.AlterColumns() {
+		return runQuery(ctx, tx, b)
+	}
+	if b.current == nil && (b.needsRebuild(d) || len(b.blueprint.dropColumns) > 0) {
+		current, err := sqliteBlueprint(ctx, tx, b.blueprint.name)
+		if err != nil {
+			return err
+		}
+		b = &UpdateTableBuilder{
+			blueprint: b.blueprint,
+			current:   current,
+		}
+	}
+	if !b.needsRebuild(d) {
+		return runQuery(ctx, tx, b)
+	}
+
+	enabled, err := ForeignKeysEnabled(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return fmt.Errorf("%s: %w", b.blueprint.name, ErrForeignKeysEnabled)
+	}
+	err = runQuery(ctx, tx, b)
+	if err != nil {
+		return err
+	}
+	return CheckForeignKeys(ctx, tx)
 }
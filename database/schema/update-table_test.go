@@ -1,10 +1,17 @@
 package schema_test
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
 
+	"github.com/abibby/salusa/database/dialects/mysql"
+	"github.com/abibby/salusa/database/dialects/postgres"
+	"github.com/abibby/salusa/database/dialects/sqlite"
 	"github.com/abibby/salusa/database/schema"
 	"github.com/abibby/salusa/internal/test"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestUpdateTable(t *testing.T) {
@@ -30,6 +37,37 @@ func TestUpdateTable(t *testing.T) {
 			}),
 			ExpectedSQL:      "ALTER TABLE \"foo\" MODIFY COLUMN \"id\" INTEGER NOT NULL;",
 			ExpectedBindings: []any{},
+			// sqlite rebuilds the table, see "change column sqlite"
+			Dialect: &postgres.Posgtgres{},
+		},
+		{
+			Name: "change column mysql",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.Int("id").Change()
+			}),
+			ExpectedSQL:      "ALTER TABLE `foo` MODIFY COLUMN `id` INT NOT NULL;",
+			ExpectedBindings: []any{},
+			Dialect:          &mysql.MySQL{},
+		},
+		{
+			Name: "change column sqlite",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.Int("id").Nullable().Change()
+			}).Current(schema.Create("foo", func(table *schema.Blueprint) {
+				table.String("id")
+				table.String("name")
+			}).GetBlueprint()),
+			ExpectedSQL:      "CREATE TABLE \"foo_rebuild\" (\"id\" INTEGER, \"name\" TEXT NOT NULL); INSERT INTO \"foo_rebuild\" (\"id\", \"name\") SELECT \"id\", \"name\" FROM \"foo\"; DROP TABLE \"foo\"; ALTER TABLE \"foo_rebuild\" RENAME TO \"foo\";",
+			ExpectedBindings: []any{},
+		},
+		{
+			Name: "change column sqlite without current",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.Int("id").Change()
+			}),
+			ExpectedSQL:      "",
+			ExpectedBindings: nil,
+			ExpectedError:    schema.ErrMissingCurrentBlueprint,
 		},
 		{
 			Name: "drop column",
@@ -46,6 +84,8 @@ func TestUpdateTable(t *testing.T) {
 			}),
 			ExpectedSQL:      "ALTER TABLE \"foo\" ADD CONSTRAINT \"id-bar-foo_id\" FOREIGN KEY (\"id\") REFERENCES \"bar\"(\"foo_id\");",
 			ExpectedBindings: []any{},
+			// sqlite rebuilds the table, see "add foreign key sqlite"
+			Dialect: &postgres.Posgtgres{},
 		},
 		// {
 		// 	Name: "drop foreign key",
@@ -55,6 +95,110 @@ func TestUpdateTable(t *testing.T) {
 		// 	ExpectedSQL:      "",
 		// 	ExpectedBindings: []any{},
 		// },
+		{
+			Name: "add foreign key sqlite",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.ForeignKey("id", "bar", "foo_id")
+			}).Current(schema.Create("foo", func(table *schema.Blueprint) {
+				table.Int("id")
+			}).GetBlueprint()),
+			ExpectedSQL:      "CREATE TABLE \"foo_rebuild\" (\"id\" INTEGER NOT NULL, CONSTRAINT \"id-bar-foo_id\" FOREIGN KEY (\"id\") REFERENCES \"bar\"(\"foo_id\")); INSERT INTO \"foo_rebuild\" (\"id\") SELECT \"id\" FROM \"foo\"; DROP TABLE \"foo\"; ALTER TABLE \"foo_rebuild\" RENAME TO \"foo\";",
+			ExpectedBindings: []any{},
+		},
+		{
+			Name: "add foreign key with empty current",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.ForeignKey("id", "bar", "foo_id")
+			}).Current(schema.NewBlueprint("foo")),
+			ExpectedSQL:      "",
+			ExpectedBindings: nil,
+			ExpectedError:    schema.ErrMissingCurrentBlueprint,
+		},
+		{
+			Name: "add foreign key mysql",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.ForeignKey("id", "bar", "foo_id")
+			}),
+			ExpectedSQL:      "ALTER TABLE `foo` ADD CONSTRAINT `id-bar-foo_id` FOREIGN KEY (`id`) REFERENCES `bar`(`foo_id`);",
+			ExpectedBindings: []any{},
+			Dialect:          &mysql.MySQL{},
+		},
+		{
+			Name: "foreign key actions",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.ForeignKey("bar_id", "bar", "id").CascadeOnDelete().NullOnUpdate()
+			}),
+			ExpectedSQL:      "ALTER TABLE `foo` ADD CONSTRAINT `bar_id-bar-id` FOREIGN KEY (`bar_id`) REFERENCES `bar`(`id`) ON DELETE CASCADE ON UPDATE SET NULL;",
+			ExpectedBindings: []any{},
+			Dialect:          &mysql.MySQL{},
+		},
+		{
+			Name: "composite foreign key",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.Foreign("a", "b").References("x", "y").On("bar").Name("foo_bar").RestrictOnDelete()
+			}),
+			ExpectedSQL:      "ALTER TABLE \"foo\" ADD CONSTRAINT \"foo_bar\" FOREIGN KEY (\"a\", \"b\") REFERENCES \"bar\"(\"x\", \"y\") ON DELETE RESTRICT;",
+			ExpectedBindings: []any{},
+			Dialect:          &postgres.Posgtgres{},
+		},
+		{
+			Name: "drop foreign key mysql",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.DropForeignKey("id-bar-foo_id")
+			}),
+			ExpectedSQL:      "ALTER TABLE `foo` DROP FOREIGN KEY `id-bar-foo_id`;",
+			ExpectedBindings: []any{},
+			Dialect:          &mysql.MySQL{},
+		},
+		{
+			Name: "drop foreign key postgres",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.DropForeignKey("id-bar-foo_id")
+			}),
+			ExpectedSQL:      "ALTER TABLE \"foo\" DROP CONSTRAINT \"id-bar-foo_id\";",
+			ExpectedBindings: []any{},
+			Dialect:          &postgres.Posgtgres{},
+		},
+		{
+			Name: "drop foreign key sqlite",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.DropForeignKey("bar_id-bar-id")
+			}).Current(schema.Create("foo", func(table *schema.Blueprint) {
+				table.Int("id")
+				table.Int("bar_id")
+				table.ForeignKey("bar_id", "bar", "id")
+				table.Index("foo-bar_id").AddColumn("bar_id")
+			}).GetBlueprint()),
+			ExpectedSQL:      "CREATE TABLE \"foo_rebuild\" (\"id\" INTEGER NOT NULL, \"bar_id\" INTEGER NOT NULL); INSERT INTO \"foo_rebuild\" (\"id\", \"bar_id\") SELECT \"id\", \"bar_id\" FROM \"foo\"; DROP TABLE \"foo\"; ALTER TABLE \"foo_rebuild\" RENAME TO \"foo\"; CREATE INDEX IF NOT EXISTS \"foo-bar_id\" ON \"foo\" (\"bar_id\");",
+			ExpectedBindings: []any{},
+		},
+		{
+			Name: "add check mysql",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.Check("positive_id", "id > 0")
+			}),
+			ExpectedSQL:      "ALTER TABLE `foo` ADD CONSTRAINT `positive_id` CHECK (id > 0);",
+			ExpectedBindings: []any{},
+			Dialect:          &mysql.MySQL{},
+		},
+		{
+			Name: "drop check mysql",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.DropCheck("positive_id")
+			}),
+			ExpectedSQL:      "ALTER TABLE `foo` DROP CHECK `positive_id`;",
+			ExpectedBindings: []any{},
+			Dialect:          &mysql.MySQL{},
+		},
+		{
+			Name: "drop check postgres",
+			Builder: schema.Table("foo", func(table *schema.Blueprint) {
+				table.DropCheck("positive_id")
+			}),
+			ExpectedSQL:      "ALTER TABLE \"foo\" DROP CONSTRAINT \"positive_id\";",
+			ExpectedBindings: []any{},
+			Dialect:          &postgres.Posgtgres{},
+		},
 		{
 			Name: "add index",
 			Builder: schema.Table("foo", func(table *schema.Blueprint) {
@@ -73,3 +217,138 @@ func TestUpdateTable(t *testing.T) {
 		// },
 	})
 }
+
+func TestUpdateTableRebuildSQLite(t *testing.T) {
+	test.Run(t, "", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		create := schema.Create("parents", func(table *schema.Blueprint) {
+			table.Int("id").Primary()
+		})
+		err := create.Run(ctx, tx)
+		assert.NoError(t, err)
+
+		create = schema.Create("children", func(table *schema.Blueprint) {
+			table.Int("id").Primary()
+			table.Int("parent_id")
+		})
+		err = create.Run(ctx, tx)
+		assert.NoError(t, err)
+
+		_, err = tx.Exec("INSERT INTO parents (id) VALUES (1); INSERT INTO children (id, parent_id) VALUES (1, 1)")
+		assert.NoError(t, err)
+
+		err = schema.Table("children", func(table *schema.Blueprint) {
+			table.ForeignKey("parent_id", "parents", "id").CascadeOnDelete()
+			table.Check("positive_id", "id > 0")
+		}).Current(create.GetBlueprint()).Run(ctx, tx)
+		assert.NoError(t, err)
+
+		count := 0
+		err = tx.Get(&count, "SELECT count(*) FROM children")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		_, err = tx.Exec("INSERT INTO children (id, parent_id) VALUES (-1, 1)")
+		assert.Error(t, err)
+	})
+}
+
+func TestUpdateTableRebuildSQLiteIntrospect(t *testing.T) {
+	test.Run(t, "", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		err := schema.Create("parents", func(table *schema.Blueprint) {
+			table.Int("id").Primary().AutoIncrement()
+		}).Run(ctx, tx)
+		assert.NoError(t, err)
+
+		err = schema.Create("children", func(table *schema.Blueprint) {
+			table.Int("id").Primary().AutoIncrement()
+			table.Int("parent_id")
+			table.String("name").Default("none")
+			table.String("code").Unique()
+			table.ForeignKey("parent_id", "parents", "id").CascadeOnDelete()
+			table.Index("children-name").AddColumn("name")
+		}).Run(ctx, tx)
+		assert.NoError(t, err)
+
+		_, err = tx.Exec("INSERT INTO parents (id) VALUES (1); INSERT INTO children (parent_id, name, code) VALUES (1, 'a', 'a')")
+		assert.NoError(t, err)
+
+		err = schema.Table("children", func(table *schema.Blueprint) {
+			table.Int("name").Nullable().Change()
+		}).Run(ctx, tx)
+		assert.NoError(t, err)
+
+		createSQL := ""
+		err = tx.Get(&createSQL, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'children'")
+		assert.NoError(t, err)
+		assert.Equal(t, "CREATE TABLE \"children\" (\"id\" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL, \"parent_id\" INTEGER NOT NULL, \"name\" INTEGER, \"code\" TEXT NOT NULL UNIQUE, CONSTRAINT \"parent_id-parents-id\" FOREIGN KEY (\"parent_id\") REFERENCES \"parents\"(\"id\") ON DELETE CASCADE)", createSQL)
+
+		indexCount := 0
+		err = tx.Get(&indexCount, "SELECT count(*) FROM sqlite_master WHERE type = 'index' AND name = 'children-name'")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, indexCount)
+
+		names := []*string{}
+		err = tx.Select(&names, "SELECT name FROM children")
+		assert.NoError(t, err)
+		if assert.Len(t, names, 1) {
+			assert.Equal(t, "a", *names[0])
+		}
+	})
+}
+
+func TestUpdateTableRebuildSQLiteForeignKeysEnabled(t *testing.T) {
+	sqlite.UseSQLite()
+	db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite")+"?_foreign_keys=on")
+	assert.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	parents := schema.Create("parents", func(table *schema.Blueprint) {
+		table.Int("id").Primary()
+		table.String("name")
+	})
+	assert.NoError(t, parents.Run(ctx, db))
+	assert.NoError(t, schema.Create("children", func(table *schema.Blueprint) {
+		table.Int("id").Primary()
+		table.Int("parent_id")
+		table.ForeignKey("parent_id", "parents", "id").CascadeOnDelete()
+	}).Run(ctx, db))
+	_, err = db.Exec("INSERT INTO parents (id, name) VALUES (1, 'a'); INSERT INTO children (id, parent_id) VALUES (1, 1)")
+	assert.NoError(t, err)
+
+	tx, err := db.Beginx()
+	assert.NoError(t, err)
+	err = schema.Table("parents", func(table *schema.Blueprint) {
+		table.String("name").Nullable().Change()
+	}).Current(parents.GetBlueprint()).Run(ctx, tx)
+	assert.ErrorIs(t, err, schema.ErrForeignKeysEnabled)
+	assert.NoError(t, tx.Rollback())
+
+	count := 0
+	assert.NoError(t, db.Get(&count, "SELECT count(*) FROM children"))
+	assert.Equal(t, 1, count)
+}
+
+func TestCheckForeignKeys(t *testing.T) {
+	test.Run(t, "", func(t *testing.T, tx *sqlx.Tx) {
+		ctx := context.Background()
+		assert.NoError(t, schema.Create("parents", func(table *schema.Blueprint) {
+			table.Int("id").Primary()
+		}).Run(ctx, tx))
+		assert.NoError(t, schema.Create("children", func(table *schema.Blueprint) {
+			table.Int("id").Primary()
+			table.Int("parent_id")
+			table.ForeignKey("parent_id", "parents", "id")
+		}).Run(ctx, tx))
+
+		_, err := tx.Exec("INSERT INTO parents (id) VALUES (1); INSERT INTO children (id, parent_id) VALUES (1, 1)")
+		assert.NoError(t, err)
+		assert.NoError(t, schema.CheckForeignKeys(ctx, tx))
+
+		_, err = tx.Exec("INSERT INTO children (id, parent_id) VALUES (2, 2)")
+		assert.NoError(t, err)
+		assert.ErrorIs(t, schema.CheckForeignKeys(ctx, tx), schema.ErrForeignKeyViolation)
+	})
+}
@@ -0,0 +1,44 @@
+package seed
+
+import (
+	"context"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/database/model"
+)
+
+type funcSeeder struct {
+	name         string
+	run          func(ctx context.Context, tx database.DB) error
+	dependencies []string
+}
+
+var _ DependentSeeder = (*funcSeeder)(nil)
+
+// Func creates a seeder from a function.
+func Func(name string, run func(ctx context.Context, tx database.DB) error, dependencies ...string) DependentSeeder {
+	return &funcSeeder{
+		name:         name,
+		run:          run,
+		dependencies: dependencies,
+	}
+}
+
+func (s *funcSeeder) Name() string {
+	return s.name
+}
+func (s *funcSeeder) Run(ctx context.Context, tx database.DB) error {
+	return s.run(ctx, tx)
+}
+func (s *funcSeeder) Dependencies() []string {
+	return s.dependencies
+}
+
+// FromFactory creates a seeder that saves the models created by factory.
+func FromFactory[T model.Model](name string, factory *dbtest.CountFactory[T], dependencies ...string) DependentSeeder {
+	return Func(name, func(ctx context.Context, tx database.DB) error {
+		_, err := factory.CreateContext(ctx, tx)
+		return err
+	}, dependencies...)
+}
@@ -0,0 +1,152 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/abibby/salusa/clog"
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/di"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrSeederNotFound     = errors.New("seeder not found")
+	ErrCircularDependency = errors.New("circular seeder dependency")
+)
+
+// Seeder fills the database with data. Seeders with inject tags have their
+// dependencies filled before they are run.
+type Seeder interface {
+	Name() string
+	Run(ctx context.Context, tx database.DB) error
+}
+
+// DependentSeeder is implemented by seeders that must run after other seeders.
+type DependentSeeder interface {
+	Seeder
+	Dependencies() []string
+}
+
+type Seeders struct {
+	seeders []Seeder
+}
+
+func New() *Seeders {
+	return &Seeders{
+		seeders: []Seeder{},
+	}
+}
+
+// Add adds seeders to the list. Seeders run in the order they are added unless
+// they depend on a seeder added later.
+func (s *Seeders) Add(seeders ...Seeder) *Seeders {
+	s.seeders = append(s.seeders, seeders...)
+	return s
+}
+
+// Get returns the seeder with the given name.
+func (s *Seeders) Get(name string) (Seeder, bool) {
+	for _, seeder := range s.seeders {
+		if seeder.Name() == name {
+			return seeder, true
+		}
+	}
+	return nil, false
+}
+
+// Order returns the named seeders and all of their dependencies in the order
+// they should run. If no names are given all seeders are returned.
+func (s *Seeders) Order(names ...string) ([]Seeder, error) {
+	if len(names) == 0 {
+		for _, seeder := range s.seeders {
+			names = append(names, seeder.Name())
+		}
+	}
+
+	ordered := []Seeder{}
+	added := map[string]bool{}
+	visiting := []string{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if added[name] {
+			return nil
+		}
+		for i, v := range visiting {
+			if v == name {
+				return fmt.Errorf("%s: %w", strings.Join(append(visiting[i:], name), " -> "), ErrCircularDependency)
+			}
+		}
+
+		seeder, ok := s.Get(name)
+		if !ok {
+			return fmt.Errorf("%s: %w", name, ErrSeederNotFound)
+		}
+
+		if dependent, ok := seeder.(DependentSeeder); ok {
+			visiting = append(visiting, name)
+			for _, dependency := range dependent.Dependencies() {
+				err := visit(dependency)
+				if err != nil {
+					return err
+				}
+			}
+			visiting = visiting[:len(visiting)-1]
+		}
+
+		added[name] = true
+		ordered = append(ordered, seeder)
+		return nil
+	}
+
+	for _, name := range names {
+		err := visit(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Run runs the named seeders and their dependencies, or all seeders if no
+// names are given. Each seeder is run in its own transaction.
+func (s *Seeders) Run(ctx context.Context, db database.DB, names ...string) error {
+	seeders, err := s.Order(names...)
+	if err != nil {
+		return err
+	}
+
+	logger := clog.Use(ctx)
+	update := database.NewUpdate(ctx, nil, db)
+	for _, seeder := range seeders {
+		if di.IsFillable(seeder) {
+			err = di.Fill(ctx, seeder)
+			if err != nil {
+				return fmt.Errorf("failed to fill seeder %s: %w", seeder.Name(), err)
+			}
+		}
+
+		logger.Info("starting seeder", "name", seeder.Name())
+		err = update(func(tx *sqlx.Tx) error {
+			return seeder.Run(ctx, tx)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to run seeder %s: %w", seeder.Name(), err)
+		}
+		logger.Info("finished seeder", "name", seeder.Name())
+	}
+	return nil
+}
+
+// Register adds the seeders to the dependency provider.
+func Register(seeders *Seeders) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		di.RegisterSingleton(ctx, func() *Seeders {
+			return seeders
+		})
+		return nil
+	}
+}
@@ -0,0 +1,101 @@
+package seed_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/database/seed"
+	"github.com/abibby/salusa/internal/test"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func noop(ctx context.Context, tx database.DB) error {
+	return nil
+}
+
+func names(seeders []seed.Seeder) []string {
+	result := make([]string, len(seeders))
+	for i, s := range seeders {
+		result[i] = s.Name()
+	}
+	return result
+}
+
+func TestOrder(t *testing.T) {
+	s := seed.New().Add(
+		seed.Func("a", noop, "c"),
+		seed.Func("b", noop),
+		seed.Func("c", noop, "b"),
+		seed.Func("d", noop),
+	)
+
+	t.Run("all", func(t *testing.T) {
+		seeders, err := s.Order()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b", "c", "a", "d"}, names(seeders))
+	})
+
+	t.Run("named", func(t *testing.T) {
+		seeders, err := s.Order("c")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b", "c"}, names(seeders))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := s.Order("e")
+		assert.ErrorIs(t, err, seed.ErrSeederNotFound)
+	})
+
+	t.Run("circular", func(t *testing.T) {
+		s := seed.New().Add(
+			seed.Func("a", noop, "b"),
+			seed.Func("b", noop, "a"),
+		)
+		_, err := s.Order()
+		assert.ErrorIs(t, err, seed.ErrCircularDependency)
+	})
+}
+
+func TestRun(t *testing.T) {
+	fooFactory := dbtest.NewFactory(func(tx database.DB) *test.Foo {
+		return &test.Foo{Name: "seeded"}
+	})
+
+	test.RunNoTx(t, "factory", func(t *testing.T, db *sqlx.DB) {
+		db.SetMaxOpenConns(1)
+		ctx := context.Background()
+
+		err := seed.New().
+			Add(seed.FromFactory("foos", fooFactory.Count(3))).
+			Run(ctx, db)
+		assert.NoError(t, err)
+
+		count, err := builder.From[*test.Foo]().Where("name", "=", "seeded").Count(db)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	test.RunNoTx(t, "failed seeder rolls back", func(t *testing.T, db *sqlx.DB) {
+		db.SetMaxOpenConns(1)
+		ctx := context.Background()
+		errSeed := errors.New("seed failed")
+
+		err := seed.New().
+			Add(seed.Func("fail", func(ctx context.Context, tx database.DB) error {
+				_, err := fooFactory.CreateContext(ctx, tx)
+				assert.NoError(t, err)
+				return errSeed
+			})).
+			Run(ctx, db)
+		assert.ErrorIs(t, err, errSeed)
+
+		count, err := builder.From[*test.Foo]().Where("name", "=", "seeded").Count(db)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
@@ -40,19 +40,100 @@ func Exec(ctx context.Context, db DB, query string, args []interface{}) (sql.Res
 	return result, nil
 }
 
+// txState is tracked for the transactions started by an Update or Read.
+type txState struct {
+	connection  string
+	written     bool
+	afterCommit []func() error
+}
+
+var (
+	txStatesMtx sync.Mutex
+	txStates    = map[*sqlx.Tx]*txState{}
+)
+
+// AfterCommit runs cb after tx commits if tx is a transaction started by an
+// Update or Read. Otherwise cb runs immediately. Callbacks are dropped if the
+// transaction is rolled back.
+func AfterCommit(tx DB, cb func() error) error {
+	if tx, ok := tx.(*sqlx.Tx); ok {
+		txStatesMtx.Lock()
+		state, ok := txStates[tx]
+		if ok {
+			state.afterCommit = append(state.afterCommit, cb)
+		}
+		txStatesMtx.Unlock()
+		if ok {
+			return nil
+		}
+	}
+	return cb()
+}
+
+// MarkWritten records that tx has made changes that other connections can't
+// see until it commits.
+func MarkWritten(tx DB) {
+	if tx, ok := tx.(*sqlx.Tx); ok {
+		txStatesMtx.Lock()
+		if state, ok := txStates[tx]; ok {
+			state.written = true
+		}
+		txStatesMtx.Unlock()
+	}
+}
+
+// HasWritten reports if tx may have changes that are not committed yet. Only
+// writes marked with MarkWritten are tracked, transactions that were not
+// started by an Update or Read are always assumed to have written.
+func HasWritten(tx DB) bool {
+	t, ok := tx.(*sqlx.Tx)
+	if !ok {
+		return false
+	}
+	txStatesMtx.Lock()
+	defer txStatesMtx.Unlock()
+	state, ok := txStates[t]
+	return !ok || state.written
+}
+
+// runAfterCommit stops tracking tx and runs the callbacks registered for it
+// if committed is true.
+func runAfterCommit(tx *sqlx.Tx, committed bool) error {
+	txStatesMtx.Lock()
+	state := txStates[tx]
+	delete(txStates, tx)
+	txStatesMtx.Unlock()
+
+	if !committed || state == nil {
+		return nil
+	}
+	errs := make([]error, len(state.afterCommit))
+	for i, cb := range state.afterCommit {
+		errs[i] = cb()
+	}
+	return errors.Join(errs...)
+}
+
 func runTx(ctx context.Context, db DB, f func(*sqlx.Tx) error, readOnly bool) error {
 	var tx *sqlx.Tx
 	var err error
-	switch db := db.(type) {
-	case *sqlx.DB:
-		tx, err = db.BeginTxx(ctx, &sql.TxOptions{
+	owned := false
+	switch beginner := db.(type) {
+	case TxBeginner:
+		tx, err = beginner.BeginTxx(ctx, &sql.TxOptions{
 			ReadOnly: readOnly,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to start transaction: %w", err)
 		}
+		owned = true
+		txStatesMtx.Lock()
+		txStates[tx] = &txState{connection: ConnectionName(db)}
+		txStatesMtx.Unlock()
+		// stops tracking tx and drops the callbacks if it doesn't commit
+		defer runAfterCommit(tx, false)
 	case *sqlx.Tx:
-		tx = db
+		tx = beginner
 	default:
 		return fmt.Errorf("unsupported type %v", reflect.TypeOf(db))
 	}
@@ -71,6 +152,9 @@ func runTx(ctx context.Context, db DB, f func(*sqlx.Tx) error, readOnly bool) er
 		if txErr != nil {
 			return txErr
 		}
+		if owned {
+			return runAfterCommit(tx, true)
+		}
 	} else {
 		txErr := tx.Rollback()
 		if txErr != nil {
@@ -1,6 +1,8 @@
 package helpers
 
 import (
+	"strings"
+
 	"github.com/abibby/salusa/database/dialects"
 )
 
@@ -50,12 +52,39 @@ func Join[T SQLStringer](sqlers []T, sep string) SQLStringer {
 	})
 }
 
+// Raw adds sql to the query without escaping. Bindings are marked with a ? and
+// are replaced with the dialects binding when rendered.
 func Raw(sql string, bindings ...any) SQLStringer {
 	return SQLStringFunc(func(d dialects.Dialect) (string, []any, error) {
-		return sql, bindings, nil
+		if len(bindings) == 0 {
+			return sql, bindings, nil
+		}
+		return rebind(d, sql), bindings, nil
 	})
 }
 
+// rebind replaces the ? placeholders outside of quoted strings and identifiers
+// with the dialects binding so numbered bindings stay in order.
+func rebind(d dialects.Dialect, sql string) string {
+	sb := &strings.Builder{}
+	var quote rune
+	for _, r := range sql {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '?':
+			sb.WriteString(d.Binding())
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
 func Group(sqler SQLStringer) SQLStringer {
 	return SQLStringFunc(func(d dialects.Dialect) (string, []any, error) {
 		q, bindings, err := sqler.SQLString(d)
@@ -21,13 +21,25 @@ type Case struct {
 	Builder          helpers.SQLStringer
 	ExpectedSQL      string
 	ExpectedBindings []any
+	ExpectedError    error
+	// Dialect is used to render the query, if it is nil the default dialect
+	// is used.
+	Dialect dialects.Dialect
 }
 
 func QueryTest(t *testing.T, testCases []Case) {
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
-			q, bindings, err := tc.Builder.SQLString(dialects.New())
-			assert.NoError(t, err)
+			d := tc.Dialect
+			if d == nil {
+				d = dialects.New()
+			}
+			q, bindings, err := tc.Builder.SQLString(d)
+			if tc.ExpectedError != nil {
+				assert.ErrorIs(t, err, tc.ExpectedError)
+			} else {
+				assert.NoError(t, err)
+			}
 
 			assert.Equal(t, tc.ExpectedSQL, q)
 			assert.Equal(t, tc.ExpectedBindings, bindings)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/abibby/salusa/database/databasedi"
 	"github.com/abibby/salusa/di"
 	"github.com/abibby/salusa/request"
 	"github.com/abibby/salusa/router"
@@ -36,6 +37,9 @@ func New(options ...KernelOption) *Kernel {
 		rootHandler:    http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
 		globalMiddleware: []router.Middleware{
 			request.DIMiddleware(),
+			request.RequestIDMiddleware(),
+			databasedi.StickyReadsMiddleware(),
+			databasedi.QueryTrackingMiddleware(),
 		},
 		services:     []Service{},
 		bootstrapped: false,
@@ -34,6 +34,7 @@ func (k *Kernel) Run(ctx context.Context) error {
 	headers := pflag.StringArrayP("header", "h", []string{}, "header")
 	body := pflag.StringP("body", "b", "", "body")
 	username := pflag.StringP("user", "u", "", "uername")
+	seed := pflag.Bool("seed", false, "run the seeders named in the arguments or all seeders if none are given")
 
 	pflag.Parse()
 
@@ -52,6 +53,10 @@ func (k *Kernel) Run(ctx context.Context) error {
 		return k.runFetch(ctx, *fetch, *method, *headers, *body, *username)
 	}
 
+	if *seed {
+		return k.runSeed(ctx, pflag.Args())
+	}
+
 	go k.RunServices(ctx)
 
 	return k.RunHttpServer(ctx)
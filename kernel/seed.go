@@ -0,0 +1,21 @@
+package kernel
+
+import (
+	"context"
+
+	"github.com/abibby/salusa/database/seed"
+	"github.com/abibby/salusa/di"
+	"github.com/jmoiron/sqlx"
+)
+
+func (k *Kernel) runSeed(ctx context.Context, names []string) error {
+	seeders, err := di.Resolve[*seed.Seeders](ctx)
+	if err != nil {
+		return err
+	}
+	db, err := di.Resolve[*sqlx.DB](ctx)
+	if err != nil {
+		return err
+	}
+	return seeders.Run(ctx, db, names...)
+}
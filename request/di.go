@@ -3,10 +3,13 @@ package request
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 
+	"github.com/abibby/salusa/clog"
 	"github.com/abibby/salusa/di"
 	"github.com/abibby/salusa/router"
+	"github.com/google/uuid"
 )
 
 type contextKey uint8
@@ -44,3 +47,20 @@ func DIMiddleware() router.MiddlewareFunc {
 		})
 	}
 }
+
+// RequestIDMiddleware adds the id of the request to the log context under
+// clog.RequestIDKey. The id is taken from the X-Request-Id header or generated
+// if the header is not set.
+func RequestIDMiddleware() router.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get("X-Request-Id")
+			if id == "" {
+				id = uuid.NewString()
+			}
+			w.Header().Set("X-Request-Id", id)
+			ctx := clog.With(r.Context(), slog.String(clog.RequestIDKey, id))
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
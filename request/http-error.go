@@ -44,9 +44,10 @@ type StackFrame struct {
 }
 
 type HTTPError struct {
-	err    error
-	status int
-	stack  []byte
+	err     error
+	status  int
+	stack   []byte
+	headers http.Header
 }
 
 type StatusError int
@@ -115,7 +116,26 @@ func (e *HTTPError) Status() int {
 	return e.status
 }
 
+// WithHeader adds a header to the error response, e.g. Retry-After on a 429.
+func (e *HTTPError) WithHeader(key, value string) *HTTPError {
+	if e.headers == nil {
+		e.headers = http.Header{}
+	}
+	e.headers.Add(key, value)
+	return e
+}
+
+// Header returns the headers added with WithHeader.
+func (e *HTTPError) Header() http.Header {
+	return e.headers
+}
+
 func (e *HTTPError) Respond(w http.ResponseWriter, r *http.Request) error {
+	for k, vs := range e.headers {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
 
 	response := errResponse{
 		Error:      template.HTML(e.err.Error()),
@@ -0,0 +1,24 @@
+package request_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abibby/salusa/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPErrorWithHeader(t *testing.T) {
+	err := request.NewHTTPError(errors.New("slow down"), http.StatusTooManyRequests).
+		WithHeader("Retry-After", "30")
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	assert.NoError(t, err.Respond(w, r))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+}
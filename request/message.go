@@ -95,3 +95,9 @@ func getMessage(ctx context.Context, ruleName string, options *MessageOptions) (
 	}
 	return buff.String(), nil
 }
+
+// RuleMessage returns the validation message for a rule, for validation done
+// outside of request struct tags.
+func RuleMessage(ctx context.Context, ruleName string, options *MessageOptions) (string, error) {
+	return getMessage(ctx, ruleName, options)
+}
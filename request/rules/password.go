@@ -0,0 +1,271 @@
+package rules
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BcryptMaxBytes is the longest password bcrypt hashes, anything after it is
+// silently ignored.
+const BcryptMaxBytes = 72
+
+// BreachedPasswords reports if a password has been seen in a data breach.
+type BreachedPasswords interface {
+	Breached(password string) (bool, error)
+}
+
+// PasswordViolation is a requirement of a PasswordPolicy that a password
+// failed. Rule is the validation rule the message is looked up with.
+type PasswordViolation struct {
+	Rule      string
+	Arguments []string
+}
+
+// PasswordPolicy checks passwords for length, character classes, personal
+// information and breaches.
+type PasswordPolicy struct {
+	minLength     int
+	maxBytes      int
+	letters       bool
+	mixedCase     bool
+	numbers       bool
+	symbols       bool
+	notPersonal   bool
+	uncompromised BreachedPasswords
+}
+
+// NewPasswordPolicy creates a policy that requires at least 8 characters and
+// at most BcryptMaxBytes bytes.
+func NewPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		minLength: 8,
+		maxBytes:  BcryptMaxBytes,
+	}
+}
+
+func (p *PasswordPolicy) Min(length int) *PasswordPolicy {
+	p.minLength = length
+	return p
+}
+func (p *PasswordPolicy) MaxBytes(bytes int) *PasswordPolicy {
+	p.maxBytes = bytes
+	return p
+}
+func (p *PasswordPolicy) Letters() *PasswordPolicy {
+	p.letters = true
+	return p
+}
+func (p *PasswordPolicy) MixedCase() *PasswordPolicy {
+	p.mixedCase = true
+	return p
+}
+func (p *PasswordPolicy) Numbers() *PasswordPolicy {
+	p.numbers = true
+	return p
+}
+func (p *PasswordPolicy) Symbols() *PasswordPolicy {
+	p.symbols = true
+	return p
+}
+
+// NotPersonal rejects passwords that contain the personal values passed to
+// Check, like the username or email.
+func (p *PasswordPolicy) NotPersonal() *PasswordPolicy {
+	p.notPersonal = true
+	return p
+}
+
+// Uncompromised rejects passwords in the breached password list.
+func (p *PasswordPolicy) Uncompromised(breached BreachedPasswords) *PasswordPolicy {
+	p.uncompromised = breached
+	return p
+}
+
+// Check returns the requirements the password fails. Personal values are
+// only checked if the policy has NotPersonal set.
+func (p *PasswordPolicy) Check(password string, personal ...string) ([]PasswordViolation, error) {
+	violations := []PasswordViolation{}
+	add := func(rule string, args ...string) {
+		violations = append(violations, PasswordViolation{Rule: rule, Arguments: args})
+	}
+
+	if utf8.RuneCountInString(password) < p.minLength {
+		add("min", strconv.Itoa(p.minLength))
+	}
+	if p.maxBytes > 0 && len(password) > p.maxBytes {
+		add("max_bytes", strconv.Itoa(p.maxBytes))
+	}
+	if p.letters && !hasLetter(password) {
+		add("letters")
+	}
+	if p.mixedCase && !hasMixedCase(password) {
+		add("mixed_case")
+	}
+	if p.numbers && !hasNumber(password) {
+		add("numbers")
+	}
+	if p.symbols && !hasSymbol(password) {
+		add("symbols")
+	}
+	if p.notPersonal && containsPersonal(password, personal) {
+		add("not_personal")
+	}
+	if p.uncompromised != nil {
+		breached, err := p.uncompromised.Breached(password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			add("uncompromised")
+		}
+	}
+	return violations, nil
+}
+
+var (
+	passwordPolicyMtx = &sync.RWMutex{}
+	passwordPolicy    = NewPasswordPolicy()
+)
+
+// SetPasswordPolicy sets the policy used by the password and uncompromised
+// rules.
+func SetPasswordPolicy(p *PasswordPolicy) {
+	passwordPolicyMtx.Lock()
+	defer passwordPolicyMtx.Unlock()
+	passwordPolicy = p
+}
+
+func GetPasswordPolicy() *PasswordPolicy {
+	passwordPolicyMtx.RLock()
+	defer passwordPolicyMtx.RUnlock()
+	return passwordPolicy
+}
+
+func initPasswordRules() {
+	AddStringRule("password", func(value string, args []string) bool {
+		violations, err := GetPasswordPolicy().Check(value)
+		if err != nil {
+			log.Printf("password could not be checked: %v", err)
+			return true
+		}
+		return len(violations) == 0
+	})
+	AddStringRule("max_bytes", func(value string, args []string) bool {
+		if len(args) < 1 {
+			log.Print("max_bytes must have 1 argument")
+			return true
+		}
+		maxBytes, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Printf("max_bytes argument must be int, '%s' given", args[0])
+			return true
+		}
+		return len(value) <= maxBytes
+	})
+	AddStringRule("letters", func(value string, args []string) bool {
+		return hasLetter(value)
+	})
+	AddStringRule("mixed_case", func(value string, args []string) bool {
+		return hasMixedCase(value)
+	})
+	AddStringRule("numbers", func(value string, args []string) bool {
+		return hasNumber(value)
+	})
+	AddStringRule("symbols", func(value string, args []string) bool {
+		return hasSymbol(value)
+	})
+	AddStringRule("uncompromised", func(value string, args []string) bool {
+		breached := GetPasswordPolicy().uncompromised
+		if breached == nil {
+			return true
+		}
+		ok, err := breached.Breached(value)
+		if err != nil {
+			log.Printf("password could not be checked for breaches: %v", err)
+			return true
+		}
+		return !ok
+	})
+}
+
+func hasLetter(s string) bool {
+	return strings.IndexFunc(s, unicode.IsLetter) >= 0
+}
+func hasMixedCase(s string) bool {
+	return strings.IndexFunc(s, unicode.IsUpper) >= 0 && strings.IndexFunc(s, unicode.IsLower) >= 0
+}
+func hasNumber(s string) bool {
+	return strings.IndexFunc(s, unicode.IsNumber) >= 0
+}
+func hasSymbol(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	}) >= 0
+}
+
+// containsPersonal reports if the password contains one of the values, or
+// the part of an email before the @. Values shorter than 3 characters are
+// ignored.
+func containsPersonal(password string, personal []string) bool {
+	password = strings.ToLower(password)
+	for _, v := range personal {
+		v = strings.ToLower(strings.TrimSpace(v))
+		values := []string{v}
+		if local, _, ok := strings.Cut(v, "@"); ok {
+			values = append(values, local)
+		}
+		for _, v := range values {
+			if utf8.RuneCountInString(v) >= 3 && strings.Contains(password, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BreachedPasswordFS looks passwords up in a directory of k-anonymity range
+// files. Each file is named with the first 5 characters of the upper case
+// SHA-1 hash of the password and has one line per password with the rest of
+// the hash, optionally followed by a colon and a count, the same format as
+// the Have I Been Pwned range API.
+type BreachedPasswordFS struct {
+	fsys fs.FS
+}
+
+var _ BreachedPasswords = (*BreachedPasswordFS)(nil)
+
+func NewBreachedPasswordFS(fsys fs.FS) *BreachedPasswordFS {
+	return &BreachedPasswordFS{fsys: fsys}
+}
+
+func (b *BreachedPasswordFS) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := b.fsys.Open(prefix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
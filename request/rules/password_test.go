@@ -0,0 +1,99 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sha1("password") = 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+var breachedFS = fstest.MapFS{
+	"5BAA6": &fstest.MapFile{Data: []byte("003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n")},
+}
+
+func TestPasswordRules(t *testing.T) {
+	SetPasswordPolicy(NewPasswordPolicy().Uncompromised(NewBreachedPasswordFS(breachedFS)))
+	defer SetPasswordPolicy(NewPasswordPolicy())
+
+	data := map[string]TestCase{
+		"password-pass":      {"password", &ValidationOptions{Value: "correct horse"}, true},
+		"password-fail":      {"password", &ValidationOptions{Value: "short"}, false},
+		"password-breached":  {"password", &ValidationOptions{Value: "password"}, false},
+		"max_bytes-pass":     {"max_bytes", &ValidationOptions{Value: "ab", Arguments: []string{"2"}}, true},
+		"max_bytes-fail":     {"max_bytes", &ValidationOptions{Value: "éé", Arguments: []string{"2"}}, false},
+		"letters-pass":       {"letters", &ValidationOptions{Value: "1a"}, true},
+		"letters-fail":       {"letters", &ValidationOptions{Value: "12"}, false},
+		"mixed_case-pass":    {"mixed_case", &ValidationOptions{Value: "aB"}, true},
+		"mixed_case-fail":    {"mixed_case", &ValidationOptions{Value: "ab"}, false},
+		"numbers-pass":       {"numbers", &ValidationOptions{Value: "a1"}, true},
+		"numbers-fail":       {"numbers", &ValidationOptions{Value: "ab"}, false},
+		"symbols-pass":       {"symbols", &ValidationOptions{Value: "a!"}, true},
+		"symbols-fail":       {"symbols", &ValidationOptions{Value: "a1"}, false},
+		"uncompromised-pass": {"uncompromised", &ValidationOptions{Value: "correct horse"}, true},
+		"uncompromised-fail": {"uncompromised", &ValidationOptions{Value: "password"}, false},
+	}
+
+	runTests(t, data)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	rules := func(violations []PasswordViolation) []string {
+		names := make([]string, len(violations))
+		for i, v := range violations {
+			names[i] = v.Rule
+		}
+		return names
+	}
+
+	t.Run("character classes", func(t *testing.T) {
+		p := NewPasswordPolicy().Letters().MixedCase().Numbers().Symbols()
+
+		violations, err := p.Check("12345678")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"letters", "mixed_case", "symbols"}, rules(violations))
+
+		violations, err = p.Check("Abcdefg1!")
+		assert.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+
+	t.Run("length", func(t *testing.T) {
+		violations, err := NewPasswordPolicy().Min(10).Check("short")
+		assert.NoError(t, err)
+		assert.Equal(t, []PasswordViolation{{Rule: "min", Arguments: []string{"10"}}}, violations)
+
+		violations, err = NewPasswordPolicy().Check(strings.Repeat("a", BcryptMaxBytes+1))
+		assert.NoError(t, err)
+		assert.Equal(t, []PasswordViolation{{Rule: "max_bytes", Arguments: []string{"72"}}}, violations)
+	})
+
+	t.Run("personal", func(t *testing.T) {
+		p := NewPasswordPolicy().NotPersonal()
+
+		violations, err := p.Check("my-Adam-password", "user", "adam@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"not_personal"}, rules(violations))
+
+		violations, err = p.Check("correct horse", "user", "adam@example.com")
+		assert.NoError(t, err)
+		assert.Empty(t, violations)
+
+		violations, err = NewPasswordPolicy().Check("adam-password", "adam")
+		assert.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+
+	t.Run("breached", func(t *testing.T) {
+		b := NewBreachedPasswordFS(breachedFS)
+
+		breached, err := b.Breached("password")
+		assert.NoError(t, err)
+		assert.True(t, breached)
+
+		breached, err = b.Breached("correct horse battery staple")
+		assert.NoError(t, err)
+		assert.False(t, breached)
+	})
+}
@@ -32,6 +32,7 @@ var initRules = sync.OnceFunc(func() {
 	initGenericRules()
 	initBoolRules()
 	initDateRules()
+	initPasswordRules()
 })
 
 func GetRule(key string) (ValidationRule, bool) {
@@ -0,0 +1,118 @@
+// Package resource transforms models into the shape they are returned from the
+// api. Resources are plain structs with json tags, so handlers can return them
+// directly and openapidoc documents the transformed shape instead of the
+// model.
+package resource
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/abibby/salusa/auth"
+)
+
+// Resource is implemented by pointers to structs that can be built from a
+// model.
+type Resource[T any] interface {
+	Transform(ctx context.Context, model T) error
+}
+
+type resourcePtr[R, T any] interface {
+	*R
+	Resource[T]
+}
+
+// Relation is implemented by relationships such as builder.HasMany.
+type Relation[T any] interface {
+	Value() (T, bool)
+	Loaded() bool
+}
+
+// Collection wraps a list of resources in a data key.
+type Collection[R any] struct {
+	Data []*R `json:"data"`
+}
+
+// Make transforms a model into the resource R. A nil model returns a nil
+// resource.
+func Make[R any, PR resourcePtr[R, T], T any](ctx context.Context, model T) (*R, error) {
+	if isNil(model) {
+		return nil, nil
+	}
+	r := new(R)
+	err := PR(r).Transform(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// MakeMany transforms a list of models into a list of resources.
+func MakeMany[R any, PR resourcePtr[R, T], T any](ctx context.Context, models []T) ([]*R, error) {
+	resources := make([]*R, len(models))
+	for i, m := range models {
+		r, err := Make[R, PR](ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		resources[i] = r
+	}
+	return resources, nil
+}
+
+// MakeCollection transforms a list of models into a Collection.
+func MakeCollection[R any, PR resourcePtr[R, T], T any](ctx context.Context, models []T) (*Collection[R], error) {
+	resources, err := MakeMany[R, PR](ctx, models)
+	if err != nil {
+		return nil, err
+	}
+	return &Collection[R]{Data: resources}, nil
+}
+
+// WhenLoaded transforms a relationship if it has been loaded and returns nil
+// otherwise. Use it with an omitempty json tag to only include loaded
+// relationships.
+func WhenLoaded[R any, PR resourcePtr[R, T], T any](ctx context.Context, relation Relation[T]) (*R, error) {
+	if isNil(relation) || !relation.Loaded() {
+		return nil, nil
+	}
+	v, _ := relation.Value()
+	return Make[R, PR](ctx, v)
+}
+
+// WhenLoadedMany transforms a relationship to many models if it has been
+// loaded and returns nil otherwise.
+func WhenLoadedMany[R any, PR resourcePtr[R, T], T any](ctx context.Context, relation Relation[[]T]) ([]*R, error) {
+	if isNil(relation) || !relation.Loaded() {
+		return nil, nil
+	}
+	v, _ := relation.Value()
+	return MakeMany[R, PR](ctx, v)
+}
+
+// When returns a pointer to v if cond is true and nil otherwise.
+func When[V any](cond bool, v V) *V {
+	if !cond {
+		return nil
+	}
+	return &v
+}
+
+// WhenClaims returns a pointer to v if the request has claims that pass check
+// and nil otherwise.
+func WhenClaims[V any](ctx context.Context, check func(claims *auth.Claims) bool, v V) *V {
+	claims, ok := auth.GetClaimsCtx(ctx)
+	return When(ok && check(claims), v)
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package resource_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/database/builder"
+	"github.com/abibby/salusa/database/model"
+	"github.com/abibby/salusa/internal/test"
+	"github.com/abibby/salusa/openapidoc"
+	"github.com/abibby/salusa/resource"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type FooResource struct {
+	ID     int            `json:"id"`
+	Title  string         `json:"title"`
+	Secret *string        `json:"secret,omitempty"`
+	Bars   []*BarResource `json:"bars,omitempty"`
+}
+
+func (r *FooResource) Transform(ctx context.Context, foo *test.Foo) error {
+	var err error
+	r.ID = foo.ID
+	r.Title = "Foo " + foo.Name
+	r.Secret = resource.WhenClaims(ctx, func(c *auth.Claims) bool {
+		return c.Subject == "admin"
+	}, foo.Name)
+	r.Bars, err = resource.WhenLoadedMany[BarResource](ctx, foo.Bars)
+	return err
+}
+
+type BarResource struct {
+	ID int `json:"id"`
+}
+
+func (r *BarResource) Transform(ctx context.Context, bar *test.Bar) error {
+	r.ID = bar.ID
+	return nil
+}
+
+func TestMake(t *testing.T) {
+	test.Run(t, "not loaded", func(t *testing.T, tx *sqlx.Tx) {
+		foo := createFoo(t, tx)
+
+		r, err := resource.Make[FooResource](context.Background(), foo)
+		assert.NoError(t, err)
+		assertJSON(t, `{"id":1,"title":"Foo foo"}`, r)
+	})
+
+	test.Run(t, "loaded", func(t *testing.T, tx *sqlx.Tx) {
+		createFoo(t, tx)
+
+		foo, err := builder.From[*test.Foo]().With("Bars").First(tx)
+		assert.NoError(t, err)
+
+		r, err := resource.Make[FooResource](context.Background(), foo)
+		assert.NoError(t, err)
+		assertJSON(t, `{"id":1,"title":"Foo foo","bars":[{"id":1},{"id":2}]}`, r)
+	})
+
+	test.Run(t, "claims", func(t *testing.T, tx *sqlx.Tx) {
+		foo := createFoo(t, tx)
+
+		ctx := auth.WithClaims(context.Background(), auth.NewClaims().WithSubject("admin"))
+		r, err := resource.Make[FooResource](ctx, foo)
+		assert.NoError(t, err)
+		assertJSON(t, `{"id":1,"title":"Foo foo","secret":"foo"}`, r)
+
+		ctx = auth.WithClaims(context.Background(), auth.NewClaims().WithSubject("user"))
+		r, err = resource.Make[FooResource](ctx, foo)
+		assert.NoError(t, err)
+		assertJSON(t, `{"id":1,"title":"Foo foo"}`, r)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		r, err := resource.Make[FooResource](context.Background(), (*test.Foo)(nil))
+		assert.NoError(t, err)
+		assert.Nil(t, r)
+	})
+}
+
+func TestMakeCollection(t *testing.T) {
+	test.Run(t, "collection", func(t *testing.T, tx *sqlx.Tx) {
+		createFoo(t, tx)
+
+		foos, err := builder.From[*test.Foo]().Get(tx)
+		assert.NoError(t, err)
+
+		c, err := resource.MakeCollection[FooResource](context.Background(), foos)
+		assert.NoError(t, err)
+		assertJSON(t, `{"data":[{"id":1,"title":"Foo foo"}]}`, c)
+	})
+
+	t.Run("schema", func(t *testing.T) {
+		s, err := openapidoc.Schema(reflect.TypeFor[*resource.Collection[FooResource]](), true)
+		assert.NoError(t, err)
+
+		data := s.Properties["data"]
+		assert.Equal(t, []string{"array"}, []string(data.Type))
+
+		foo := data.Items.Schema
+		assert.Contains(t, foo.Properties, "title")
+		assert.Contains(t, foo.Properties, "bars")
+		assert.NotContains(t, foo.Properties, "name")
+	})
+}
+
+func createFoo(t *testing.T, tx *sqlx.Tx) *test.Foo {
+	foo := &test.Foo{Name: "foo"}
+	assert.NoError(t, model.Save(tx, foo))
+	for range 2 {
+		assert.NoError(t, model.Save(tx, &test.Bar{FooID: foo.ID}))
+	}
+	return foo
+}
+
+func assertJSON(t *testing.T, expected string, v any) {
+	t.Helper()
+	b, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.JSONEq(t, expected, string(b))
+}
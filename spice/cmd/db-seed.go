@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/abibby/salusa/spice/util"
+	"github.com/spf13/cobra"
+)
+
+// dbSeedCmd represents the db:seed command
+var dbSeedCmd = &cobra.Command{
+	Use:   "db:seed [name]...",
+	Short: "Run database seeders",
+	Long:  `Run the named seeders and their dependencies, or all seeders if no names are given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := util.LoadConfig(".")
+		if err != nil {
+			return err
+		}
+
+		goCmd := exec.Command("go", append([]string{"run", ".", "--seed"}, args...)...)
+		goCmd.Dir = c.Root
+		goCmd.Stdin = os.Stdin
+		goCmd.Stderr = os.Stderr
+		goCmd.Stdout = os.Stdout
+		return goCmd.Run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbSeedCmd)
+}
@@ -3,6 +3,9 @@ package app
 import (
 	"context"
 
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/database/cache"
+	"github.com/abibby/salusa/database/seed"
 	"github.com/abibby/salusa/event"
 	"github.com/abibby/salusa/event/cron"
 	"github.com/abibby/salusa/kernel"
@@ -16,6 +19,7 @@ import (
 	"github.com/abibby/salusa/static/template/migrations"
 	"github.com/abibby/salusa/static/template/resources"
 	"github.com/abibby/salusa/static/template/routes"
+	"github.com/abibby/salusa/static/template/seeders"
 	"github.com/abibby/salusa/view"
 	"github.com/go-openapi/spec"
 	"github.com/google/uuid"
@@ -26,9 +30,11 @@ var Kernel = kernel.New(
 	kernel.Bootstrap(
 		salusadi.Register[*models.User](migrations.Use()),
 		view.Register(resources.Content, "**/*.html"),
+		seed.Register(seeders.Use()),
 		providers.Register,
 		func(ctx context.Context) error {
 			openapidoc.RegisterFormat[uuid.UUID]("uuid")
+			auth.SetDenylist(auth.NewCacheDenylist(cache.Default()))
 			return nil
 		},
 	),
@@ -3,6 +3,8 @@ package config
 import (
 	"errors"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/abibby/salusa/database"
 	"github.com/abibby/salusa/database/dialects/sqlite"
@@ -13,10 +15,13 @@ import (
 )
 
 type Config struct {
-	Port     int
-	BasePath string
+	Port         int
+	BasePath     string
+	Key          string
+	PreviousKeys []string
 
 	Database database.Config
+	QueryLog *database.QueryLog
 	Mail     email.Config
 	Queue    event.Config
 }
@@ -32,8 +37,16 @@ func Load() *Config {
 	return &Config{
 		Port:     env.Int("PORT", 2303),
 		BasePath: env.String("BASE_PATH", ""),
+		Key:      env.String("APP_KEY", ""),
+		PreviousKeys: strings.FieldsFunc(env.String("APP_PREVIOUS_KEYS", ""), func(r rune) bool {
+			return r == ','
+		}),
 		Database: sqlite.NewConfig(env.String("DATABASE_PATH", "./db.sqlite")),
-		Queue:    event.NewChannelQueueConfig(),
+		QueryLog: &database.QueryLog{
+			SlowThreshold:     time.Duration(env.Int("DATABASE_SLOW_QUERY_MS", 500)) * time.Millisecond,
+			NPlusOneThreshold: env.Int("DATABASE_N_PLUS_ONE_THRESHOLD", 0),
+		},
+		Queue: event.NewChannelQueueConfig(),
 		Mail: &email.SMTPConfig{
 			From:     env.String("MAIL_FROM", "salusa@example.com"),
 			Host:     env.String("MAIL_HOST", "sandbox.smtp.mailtrap.io"),
@@ -51,9 +64,23 @@ func (c *Config) GetBaseURL() string {
 	return c.BasePath
 }
 
+func (c *Config) AppKey() []byte {
+	return []byte(c.Key)
+}
+func (c *Config) PreviousAppKeys() [][]byte {
+	keys := make([][]byte, len(c.PreviousKeys))
+	for i, k := range c.PreviousKeys {
+		keys[i] = []byte(k)
+	}
+	return keys
+}
+
 func (c *Config) DBConfig() database.Config {
 	return c.Database
 }
+func (c *Config) QueryLogConfig() *database.QueryLog {
+	return c.QueryLog
+}
 func (c *Config) MailConfig() email.Config {
 	return c.Mail
 }
@@ -0,0 +1,9 @@
+package migrations
+
+import (
+	"github.com/abibby/salusa/auth"
+)
+
+func init() {
+	migrations.Add(auth.RefreshTokenMigration("20261019_120000-RefreshTokens"))
+}
@@ -0,0 +1,9 @@
+package migrations
+
+import (
+	"github.com/abibby/salusa/auth/oauth"
+)
+
+func init() {
+	migrations.Add(oauth.SocialAccountMigration("20261019_130000-SocialAccounts"))
+}
@@ -0,0 +1,9 @@
+package migrations
+
+import (
+	"github.com/abibby/salusa/auth"
+)
+
+func init() {
+	migrations.Add(auth.PersonalAccessTokenMigration("20261019_140000-PersonalAccessTokens"))
+}
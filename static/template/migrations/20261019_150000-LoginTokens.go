@@ -0,0 +1,9 @@
+package migrations
+
+import (
+	"github.com/abibby/salusa/auth"
+)
+
+func init() {
+	migrations.Add(auth.LoginTokenMigration("20261019_150000-LoginTokens"))
+}
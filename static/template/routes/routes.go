@@ -4,6 +4,7 @@ import (
 	"github.com/abibby/salusa/auth"
 	"github.com/abibby/salusa/openapidoc"
 	"github.com/abibby/salusa/request"
+	"github.com/abibby/salusa/request/rules"
 	"github.com/abibby/salusa/router"
 	"github.com/abibby/salusa/static/template/app/handlers"
 	"github.com/abibby/salusa/static/template/app/models"
@@ -13,13 +14,14 @@ import (
 
 func InitRoutes(r *router.Router) {
 	r.Use(request.HandleErrors())
-	r.Use(auth.AttachUser())
+	r.Use(auth.AttachUser(auth.NewBearerGuard(), auth.NewPersonalAccessTokenGuard()))
 
 	r.Get("/", view.View("index.html", nil)).Name("home")
 	r.Get("/login", view.View("login.html", nil)).Name("login")
 	r.Get("/user/create", view.View("create_user.html", nil)).Name("user.create")
 
 	r.Handle("/docs", openapidoc.SwaggerUI())
+	r.Get("/.well-known/jwks.json", auth.JWKS()).Name("auth.jwks")
 
 	r.Group("/api", func(r *router.Router) {
 		auth.RegisterRoutes(r, auth.NewBasicAuthController[*models.User](
@@ -33,6 +35,8 @@ func InitRoutes(r *router.Router) {
 				}, &r.UserCreateRequest)
 			}),
 			auth.ResetPasswordName("reset-password"),
+			auth.Throttle(auth.NewLoginThrottle(auth.NewMemoryThrottleStore())),
+			auth.PasswordPolicy(rules.NewPasswordPolicy().NotPersonal()),
 		))
 
 		r.Get("/user", handlers.UserList)
@@ -0,0 +1,20 @@
+package seeders
+
+import (
+	"github.com/abibby/salusa/database"
+	"github.com/abibby/salusa/database/dbtest"
+	"github.com/abibby/salusa/database/seed"
+	"github.com/abibby/salusa/static/template/app/models"
+)
+
+var FooFactory = dbtest.NewFactory(func(tx database.DB) *models.Foo {
+	return &models.Foo{}
+})
+
+var seeders = seed.New().Add(
+	seed.FromFactory("foos", FooFactory.Count(10)),
+)
+
+func Use() *seed.Seeders {
+	return seeders
+}
@@ -0,0 +1,122 @@
+// Package tenant resolves the tenant of a request and stores it in the request
+// context where mixins.TenantOwned models read it from.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/request"
+	"github.com/abibby/salusa/router"
+)
+
+var ErrTenantMismatch = errors.New("tenant does not match the authenticated user")
+
+type contextKey uint8
+
+const (
+	tenantKey contextKey = iota
+)
+
+// WithTenant returns a copy of ctx with the tenant attached.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// Get returns the tenant attached to ctx.
+func Get(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenant, ok := ctx.Value(tenantKey).(string)
+	return tenant, ok && tenant != ""
+}
+
+// Resolver finds the tenant of a request.
+type Resolver func(r *http.Request) (string, bool)
+
+// FromSubdomain resolves the tenant from the subdomain of domain the request was
+// sent to, e.g. acme.example.com with the domain example.com resolves to acme.
+//
+// The subdomain is chosen by the client so it must not be used for
+// authorization on its own. Middleware checks it against the tenant claim of
+// the authenticated user.
+func FromSubdomain(domain string) Resolver {
+	suffix := "." + strings.ToLower(domain)
+	return func(r *http.Request) (string, bool) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if !strings.HasSuffix(host, suffix) {
+			return "", false
+		}
+		subdomain := strings.TrimSuffix(host, suffix)
+		if subdomain == "" || strings.Contains(subdomain, ".") {
+			return "", false
+		}
+		return subdomain, true
+	}
+}
+
+// FromHeader resolves the tenant from a request header.
+//
+// The header is chosen by the client so it must not be used for authorization
+// on its own. Middleware checks it against the tenant claim of the
+// authenticated user.
+func FromHeader(name string) Resolver {
+	return func(r *http.Request) (string, bool) {
+		tenant := r.Header.Get(name)
+		return tenant, tenant != ""
+	}
+}
+
+// FromClaim resolves the tenant from the tenant claim of the authenticated
+// user. It must run after auth.AttachUser.
+func FromClaim() Resolver {
+	return func(r *http.Request) (string, bool) {
+		claims, ok := auth.GetClaims(r)
+		if !ok || claims.Tenant == "" {
+			return "", false
+		}
+		return claims.Tenant, true
+	}
+}
+
+// Middleware attaches the tenant from the first resolver that finds one to the
+// request context. Requests without a tenant are passed through unchanged.
+//
+// If the request has claims with a tenant, requests for any other tenant are
+// rejected with a 403 error wrapping ErrTenantMismatch. Claims are only
+// available if Middleware runs after auth.AttachUser. Users without a tenant
+// claim are not checked, their access to the tenant must be authorized by the
+// application.
+func Middleware(resolvers ...Resolver) router.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, resolve := range resolvers {
+				tenant, ok := resolve(r)
+				if !ok {
+					continue
+				}
+				claims, ok := auth.GetClaims(r)
+				if ok && claims.Tenant != "" && claims.Tenant != tenant {
+					err := request.NewHTTPError(ErrTenantMismatch, http.StatusForbidden).Respond(w, r)
+					if err != nil {
+						log.Print(err)
+					}
+					return
+				}
+				r = r.WithContext(WithTenant(r.Context(), tenant))
+				break
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
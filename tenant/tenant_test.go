@@ -0,0 +1,124 @@
+package tenant_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abibby/salusa/auth"
+	"github.com/abibby/salusa/tenant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvers(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Resolver tenant.Resolver
+		Request  func(r *http.Request)
+		Tenant   string
+		Ok       bool
+	}{
+		{
+			Name:     "subdomain",
+			Resolver: tenant.FromSubdomain("example.com"),
+			Request:  func(r *http.Request) { r.Host = "acme.example.com:8080" },
+			Tenant:   "acme",
+			Ok:       true,
+		},
+		{
+			Name:     "no subdomain",
+			Resolver: tenant.FromSubdomain("example.com"),
+			Request:  func(r *http.Request) { r.Host = "example.com" },
+			Ok:       false,
+		},
+		{
+			Name:     "nested subdomain",
+			Resolver: tenant.FromSubdomain("example.com"),
+			Request:  func(r *http.Request) { r.Host = "a.b.example.com" },
+			Ok:       false,
+		},
+		{
+			Name:     "header",
+			Resolver: tenant.FromHeader("X-Tenant"),
+			Request:  func(r *http.Request) { r.Header.Set("X-Tenant", "acme") },
+			Tenant:   "acme",
+			Ok:       true,
+		},
+		{
+			Name:     "missing header",
+			Resolver: tenant.FromHeader("X-Tenant"),
+			Request:  func(r *http.Request) {},
+			Ok:       false,
+		},
+		{
+			Name:     "no claims",
+			Resolver: tenant.FromClaim(),
+			Request:  func(r *http.Request) {},
+			Ok:       false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			tc.Request(r)
+			id, ok := tc.Resolver(r)
+			assert.Equal(t, tc.Ok, ok)
+			assert.Equal(t, tc.Tenant, id)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var resolved string
+	var found bool
+	h := tenant.Middleware(
+		tenant.FromHeader("X-Tenant"),
+		tenant.FromSubdomain("example.com"),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resolved, found = tenant.Get(r.Context())
+	}))
+
+	r := httptest.NewRequest("GET", "http://acme.example.com/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, found)
+	assert.Equal(t, "acme", resolved)
+
+	r = httptest.NewRequest("GET", "http://acme.example.com/", nil)
+	r.Header.Set("X-Tenant", "header")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "header", resolved)
+
+	r = httptest.NewRequest("GET", "http://example.com/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.False(t, found)
+}
+
+func TestMiddlewareClaimsTenant(t *testing.T) {
+	called := false
+	h := tenant.Middleware(
+		tenant.FromHeader("X-Tenant"),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	withClaims := func(r *http.Request, claims *auth.Claims) *http.Request {
+		return r.WithContext(auth.WithClaims(r.Context(), claims))
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Tenant", "other")
+	r = withClaims(r, auth.NewClaims().WithTenant("acme"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.False(t, called)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Tenant", "acme")
+	r = withClaims(r, auth.NewClaims().WithTenant("acme"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, w.Code)
+}